@@ -0,0 +1,121 @@
+package certs
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//DefaultReloadInterval is how often a Manager checks
+//whether its certificate files have changed
+const DefaultReloadInterval = time.Hour
+
+//Manager serves a TLS certificate and key pair from files,
+//reloading them when they change, like when Let's Encrypt
+//renews the certificate, without restarting the server.
+//If the new files are invalid, the old pair is still served.
+type Manager struct {
+	//CertPath and KeyPath are the paths of the certificate and private key files
+	CertPath string
+	KeyPath  string
+
+	//cert holds the *tls.Certificate being served
+	cert atomic.Value
+
+	mx      sync.Mutex
+	certMod time.Time
+	keyMod  time.Time
+}
+
+//NewManager constructs a new Manager for the certificate and key files
+//at `certPath` and `keyPath`, returning an error if they can't be loaded
+func NewManager(certPath string, keyPath string) (*Manager, error) {
+	m := &Manager{CertPath: certPath, KeyPath: keyPath}
+	if err := m.Reload(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+//Certificate returns the certificate being served
+func (m *Manager) Certificate() *tls.Certificate {
+	cert, _ := m.cert.Load().(*tls.Certificate)
+	return cert
+}
+
+//GetCertificate returns the certificate being served. It is used as
+//the GetCertificate function of the server's tls.Config.
+func (m *Manager) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cert := m.Certificate()
+	if cert == nil {
+		return nil, fmt.Errorf("no certificate loaded")
+	}
+	return cert, nil
+}
+
+//Reload re-reads the certificate and key files, and serves them from
+//then on. If they are invalid, the certificate already being served
+//continues to be, and the error is returned.
+func (m *Manager) Reload() error {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	certMod, keyMod := modTime(m.CertPath), modTime(m.KeyPath)
+	m.certMod, m.keyMod = certMod, keyMod
+	cert, err := tls.LoadX509KeyPair(m.CertPath, m.KeyPath)
+	if err != nil {
+		return fmt.Errorf("error loading TLS certificate: %v", err)
+	}
+	m.cert.Store(&cert)
+	return nil
+}
+
+//reloadIfChanged reloads the certificate and key files if either has
+//been modified since they were last loaded, returning whether it did
+func (m *Manager) reloadIfChanged() (bool, error) {
+	m.mx.Lock()
+	changed := !modTime(m.CertPath).Equal(m.certMod) || !modTime(m.KeyPath).Equal(m.keyMod)
+	m.mx.Unlock()
+	if !changed {
+		return false, nil
+	}
+	return true, m.Reload()
+}
+
+//Watch checks every `interval` whether the certificate and key files
+//have changed and reloads them if so, until `ctx` is done. Errors
+//reloading them are logged, and retried when the files change again.
+func (m *Manager) Watch(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				reloaded, err := m.reloadIfChanged()
+				if err != nil {
+					log.Printf("%v; still serving the previous certificate", err)
+				} else if reloaded {
+					log.Printf("reloaded TLS certificate from %s", m.CertPath)
+				}
+			}
+		}
+	}()
+}
+
+//modTime returns the modification time of the file at `path`,
+//following symbolic links like those Let's Encrypt uses, or
+//the zero time if it can't be read
+func modTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
@@ -0,0 +1,173 @@
+package certs
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+//newTestPair returns a PEM-encoded self-signed certificate
+//for `commonName` and its private key
+func newTestPair(t *testing.T, commonName string) ([]byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("error generating key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("error creating certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("error marshaling key: %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+//writeTestPair writes `cert` and `key` to the files at `certPath` and
+//`keyPath`, with a modification time after any previous one
+func writeTestPair(t *testing.T, certPath string, keyPath string, cert []byte, key []byte) {
+	modTime := time.Now()
+	if info, err := os.Stat(certPath); err == nil && !info.ModTime().Before(modTime) {
+		modTime = info.ModTime().Add(time.Second)
+	}
+	for path, contents := range map[string][]byte{certPath: cert, keyPath: key} {
+		if err := os.WriteFile(path, contents, 0600); err != nil {
+			t.Fatalf("error writing %s: %v", path, err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatalf("error setting modification time of %s: %v", path, err)
+		}
+	}
+}
+
+//commonName returns the common name of the certificate `m` serves
+func commonName(t *testing.T, m *Manager) string {
+	cert, err := m.GetCertificate(nil)
+	if err != nil {
+		t.Fatalf("unexpected error getting certificate: %v", err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatalf("error parsing certificate: %v", err)
+	}
+	return leaf.Subject.CommonName
+}
+
+func TestManagerReload(t *testing.T) {
+	dir := t.TempDir()
+	certPath, keyPath := filepath.Join(dir, "fullchain.pem"), filepath.Join(dir, "privkey.pem")
+
+	if _, err := NewManager(certPath, keyPath); err == nil {
+		t.Errorf("expected an error when the files don't exist")
+	}
+
+	oldCert, oldKey := newTestPair(t, "old")
+	writeTestPair(t, certPath, keyPath, oldCert, oldKey)
+	m, err := NewManager(certPath, keyPath)
+	if err != nil {
+		t.Fatalf("unexpected error constructing manager: %v", err)
+	}
+	if name := commonName(t, m); name != "old" {
+		t.Errorf("incorrect certificate: expected %q but got %q", "old", name)
+	}
+
+	newCert, newKey := newTestPair(t, "new")
+	cases := []struct {
+		name         string
+		hint         string
+		cert         []byte
+		key          []byte
+		expectError  bool
+		expectedName string
+	}{
+		{
+			"Invalid Certificate",
+			"Invalid files should be rejected, and the old pair kept",
+			[]byte("not a certificate"),
+			oldKey,
+			true,
+			"old",
+		},
+		{
+			"Mismatched Pair",
+			"A certificate whose key doesn't match should be rejected, like when only one file has been renewed",
+			newCert,
+			oldKey,
+			true,
+			"old",
+		},
+		{
+			"Renewed",
+			"A valid new pair should be swapped in",
+			newCert,
+			newKey,
+			false,
+			"new",
+		},
+		{
+			"Empty Key",
+			"Invalid files should be rejected, and the last valid pair kept",
+			newCert,
+			[]byte{},
+			true,
+			"new",
+		},
+	}
+
+	for _, c := range cases {
+		writeTestPair(t, certPath, keyPath, c.cert, c.key)
+		err := m.Reload()
+		if err != nil != c.expectError {
+			t.Errorf("case %s: expected error to be %t but got %v\nHINT: %s", c.name, c.expectError, err, c.hint)
+		}
+		if name := commonName(t, m); name != c.expectedName {
+			t.Errorf("case %s: incorrect certificate: expected %q but got %q\nHINT: %s", c.name, c.expectedName, name, c.hint)
+		}
+	}
+}
+
+func TestManagerWatch(t *testing.T) {
+	dir := t.TempDir()
+	certPath, keyPath := filepath.Join(dir, "fullchain.pem"), filepath.Join(dir, "privkey.pem")
+	oldCert, oldKey := newTestPair(t, "old")
+	writeTestPair(t, certPath, keyPath, oldCert, oldKey)
+	m, err := NewManager(certPath, keyPath)
+	if err != nil {
+		t.Fatalf("unexpected error constructing manager: %v", err)
+	}
+
+	if reloaded, _ := m.reloadIfChanged(); reloaded {
+		t.Errorf("unchanged files should not be reloaded")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	m.Watch(ctx, 10*time.Millisecond)
+
+	newCert, newKey := newTestPair(t, "new")
+	writeTestPair(t, certPath, keyPath, newCert, newKey)
+	deadline := time.Now().Add(5 * time.Second)
+	for commonName(t, m) != "new" {
+		if time.Now().After(deadline) {
+			t.Fatalf("changed files should be reloaded while watching")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
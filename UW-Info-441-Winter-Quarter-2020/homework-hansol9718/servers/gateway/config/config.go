@@ -0,0 +1,462 @@
+package config
+
+import (
+	"bufio"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis"
+	//registers the "mysql" database driver
+	_ "github.com/go-sql-driver/mysql"
+
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-hansol9718/servers/gateway/handlers"
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-hansol9718/servers/gateway/logging"
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-hansol9718/servers/gateway/models/users"
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-hansol9718/servers/gateway/sessions"
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-hansol9718/servers/gateway/summary"
+)
+
+//FileVar is the environment variable naming the optional
+//config file. Settings in the environment override it.
+const FileVar = "CONFIGFILE"
+
+//Names of the settings, which are read from environment
+//variables and config file entries of the same name
+const (
+	VarAddr             = "ADDR"
+	VarTLSKey           = "TLSKEY"
+	VarTLSCert          = "TLSCERT"
+	VarDevMode          = "DEVMODE"
+	VarRedirectAddr     = "REDIRECTADDR"
+	VarSessionKey       = "SESSIONKEY"
+	VarSessionDuration  = "SESSIONDURATION"
+	VarRedisAddr        = "REDISADDR"
+	VarSummaryRedisAddr = "SUMMARYREDISADDR"
+	VarSummaryStale     = "SUMMARYSTALE"
+	VarDSN              = "DSN"
+	VarAllowedOrigins   = "ALLOWEDORIGINS"
+	VarProxyRoutes      = "PROXYROUTES"
+	VarProxyBalance     = "PROXYBALANCE"
+	VarProxyHealthPath  = "PROXYHEALTHPATH"
+	VarFetchAllow       = "FETCHALLOW"
+	VarFetchDeny        = "FETCHDENY"
+	VarSummaryDeep      = "SUMMARYDEEP"
+	VarBatchMax         = "BATCHMAX"
+	VarBatchTimeout     = "BATCHTIMEOUT"
+	VarImageProxyKey    = "IMAGEPROXYKEY"
+	VarImageProxyOrigin = "IMAGEPROXYORIGIN"
+)
+
+//vars are all the settings' names
+var vars = []string{
+	VarAddr, VarTLSKey, VarTLSCert, VarDevMode, VarRedirectAddr,
+	VarSessionKey, VarSessionDuration, VarRedisAddr, VarDSN,
+	VarSummaryRedisAddr, VarSummaryStale,
+	VarAllowedOrigins, VarProxyRoutes, VarProxyBalance, VarProxyHealthPath,
+	VarFetchAllow, VarFetchDeny, VarSummaryDeep, VarBatchMax, VarBatchTimeout,
+	VarImageProxyKey, VarImageProxyOrigin,
+}
+
+//Default settings
+const (
+	//DefaultAddr is the address the server listens on
+	DefaultAddr = ":443"
+	//DefaultDevAddr is the address the server listens
+	//on when serving plain HTTP in dev mode
+	DefaultDevAddr = ":4000"
+	//DefaultSessionDuration is how long sessions last
+	DefaultSessionDuration = time.Hour
+	//DefaultSummaryCacheSize is how many page summaries are cached
+	//in memory when SUMMARYREDISADDR isn't set
+	DefaultSummaryCacheSize = 1000
+)
+
+//Config is the gateway's configuration
+type Config struct {
+	//Addr is the address the server listens on
+	Addr string
+	//TLSKeyPath and TLSCertPath are the paths of the
+	//server's TLS private key and certificate files.
+	//They may only be empty in dev mode.
+	TLSKeyPath  string
+	TLSCertPath string
+	//DevMode allows serving plain HTTP, for local development,
+	//when no TLS key and certificate are configured
+	DevMode bool
+	//RedirectAddr is the address of an optional plain HTTP
+	//listener that redirects every request to HTTPS
+	RedirectAddr string
+	//SessionKey signs session IDs
+	SessionKey string
+	//SessionDuration is how long sessions last
+	SessionDuration time.Duration
+	//RedisAddr is the address of the redis server that stores sessions
+	RedisAddr string
+	//SummaryRedisAddr is the address of the redis server that caches
+	//page summaries, which should be a different one from RedisAddr
+	//with a maxmemory limit, so that summaries can't crowd out
+	//sessions. If empty, summaries are cached in memory.
+	SummaryRedisAddr string
+	//SummaryStale is how long stale page summaries are kept in
+	//redis so that they can be revalidated, up to a day. If zero,
+	//they are deleted as soon as they go stale.
+	SummaryStale time.Duration
+	//DSN is the data source name of the MySQL database
+	//that stores user accounts
+	DSN string
+	//AllowedOrigins are the origins browser clients may call
+	//the API from. If empty, every origin may.
+	AllowedOrigins []string
+	//ProxyRoutes are the routes proxied to microservices
+	ProxyRoutes []*handlers.ProxyRoute
+	//ProxyBalance is how proxied requests are spread across backends
+	ProxyBalance handlers.Strategy
+	//ProxyHealthPath is the path backends' health is checked at.
+	//If empty, backends are never checked.
+	ProxyHealthPath string
+	//FetchAllow are private networks pages may be summarized from,
+	//and FetchDeny are public networks they may not be
+	FetchAllow []*net.IPNet
+	FetchDeny  []*net.IPNet
+	//SummaryDeep makes every page summary read the page's body,
+	//for an excerpt of its text and a fallback preview image.
+	//Otherwise clients ask for it per request with ?deep=true.
+	SummaryDeep bool
+	//BatchMax is the most URLs summarized in one batch request
+	BatchMax int
+	//BatchTimeout is how long a batch request waits for summaries,
+	//which may be no longer than a single summary is waited for
+	BatchTimeout time.Duration
+	//ImageProxyKey signs image proxy URLs. If set, the preview
+	//images in summaries are served through the image proxy.
+	ImageProxyKey string
+	//ImageProxyOrigin is the scheme and host clients reach the
+	//gateway at, like "https://api.example.com", which image
+	//proxy URLs point to. It must be set along with ImageProxyKey.
+	ImageProxyOrigin string
+}
+
+//Errors are all of the problems found with a configuration
+type Errors []error
+
+//Error implements the error interface
+func (errs Errors) Error() string {
+	lines := make([]string, 0, len(errs)+1)
+	lines = append(lines, "invalid configuration:")
+	for _, err := range errs {
+		lines = append(lines, "  "+err.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+//Load loads the configuration from the environment, and from the
+//config file named by the CONFIGFILE environment variable, if set.
+//Environment variables override the config file, except those set
+//to an empty string, which are treated as unset.
+func Load() (*Config, error) {
+	return load(os.LookupEnv)
+}
+
+//load loads the configuration using `lookupEnv` to read the environment.
+//Problems with the config file are reported along with those of
+//the settings, which are still validated without it.
+func load(lookupEnv func(string) (string, bool)) (*Config, error) {
+	settings := map[string]string{}
+	var errs Errors
+	if path, ok := lookupEnv(FileVar); ok && len(path) > 0 {
+		fileSettings, err := ReadFile(path)
+		errs = appendErrors(errs, err)
+		if fileSettings != nil {
+			settings = fileSettings
+		}
+	}
+	for _, name := range vars {
+		if value, ok := lookupEnv(name); ok && len(value) > 0 {
+			settings[name] = value
+		}
+	}
+	c, err := Parse(settings)
+	if errs = appendErrors(errs, err); len(errs) > 0 {
+		return nil, errs
+	}
+	return c, nil
+}
+
+//appendErrors appends `err` to `errs`, or the errors
+//it lists if it is Errors, unless it is nil
+func appendErrors(errs Errors, err error) Errors {
+	var list Errors
+	switch {
+	case err == nil:
+		return errs
+	case errors.As(err, &list):
+		return append(errs, list...)
+	default:
+		return append(errs, err)
+	}
+}
+
+//ReadFile reads the settings in the config file at `path`. Each line
+//is a NAME=value pair, like an environment variable, and may have its
+//value quoted. Blank lines and lines starting with # are ignored.
+//If any lines are invalid, or the file can't be read to the end, the
+//returned error is Errors, listing every problem, and the settings
+//from the valid lines are returned with it.
+func ReadFile(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening config file: %v", err)
+	}
+	defer f.Close()
+
+	settings := map[string]string{}
+	var errs Errors
+	known := map[string]bool{}
+	for _, name := range vars {
+		known[name] = true
+	}
+	scanner := bufio.NewScanner(f)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, "=", 2)
+		name := strings.TrimSpace(parts[0])
+		if len(parts) != 2 {
+			errs = append(errs, fmt.Errorf("%s:%d: must be NAME=value", path, lineNum))
+			continue
+		}
+		if !known[name] {
+			errs = append(errs, fmt.Errorf("%s:%d: unknown setting %q", path, lineNum, name))
+			continue
+		}
+		settings[name] = unquote(strings.TrimSpace(parts[1]))
+	}
+	if err := scanner.Err(); err != nil {
+		errs = append(errs, fmt.Errorf("error reading config file: %v", err))
+	}
+	if len(errs) > 0 {
+		return settings, errs
+	}
+	return settings, nil
+}
+
+//unquote removes matching single or double quotes around `value`
+func unquote(value string) string {
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
+
+//Parse parses and validates `settings`, keyed by setting name. If any
+//are missing or invalid, the returned error is Errors, listing them all.
+func Parse(settings map[string]string) (*Config, error) {
+	var errs Errors
+	invalid := func(name string, format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("%s: %s", name, fmt.Sprintf(format, args...)))
+	}
+	required := func(name string) string {
+		value := strings.TrimSpace(settings[name])
+		if len(value) == 0 {
+			invalid(name, "must be set")
+		}
+		return value
+	}
+
+	c := &Config{
+		Addr:             strings.TrimSpace(settings[VarAddr]),
+		TLSKeyPath:       strings.TrimSpace(settings[VarTLSKey]),
+		TLSCertPath:      strings.TrimSpace(settings[VarTLSCert]),
+		RedirectAddr:     strings.TrimSpace(settings[VarRedirectAddr]),
+		SessionKey:       required(VarSessionKey),
+		SessionDuration:  DefaultSessionDuration,
+		RedisAddr:        required(VarRedisAddr),
+		SummaryRedisAddr: strings.TrimSpace(settings[VarSummaryRedisAddr]),
+		DSN:              required(VarDSN),
+		ProxyHealthPath:  strings.TrimSpace(settings[VarProxyHealthPath]),
+		ImageProxyKey:    strings.TrimSpace(settings[VarImageProxyKey]),
+		ImageProxyOrigin: strings.TrimSpace(settings[VarImageProxyOrigin]),
+		BatchMax:         handlers.DefaultMaxBatchSize,
+		BatchTimeout:     handlers.DefaultBatchTimeout,
+	}
+	if s := strings.TrimSpace(settings[VarDevMode]); len(s) > 0 {
+		devMode, err := strconv.ParseBool(s)
+		if err != nil {
+			invalid(VarDevMode, "must be true or false")
+		}
+		c.DevMode = devMode
+	}
+
+	//TLS is required outside of dev mode, and in dev mode
+	//the key and certificate must be set together or not at all
+	switch {
+	case !c.DevMode:
+		if len(c.TLSKeyPath) == 0 {
+			invalid(VarTLSKey, "must be set, unless %s is true", VarDevMode)
+		}
+		if len(c.TLSCertPath) == 0 {
+			invalid(VarTLSCert, "must be set, unless %s is true", VarDevMode)
+		}
+	case len(c.TLSKeyPath) == 0 && len(c.TLSCertPath) > 0:
+		invalid(VarTLSKey, "must be set along with %s", VarTLSCert)
+	case len(c.TLSKeyPath) > 0 && len(c.TLSCertPath) == 0:
+		invalid(VarTLSCert, "must be set along with %s", VarTLSKey)
+	}
+
+	if len(c.Addr) == 0 {
+		c.Addr = DefaultAddr
+		if !c.TLS() {
+			c.Addr = DefaultDevAddr
+		}
+	} else if _, _, err := net.SplitHostPort(c.Addr); err != nil {
+		invalid(VarAddr, "must be [host]:port, like %q", DefaultAddr)
+	}
+	if len(c.RedirectAddr) > 0 {
+		if _, _, err := net.SplitHostPort(c.RedirectAddr); err != nil {
+			invalid(VarRedirectAddr, "must be [host]:port, like \":80\"")
+		} else if !c.TLS() {
+			invalid(VarRedirectAddr, "requires %s and %s to be set", VarTLSKey, VarTLSCert)
+		}
+	}
+
+	if s := strings.TrimSpace(settings[VarSessionDuration]); len(s) > 0 {
+		d, err := time.ParseDuration(s)
+		if err != nil || d <= 0 {
+			invalid(VarSessionDuration, "must be a positive duration, like \"1h\" or \"30m\"")
+		} else {
+			c.SessionDuration = d
+		}
+	}
+
+	if s := strings.TrimSpace(settings[VarSummaryStale]); len(s) > 0 {
+		d, err := time.ParseDuration(s)
+		if err != nil || d < 0 || d > summary.MaxStaleDuration {
+			invalid(VarSummaryStale, "must be a duration no longer than %v, like \"1h\"", summary.MaxStaleDuration)
+		} else {
+			c.SummaryStale = d
+		}
+	}
+
+	for _, origin := range strings.Split(settings[VarAllowedOrigins], ",") {
+		if origin = strings.TrimSpace(origin); len(origin) > 0 {
+			c.AllowedOrigins = append(c.AllowedOrigins, origin)
+		}
+	}
+
+	var err error
+	if c.ProxyRoutes, err = handlers.ParseProxyRoutes(settings[VarProxyRoutes]); err != nil {
+		invalid(VarProxyRoutes, "%v", err)
+	}
+	if c.ProxyBalance, err = handlers.ParseStrategy(settings[VarProxyBalance]); err != nil {
+		invalid(VarProxyBalance, "%v", err)
+	}
+	if len(c.ProxyHealthPath) > 0 && !strings.HasPrefix(c.ProxyHealthPath, "/") {
+		invalid(VarProxyHealthPath, "must start with /")
+	}
+	if c.FetchAllow, err = summary.ParseNetworks(settings[VarFetchAllow]); err != nil {
+		invalid(VarFetchAllow, "%v", err)
+	}
+	if c.FetchDeny, err = summary.ParseNetworks(settings[VarFetchDeny]); err != nil {
+		invalid(VarFetchDeny, "%v", err)
+	}
+	if s := strings.TrimSpace(settings[VarSummaryDeep]); len(s) > 0 {
+		if c.SummaryDeep, err = strconv.ParseBool(s); err != nil {
+			invalid(VarSummaryDeep, "must be true or false")
+		}
+	}
+	if s := strings.TrimSpace(settings[VarBatchMax]); len(s) > 0 {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			invalid(VarBatchMax, "must be a positive number")
+		} else {
+			c.BatchMax = n
+		}
+	}
+	if s := strings.TrimSpace(settings[VarBatchTimeout]); len(s) > 0 {
+		d, err := time.ParseDuration(s)
+		//batches taking longer would outlast the server's write timeout
+		if err != nil || d <= 0 || d > handlers.DefaultSummaryTimeout {
+			invalid(VarBatchTimeout, "must be a positive duration no longer than %v, like \"10s\"", handlers.DefaultSummaryTimeout)
+		} else {
+			c.BatchTimeout = d
+		}
+	}
+
+	//image proxy URLs point to the configured origin rather than
+	//the Host requests were sent to, which clients can forge
+	switch {
+	case len(c.ImageProxyKey) > 0 && len(c.ImageProxyOrigin) == 0:
+		invalid(VarImageProxyOrigin, "must be set along with %s", VarImageProxyKey)
+	case len(c.ImageProxyKey) == 0 && len(c.ImageProxyOrigin) > 0:
+		invalid(VarImageProxyKey, "must be set along with %s", VarImageProxyOrigin)
+	case len(c.ImageProxyOrigin) > 0:
+		u, err := url.Parse(c.ImageProxyOrigin)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 ||
+			len(strings.Trim(u.Path, "/")) > 0 || len(u.RawQuery) > 0 || len(u.Fragment) > 0 {
+			invalid(VarImageProxyOrigin, "must be a scheme and host, like \"https://api.example.com\"")
+		} else {
+			c.ImageProxyOrigin = u.Scheme + "://" + u.Host
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return c, nil
+}
+
+//TLS reports whether the server is configured to serve HTTPS
+func (c *Config) TLS() bool {
+	return len(c.TLSKeyPath) > 0 && len(c.TLSCertPath) > 0
+}
+
+//NewContext constructs the handler context for the configuration,
+//with sessions stored in redis and user accounts in MySQL
+func (c *Config) NewContext() (*handlers.Context, error) {
+	db, err := sql.Open("mysql", c.DSN)
+	if err != nil {
+		return nil, fmt.Errorf("error opening database: %v", err)
+	}
+	redisClient := redis.NewClient(&redis.Options{Addr: c.RedisAddr})
+
+	summarizer := summary.NewSummarizer()
+	summarizer.Deep = c.SummaryDeep
+	if len(c.SummaryRedisAddr) > 0 {
+		summaryClient := redis.NewClient(&redis.Options{Addr: c.SummaryRedisAddr})
+		summarizer.Cache = summary.NewRedisCache(summaryClient, c.SummaryStale)
+	} else {
+		summarizer.Cache = summary.NewMemCache(DefaultSummaryCacheSize)
+	}
+	summarizer.Fetcher = summary.NewFetcher()
+	summarizer.Fetcher.Allow = c.FetchAllow
+	summarizer.Fetcher.Deny = c.FetchDeny
+	summarizer.Fetcher.Log = logging.Default
+
+	var imageProxy *handlers.ImageProxy
+	if len(c.ImageProxyKey) > 0 {
+		fetcher := summary.NewFetcher()
+		fetcher.Allow = c.FetchAllow
+		fetcher.Deny = c.FetchDeny
+		fetcher.MaxBytes = handlers.DefaultMaxImageBytes
+		imageProxy = handlers.NewImageProxy(fetcher, c.ImageProxyOrigin, c.ImageProxyKey)
+	}
+
+	return &handlers.Context{
+		SigningKey:   c.SessionKey,
+		SessionStore: sessions.NewRedisStore(redisClient, c.SessionDuration),
+		UserStore:    users.NewMySQLStore(db),
+		Summarizer:   summarizer,
+		MaxBatchSize: c.BatchMax,
+		BatchTimeout: c.BatchTimeout,
+		ImageProxy:   imageProxy,
+	}, nil
+}
@@ -0,0 +1,295 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-hansol9718/servers/gateway/handlers"
+)
+
+//validSettings returns settings with every required one set
+func validSettings() map[string]string {
+	return map[string]string{
+		VarTLSKey:     "/tls/privkey.pem",
+		VarTLSCert:    "/tls/fullchain.pem",
+		VarSessionKey: "secret",
+		VarRedisAddr:  "redis:6379",
+		VarDSN:        "root:password@tcp(db:3306)/users",
+	}
+}
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		name           string
+		hint           string
+		settings       map[string]string
+		expectedErrors []string
+		check          func(c *Config) bool
+	}{
+		{
+			"Defaults",
+			"Optional settings should have their defaults",
+			map[string]string{},
+			nil,
+			func(c *Config) bool {
+				return c.Addr == DefaultAddr && c.SessionDuration == DefaultSessionDuration &&
+					c.ProxyBalance == handlers.RoundRobin && len(c.AllowedOrigins) == 0 &&
+					len(c.ProxyRoutes) == 0 && len(c.FetchAllow) == 0 && !c.SummaryDeep &&
+					c.BatchMax == handlers.DefaultMaxBatchSize && c.BatchTimeout == handlers.DefaultBatchTimeout &&
+					len(c.ImageProxyKey) == 0 && len(c.ImageProxyOrigin) == 0 &&
+					len(c.SummaryRedisAddr) == 0 && c.SummaryStale == 0
+			},
+		},
+		{
+			"All Settings",
+			"Every setting should be parsed",
+			map[string]string{
+				VarAddr:             ":4000",
+				VarSessionDuration:  "30m",
+				VarAllowedOrigins:   "https://a.test, https://b.test,",
+				VarProxyRoutes:      "/v1/channels=messages:80",
+				VarProxyBalance:     "leastconn",
+				VarProxyHealthPath:  "/health",
+				VarFetchAllow:       "10.0.0.0/8",
+				VarFetchDeny:        "203.0.113.0/24,198.51.100.7",
+				VarSummaryDeep:      "true",
+				VarBatchMax:         "50",
+				VarBatchTimeout:     "5s",
+				VarImageProxyKey:    "image key",
+				VarImageProxyOrigin: "https://api.test.com/",
+				VarSummaryRedisAddr: "summaries:6379",
+				VarSummaryStale:     "1h",
+			},
+			nil,
+			func(c *Config) bool {
+				return c.Addr == ":4000" && c.SessionDuration == 30*time.Minute &&
+					reflect.DeepEqual(c.AllowedOrigins, []string{"https://a.test", "https://b.test"}) &&
+					len(c.ProxyRoutes) == 1 && c.ProxyBalance == handlers.LeastConnections &&
+					c.ProxyHealthPath == "/health" && len(c.FetchAllow) == 1 && len(c.FetchDeny) == 2 &&
+					c.SummaryDeep && c.BatchMax == 50 && c.BatchTimeout == 5*time.Second &&
+					c.ImageProxyKey == "image key" && c.ImageProxyOrigin == "https://api.test.com" &&
+					c.SummaryRedisAddr == "summaries:6379" && c.SummaryStale == time.Hour
+			},
+		},
+		{
+			"Missing Required",
+			"Every missing required setting should be reported at once",
+			map[string]string{
+				VarTLSKey:     "",
+				VarTLSCert:    "",
+				VarSessionKey: "  ",
+				VarRedisAddr:  "",
+				VarDSN:        "",
+			},
+			[]string{
+				"SESSIONKEY: must be set",
+				"REDISADDR: must be set",
+				"DSN: must be set",
+				"TLSKEY: must be set",
+				"TLSCERT: must be set",
+			},
+			nil,
+		},
+		{
+			"Invalid Values",
+			"Every invalid setting should be reported at once",
+			map[string]string{
+				VarAddr:             "4000",
+				VarDevMode:          "sometimes",
+				VarRedirectAddr:     "80",
+				VarSessionDuration:  "an hour",
+				VarProxyRoutes:      "/v1/channels",
+				VarProxyBalance:     "random",
+				VarProxyHealthPath:  "health",
+				VarFetchAllow:       "10.0.0.0/33",
+				VarFetchDeny:        "not an address",
+				VarSummaryDeep:      "sometimes",
+				VarBatchMax:         "0",
+				VarBatchTimeout:     "2m",
+				VarImageProxyKey:    "image key",
+				VarImageProxyOrigin: "api.test.com",
+				VarSummaryStale:     "1w",
+			},
+			[]string{
+				"DEVMODE: ",
+				"ADDR: ",
+				"REDIRECTADDR: ",
+				"SESSIONDURATION: ",
+				"SUMMARYSTALE: ",
+				"PROXYROUTES: ",
+				"PROXYBALANCE: ",
+				"PROXYHEALTHPATH: ",
+				"FETCHALLOW: ",
+				"FETCHDENY: ",
+				"SUMMARYDEEP: ",
+				"BATCHMAX: ",
+				"BATCHTIMEOUT: ",
+				"IMAGEPROXYORIGIN: ",
+			},
+			nil,
+		},
+		{
+			"Dev Mode",
+			"In dev mode, plain HTTP should be served when TLS isn't configured",
+			map[string]string{VarDevMode: "true", VarTLSKey: "", VarTLSCert: ""},
+			nil,
+			func(c *Config) bool {
+				return c.DevMode && !c.TLS() && c.Addr == DefaultDevAddr
+			},
+		},
+		{
+			"Dev Mode With TLS",
+			"In dev mode, TLS should still be used if it's configured",
+			map[string]string{VarDevMode: "1"},
+			nil,
+			func(c *Config) bool {
+				return c.DevMode && c.TLS() && c.Addr == DefaultAddr
+			},
+		},
+		{
+			"Redirect",
+			"The redirect listener address should be parsed",
+			map[string]string{VarRedirectAddr: ":80"},
+			nil,
+			func(c *Config) bool {
+				return c.RedirectAddr == ":80"
+			},
+		},
+		{
+			"Dev Mode Partial TLS",
+			"In dev mode, the TLS key and certificate must be set together",
+			map[string]string{VarDevMode: "true", VarTLSKey: ""},
+			[]string{"TLSKEY: must be set along with TLSCERT"},
+			nil,
+		},
+		{
+			"Redirect Without TLS",
+			"Redirecting to HTTPS requires HTTPS to be served",
+			map[string]string{VarDevMode: "true", VarTLSKey: "", VarTLSCert: "", VarRedirectAddr: ":80"},
+			[]string{"REDIRECTADDR: requires"},
+			nil,
+		},
+		{
+			"Negative Session Duration",
+			"Session durations must be positive",
+			map[string]string{VarSessionDuration: "-1h"},
+			[]string{"SESSIONDURATION: "},
+			nil,
+		},
+	}
+
+	for _, c := range cases {
+		settings := validSettings()
+		for name, value := range c.settings {
+			settings[name] = value
+		}
+		config, err := Parse(settings)
+		if len(c.expectedErrors) == 0 {
+			if err != nil {
+				t.Errorf("case %s: unexpected error: %v\nHINT: %s", c.name, err, c.hint)
+			} else if !c.check(config) {
+				t.Errorf("case %s: incorrect config: %+v\nHINT: %s", c.name, config, c.hint)
+			}
+			continue
+		}
+
+		var errs Errors
+		if !errors.As(err, &errs) {
+			t.Errorf("case %s: expected Errors but got %v\nHINT: %s", c.name, err, c.hint)
+			continue
+		}
+		if len(errs) != len(c.expectedErrors) {
+			t.Errorf("case %s: expected %d errors but got %d:\n%v\nHINT: %s", c.name, len(c.expectedErrors), len(errs), err, c.hint)
+			continue
+		}
+		for i, expected := range c.expectedErrors {
+			if !strings.HasPrefix(errs[i].Error(), expected) {
+				t.Errorf("case %s: expected error %d to start with %q but got %q", c.name, i, expected, errs[i])
+			}
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "gateway.env")
+	file := "# gateway settings\n\n" +
+		"TLSKEY=/tls/privkey.pem\n" +
+		"TLSCERT = /tls/fullchain.pem\n" +
+		"SESSIONKEY=\"file secret\"\n" +
+		"REDISADDR='redis:6379'\n" +
+		"DSN=root:pass=word@tcp(db:3306)/users\n" +
+		"SESSIONDURATION=2h\n"
+	if err := os.WriteFile(path, []byte(file), 0600); err != nil {
+		t.Fatalf("error writing config file: %v", err)
+	}
+
+	env := map[string]string{
+		FileVar:            path,
+		VarSessionDuration: "15m",
+		VarDSN:             "",
+	}
+	config, err := load(func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	})
+	if err != nil {
+		t.Fatalf("unexpected error loading config: %v", err)
+	}
+	if config.SessionKey != "file secret" || config.RedisAddr != "redis:6379" {
+		t.Errorf("quoted values should be unquoted, but got %q and %q", config.SessionKey, config.RedisAddr)
+	}
+	if config.DSN != "root:pass=word@tcp(db:3306)/users" {
+		t.Errorf("values may contain =, and empty environment variables shouldn't override them, but got %q", config.DSN)
+	}
+	if config.SessionDuration != 15*time.Minute {
+		t.Errorf("environment variables should override the config file: expected %v but got %v",
+			15*time.Minute, config.SessionDuration)
+	}
+
+	badPath := filepath.Join(dir, "bad.env")
+	if err := os.WriteFile(badPath, []byte("TLSKEY\nSESIONKEY=secret\n"), 0600); err != nil {
+		t.Fatalf("error writing config file: %v", err)
+	}
+	_, err = ReadFile(badPath)
+	var errs Errors
+	if !errors.As(err, &errs) || len(errs) != 2 {
+		t.Errorf("malformed lines and unknown settings should all be reported, but got %v", err)
+	}
+
+	//lines too long to read don't hide the other problems
+	longPath := filepath.Join(dir, "long.env")
+	if err := os.WriteFile(longPath, []byte("TLSKEY\nDSN="+strings.Repeat("a", 1<<17)+"\n"), 0600); err != nil {
+		t.Fatalf("error writing config file: %v", err)
+	}
+	settings, err := ReadFile(longPath)
+	if !errors.As(err, &errs) || len(errs) != 2 || !strings.HasPrefix(errs[1].Error(), "error reading config file") || settings == nil {
+		t.Errorf("errors reading the file should be reported along with invalid lines, but got %v", err)
+	}
+
+	if _, err := ReadFile(filepath.Join(dir, "missing.env")); err == nil {
+		t.Errorf("expected an error reading a missing config file")
+	}
+
+	//a config file that can't be read doesn't hide the other problems
+	env = map[string]string{
+		FileVar:      filepath.Join(dir, "missing.env"),
+		VarDevMode:   "true",
+		VarRedisAddr: "redis:6379",
+		VarDSN:       "root:pass@tcp(db:3306)/users",
+	}
+	_, err = load(func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	})
+	if !errors.As(err, &errs) || len(errs) != 2 ||
+		!strings.HasPrefix(errs[0].Error(), "error opening config file") || !strings.HasPrefix(errs[1].Error(), "SESSIONKEY: ") {
+		t.Errorf("config file errors should be reported along with invalid settings, but got %v", err)
+	}
+}
@@ -8,8 +8,13 @@ docker run \
     -v /etc/letsencrypt:/etc/letsencrypt:ro \
     -e ADDR:=443 \
     -p 443:443\
+    -p 80:80 \
+    -e REDIRECTADDR=:80 \
     --name gateway \
     -e TLSKEY=/etc/letsencrypt/live/api.hansol7.me/privkey.pem \
     -e TLSCERT=/etc/letsencrypt/live/api.hansol7.me/fullchain.pem \
+    -e SESSIONKEY=$SESSIONKEY \
+    -e REDISADDR=$REDISADDR \
+    -e DSN=$DSN \
     hansol9718/gateway
 exit
\ No newline at end of file
@@ -1,6 +1,310 @@
 package handlers
 
-//TODO: define HTTP handler functions as described in the
-//assignment description. Remember to use your handler context
-//struct as the receiver on these functions so that you have
-//access to things like the session store and user store.
+import (
+	"encoding/json"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-hansol9718/servers/gateway/models/users"
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-hansol9718/servers/gateway/sessions"
+)
+
+const contentTypeJSON = "application/json"
+
+//currentUserID is the user ID in a path that
+//refers to the currently signed-in user
+const currentUserID = "me"
+
+//currentSessionID is the session ID in a path that
+//refers to the current session
+const currentSessionID = "mine"
+
+//dummyUser is authenticated against when signing in with an email
+//that isn't registered, so that it takes as long as signing in with
+//one that is, and the response time doesn't reveal which emails are
+//registered. Its password hash is made when the package is loaded,
+//as without one the comparison would return at once.
+var dummyUser = newDummyUser()
+
+//newDummyUser returns the dummyUser, panicking if its
+//password can't be hashed
+func newDummyUser() *users.User {
+	user := &users.User{}
+	if err := user.SetPassword("dummy password"); err != nil {
+		panic("error hashing the dummy user's password: " + err.Error())
+	}
+	return user
+}
+
+//UsersHandler handles requests for the "users" resource.
+//POST creates a new user account from a JSON-encoded
+//users.NewUser, and begins a session for the new user.
+func (ctx *Context) UsersHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+	if !isJSON(r) {
+		unsupportedMediaType(w)
+		return
+	}
+
+	newUser := &users.NewUser{}
+	if err := json.NewDecoder(r.Body).Decode(newUser); err != nil {
+		writeError(w, http.StatusBadRequest, &ErrorResponse{
+			Code:    ErrCodeBadRequest,
+			Message: "error decoding new user: " + err.Error(),
+		})
+		return
+	}
+	user, err := newUser.ToUser()
+	if err != nil {
+		writeError(w, http.StatusBadRequest, &ErrorResponse{
+			Code:    ErrCodeBadRequest,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	//the store may not enforce uniqueness itself
+	if _, err := ctx.UserStore.GetByEmail(user.Email); err != users.ErrUserNotFound {
+		userExists(w, err, "email")
+		return
+	}
+	if _, err := ctx.UserStore.GetByUserName(user.UserName); err != users.ErrUserNotFound {
+		userExists(w, err, "user name")
+		return
+	}
+
+	user, err = ctx.UserStore.Insert(user)
+	if err != nil {
+		internalError(w, "error creating user")
+		return
+	}
+	state := &SessionState{BeginTime: time.Now(), User: user}
+	if _, err := sessions.BeginSession(ctx.SigningKey, ctx.SessionStore, state, w); err != nil {
+		internalError(w, "error beginning session")
+		return
+	}
+	setLogUser(r, user.ID)
+	writeJSON(w, http.StatusCreated, user)
+}
+
+//SpecificUserHandler handles requests for a specific user, identified
+//by the last segment of the path: either the user's ID, or "me" for
+//the signed-in user. GET responds with the user's profile, and PATCH
+//applies JSON-encoded users.Updates to the signed-in user's own profile.
+//Both require a session.
+func (ctx *Context) SpecificUserHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPatch {
+		methodNotAllowed(w, http.MethodGet, http.MethodPatch)
+		return
+	}
+
+	state := &SessionState{}
+	sid, err := sessions.GetState(r, ctx.SigningKey, ctx.SessionStore, state)
+	if err != nil || state.User == nil {
+		writeError(w, http.StatusUnauthorized, &ErrorResponse{
+			Code:    ErrCodeUnauthorized,
+			Message: "you must be signed in",
+		})
+		return
+	}
+	setLogUser(r, state.User.ID)
+
+	id := state.User.ID
+	if segment := path.Base(r.URL.Path); segment != currentUserID {
+		id, err = strconv.ParseInt(segment, 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, &ErrorResponse{
+				Code:    ErrCodeBadRequest,
+				Message: "user ID must be a number or " + currentUserID,
+			})
+			return
+		}
+	}
+
+	if r.Method == http.MethodGet {
+		user, err := ctx.UserStore.GetByID(id)
+		if err == users.ErrUserNotFound {
+			writeError(w, http.StatusNotFound, &ErrorResponse{
+				Code:    ErrCodeNotFound,
+				Message: "user not found",
+			})
+			return
+		}
+		if err != nil {
+			internalError(w, "error getting user")
+			return
+		}
+		writeJSON(w, http.StatusOK, user)
+		return
+	}
+
+	if id != state.User.ID {
+		writeError(w, http.StatusForbidden, &ErrorResponse{
+			Code:    ErrCodeForbidden,
+			Message: "you may only update your own profile",
+		})
+		return
+	}
+	if !isJSON(r) {
+		unsupportedMediaType(w)
+		return
+	}
+	updates := &users.Updates{}
+	if err := json.NewDecoder(r.Body).Decode(updates); err != nil {
+		writeError(w, http.StatusBadRequest, &ErrorResponse{
+			Code:    ErrCodeBadRequest,
+			Message: "error decoding updates: " + err.Error(),
+		})
+		return
+	}
+	//validates the updates before they are saved
+	if err := state.User.ApplyUpdates(updates); err != nil {
+		writeError(w, http.StatusBadRequest, &ErrorResponse{
+			Code:    ErrCodeBadRequest,
+			Message: err.Error(),
+		})
+		return
+	}
+	user, err := ctx.UserStore.Update(id, updates)
+	if err != nil {
+		internalError(w, "error updating user")
+		return
+	}
+	//keeps the session's copy of the user current
+	state.User = user
+	if err := ctx.SessionStore.Save(sid, state); err != nil {
+		internalError(w, "error saving session")
+		return
+	}
+	writeJSON(w, http.StatusOK, user)
+}
+
+//SessionsHandler handles requests for the "sessions" resource.
+//POST signs a user in with JSON-encoded users.Credentials,
+//beginning a new session for them.
+func (ctx *Context) SessionsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+	if !isJSON(r) {
+		unsupportedMediaType(w)
+		return
+	}
+
+	creds := &users.Credentials{}
+	if err := json.NewDecoder(r.Body).Decode(creds); err != nil {
+		writeError(w, http.StatusBadRequest, &ErrorResponse{
+			Code:    ErrCodeBadRequest,
+			Message: "error decoding credentials: " + err.Error(),
+		})
+		return
+	}
+
+	user, err := ctx.UserStore.GetByEmail(creds.Email)
+	if err != nil && err != users.ErrUserNotFound {
+		internalError(w, "error getting user")
+		return
+	}
+	if err == users.ErrUserNotFound {
+		dummyUser.Authenticate(creds.Password)
+	}
+	if err != nil || user.Authenticate(creds.Password) != nil {
+		//the same response whether or not the email is registered
+		writeError(w, http.StatusUnauthorized, &ErrorResponse{
+			Code:    ErrCodeUnauthorized,
+			Message: "invalid credentials",
+		})
+		return
+	}
+
+	state := &SessionState{BeginTime: time.Now(), User: user}
+	if _, err := sessions.BeginSession(ctx.SigningKey, ctx.SessionStore, state, w); err != nil {
+		internalError(w, "error beginning session")
+		return
+	}
+	setLogUser(r, user.ID)
+	writeJSON(w, http.StatusCreated, user)
+}
+
+//SpecificSessionHandler handles requests for a specific session.
+//DELETE of "mine", the current session, signs the user out.
+func (ctx *Context) SpecificSessionHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		methodNotAllowed(w, http.MethodDelete)
+		return
+	}
+	if path.Base(r.URL.Path) != currentSessionID {
+		writeError(w, http.StatusForbidden, &ErrorResponse{
+			Code:    ErrCodeForbidden,
+			Message: "you may only end your own session",
+		})
+		return
+	}
+	if _, err := sessions.EndSession(r, ctx.SigningKey, ctx.SessionStore); err != nil {
+		writeError(w, http.StatusUnauthorized, &ErrorResponse{
+			Code:    ErrCodeUnauthorized,
+			Message: "you must be signed in",
+		})
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain")
+	w.Write([]byte("signed out"))
+}
+
+//isJSON reports whether the request body is JSON
+func isJSON(r *http.Request) bool {
+	return strings.HasPrefix(r.Header.Get("Content-Type"), contentTypeJSON)
+}
+
+//writeJSON responds with `status` and `v` encoded as JSON
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", contentTypeJSON)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+//methodNotAllowed responds that the request method
+//is not one of the `allowed` methods
+func methodNotAllowed(w http.ResponseWriter, allowed ...string) {
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	writeError(w, http.StatusMethodNotAllowed, &ErrorResponse{
+		Code:    ErrCodeMethodNotAllowed,
+		Message: "method must be " + strings.Join(allowed, " or "),
+	})
+}
+
+//unsupportedMediaType responds that the request body must be JSON
+func unsupportedMediaType(w http.ResponseWriter) {
+	writeError(w, http.StatusUnsupportedMediaType, &ErrorResponse{
+		Code:    ErrCodeUnsupportedMediaType,
+		Message: "request body must be " + contentTypeJSON,
+	})
+}
+
+//internalError responds that the gateway failed to handle the request
+func internalError(w http.ResponseWriter, message string) {
+	writeError(w, http.StatusInternalServerError, &ErrorResponse{
+		Code:    ErrCodeInternal,
+		Message: message,
+	})
+}
+
+//userExists responds to an attempt to create a user whose `field`
+//is already in use, given the error from looking that user up
+func userExists(w http.ResponseWriter, err error, field string) {
+	if err != nil {
+		internalError(w, "error checking for existing user")
+		return
+	}
+	writeError(w, http.StatusBadRequest, &ErrorResponse{
+		Code:    ErrCodeBadRequest,
+		Message: field + " is already in use",
+	})
+}
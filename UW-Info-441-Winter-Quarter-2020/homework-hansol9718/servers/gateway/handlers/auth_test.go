@@ -0,0 +1,601 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-hansol9718/servers/gateway/models/users"
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-hansol9718/servers/gateway/sessions"
+)
+
+const testSigningKey = "test signing key"
+
+//fakeUserStore is a users.Store that keeps users in a map
+type fakeUserStore struct {
+	users  map[int64]*users.User
+	nextID int64
+}
+
+func newFakeUserStore(existing ...*users.User) *fakeUserStore {
+	store := &fakeUserStore{users: map[int64]*users.User{}, nextID: 1}
+	for _, u := range existing {
+		store.Insert(u)
+	}
+	return store
+}
+
+func (s *fakeUserStore) find(match func(u *users.User) bool) (*users.User, error) {
+	for _, u := range s.users {
+		if match(u) {
+			copy := *u
+			return &copy, nil
+		}
+	}
+	return nil, users.ErrUserNotFound
+}
+
+func (s *fakeUserStore) GetByID(id int64) (*users.User, error) {
+	return s.find(func(u *users.User) bool { return u.ID == id })
+}
+
+func (s *fakeUserStore) GetByEmail(email string) (*users.User, error) {
+	return s.find(func(u *users.User) bool { return u.Email == email })
+}
+
+func (s *fakeUserStore) GetByUserName(username string) (*users.User, error) {
+	return s.find(func(u *users.User) bool { return u.UserName == username })
+}
+
+func (s *fakeUserStore) Insert(user *users.User) (*users.User, error) {
+	copy := *user
+	copy.ID = s.nextID
+	s.nextID++
+	s.users[copy.ID] = &copy
+	return s.GetByID(copy.ID)
+}
+
+func (s *fakeUserStore) Update(id int64, updates *users.Updates) (*users.User, error) {
+	u, found := s.users[id]
+	if !found {
+		return nil, users.ErrUserNotFound
+	}
+	u.FirstName = updates.FirstName
+	u.LastName = updates.LastName
+	return s.GetByID(id)
+}
+
+func (s *fakeUserStore) Delete(id int64) error {
+	delete(s.users, id)
+	return nil
+}
+
+//newTestContext returns a Context with a MemStore and a fakeUserStore
+//holding `existing` users, whose IDs start at 1
+func newTestContext(existing ...*users.User) *Context {
+	return &Context{
+		SigningKey:   testSigningKey,
+		SessionStore: sessions.NewMemStore(time.Hour, time.Hour),
+		UserStore:    newFakeUserStore(existing...),
+	}
+}
+
+//beginTestSession begins a session for `user`, returning
+//the value of the Authorization header that refers to it
+func beginTestSession(t *testing.T, ctx *Context, user *users.User) string {
+	resp := httptest.NewRecorder()
+	state := &SessionState{BeginTime: time.Now(), User: user}
+	if _, err := sessions.BeginSession(ctx.SigningKey, ctx.SessionStore, state, resp); err != nil {
+		t.Fatalf("error beginning session: %v", err)
+	}
+	return resp.Header().Get("Authorization")
+}
+
+func TestUsersHandler(t *testing.T) {
+	existing := &users.User{Email: "taken@test.com", UserName: "taken"}
+	ctx := newTestContext(existing)
+
+	newUser := func(email string, userName string, passwordConf string) string {
+		j, _ := json.Marshal(&users.NewUser{
+			Email:        email,
+			Password:     "password",
+			PasswordConf: passwordConf,
+			UserName:     userName,
+			FirstName:    "First",
+			LastName:     "Last",
+		})
+		return string(j)
+	}
+
+	cases := []struct {
+		name           string
+		hint           string
+		method         string
+		contentType    string
+		body           string
+		expectedStatus int
+	}{
+		{
+			"Valid New User",
+			"Valid new users should be created",
+			"POST",
+			"application/json",
+			newUser("new@test.com", "new", "password"),
+			http.StatusCreated,
+		},
+		{
+			"Wrong Method",
+			"Only POST is allowed",
+			"GET",
+			"",
+			"",
+			http.StatusMethodNotAllowed,
+		},
+		{
+			"Not JSON",
+			"The request body must be JSON",
+			"POST",
+			"text/plain",
+			newUser("other@test.com", "other", "password"),
+			http.StatusUnsupportedMediaType,
+		},
+		{
+			"Invalid JSON",
+			"Request bodies that can't be decoded are bad requests",
+			"POST",
+			"application/json",
+			"{not json",
+			http.StatusBadRequest,
+		},
+		{
+			"Invalid New User",
+			"New users that fail validation are bad requests",
+			"POST",
+			"application/json",
+			newUser("other@test.com", "other", "mismatch"),
+			http.StatusBadRequest,
+		},
+		{
+			"Email Taken",
+			"Emails must be unique",
+			"POST",
+			"application/json",
+			newUser("taken@test.com", "other", "password"),
+			http.StatusBadRequest,
+		},
+		{
+			"User Name Taken",
+			"User names must be unique",
+			"POST",
+			"application/json",
+			newUser("other@test.com", "taken", "password"),
+			http.StatusBadRequest,
+		},
+	}
+
+	for _, c := range cases {
+		resp := httptest.NewRecorder()
+		req, _ := http.NewRequest(c.method, "/v1/users", strings.NewReader(c.body))
+		req.Header.Set("Content-Type", c.contentType)
+		ctx.UsersHandler(resp, req)
+		if resp.Code != c.expectedStatus {
+			t.Errorf("case %s: incorrect response status code: expected %d but got %d\nHINT: %s",
+				c.name, c.expectedStatus, resp.Code, c.hint)
+			continue
+		}
+		if ctype := resp.Header().Get("Content-Type"); !strings.HasPrefix(ctype, "application/json") {
+			t.Errorf("case %s: incorrect `Content-Type` header value: expected it to start with `application/json` but got `%s`",
+				c.name, ctype)
+		}
+		if resp.Code != http.StatusCreated {
+			continue
+		}
+
+		user := &users.User{}
+		if err := json.NewDecoder(resp.Body).Decode(user); err != nil {
+			t.Fatalf("case %s: error decoding response body: %v", c.name, err)
+		}
+		if user.ID == 0 || user.UserName != "new" || user.FirstName != "First" || user.LastName != "Last" {
+			t.Errorf("case %s: incorrect user in response: %+v", c.name, user)
+		}
+		if strings.Contains(resp.Body.String(), "password") {
+			t.Errorf("case %s: the response must not include the password", c.name)
+		}
+
+		//the new user should be signed in
+		req, _ = http.NewRequest("GET", "/v1/users/me", nil)
+		req.Header.Set("Authorization", resp.Header().Get("Authorization"))
+		state := &SessionState{}
+		if _, err := sessions.GetState(req, ctx.SigningKey, ctx.SessionStore, state); err != nil {
+			t.Fatalf("case %s: error getting session state for new user: %v", c.name, err)
+		}
+		if state.User == nil || state.User.ID != user.ID {
+			t.Errorf("case %s: incorrect user in session state: expected ID %d but got %+v", c.name, user.ID, state.User)
+		}
+	}
+}
+
+func TestSpecificUserHandler(t *testing.T) {
+	cases := []struct {
+		name              string
+		hint              string
+		method            string
+		path              string
+		signedIn          bool
+		contentType       string
+		body              string
+		expectedStatus    int
+		expectedFirstName string
+	}{
+		{
+			"Get Me",
+			"`me` refers to the signed-in user",
+			"GET",
+			"/v1/users/me",
+			true,
+			"",
+			"",
+			http.StatusOK,
+			"Self",
+		},
+		{
+			"Get Other User",
+			"Signed-in users can get any user's profile",
+			"GET",
+			"/v1/users/2",
+			true,
+			"",
+			"",
+			http.StatusOK,
+			"Other",
+		},
+		{
+			"Not Signed In",
+			"Users must be signed in",
+			"GET",
+			"/v1/users/me",
+			false,
+			"",
+			"",
+			http.StatusUnauthorized,
+			"",
+		},
+		{
+			"User Not Found",
+			"Users that don't exist are not found",
+			"GET",
+			"/v1/users/99",
+			true,
+			"",
+			"",
+			http.StatusNotFound,
+			"",
+		},
+		{
+			"Invalid ID",
+			"User IDs must be numbers or `me`",
+			"GET",
+			"/v1/users/abc",
+			true,
+			"",
+			"",
+			http.StatusBadRequest,
+			"",
+		},
+		{
+			"Wrong Method",
+			"Only GET and PATCH are allowed",
+			"DELETE",
+			"/v1/users/me",
+			true,
+			"",
+			"",
+			http.StatusMethodNotAllowed,
+			"",
+		},
+		{
+			"Update Me",
+			"Users can update their own profile",
+			"PATCH",
+			"/v1/users/me",
+			true,
+			"application/json",
+			`{"firstName": "New", "lastName": "Name"}`,
+			http.StatusOK,
+			"New",
+		},
+		{
+			"Update Self By ID",
+			"Users can update their own profile by ID",
+			"PATCH",
+			"/v1/users/1",
+			true,
+			"application/json",
+			`{"firstName": "Newer", "lastName": "Name"}`,
+			http.StatusOK,
+			"Newer",
+		},
+		{
+			"Update Other User",
+			"Users can only update their own profile",
+			"PATCH",
+			"/v1/users/2",
+			true,
+			"application/json",
+			`{"firstName": "New", "lastName": "Name"}`,
+			http.StatusForbidden,
+			"",
+		},
+		{
+			"Update Not JSON",
+			"Updates must be JSON",
+			"PATCH",
+			"/v1/users/me",
+			true,
+			"text/plain",
+			`{"firstName": "New", "lastName": "Name"}`,
+			http.StatusUnsupportedMediaType,
+			"",
+		},
+		{
+			"Invalid Updates",
+			"Updates that fail validation are bad requests",
+			"PATCH",
+			"/v1/users/me",
+			true,
+			"application/json",
+			`{"firstName": "", "lastName": "Name"}`,
+			http.StatusBadRequest,
+			"",
+		},
+	}
+
+	for _, c := range cases {
+		self := &users.User{Email: "self@test.com", UserName: "self", FirstName: "Self"}
+		other := &users.User{Email: "other@test.com", UserName: "other", FirstName: "Other"}
+		ctx := newTestContext(self, other)
+		self.ID = 1
+
+		resp := httptest.NewRecorder()
+		req, _ := http.NewRequest(c.method, c.path, strings.NewReader(c.body))
+		req.Header.Set("Content-Type", c.contentType)
+		if c.signedIn {
+			req.Header.Set("Authorization", beginTestSession(t, ctx, self))
+		}
+		ctx.SpecificUserHandler(resp, req)
+		if resp.Code != c.expectedStatus {
+			t.Errorf("case %s: incorrect response status code: expected %d but got %d\nHINT: %s",
+				c.name, c.expectedStatus, resp.Code, c.hint)
+			continue
+		}
+		if resp.Code != http.StatusOK {
+			continue
+		}
+
+		user := &users.User{}
+		if err := json.NewDecoder(resp.Body).Decode(user); err != nil {
+			t.Fatalf("case %s: error decoding response body: %v", c.name, err)
+		}
+		if user.FirstName != c.expectedFirstName {
+			t.Errorf("case %s: incorrect first name: expected %q but got %q\nHINT: %s",
+				c.name, c.expectedFirstName, user.FirstName, c.hint)
+		}
+
+		//updates should be saved in the store and the session
+		if c.method == "PATCH" {
+			stored, _ := ctx.UserStore.GetByID(self.ID)
+			if stored.FirstName != c.expectedFirstName {
+				t.Errorf("case %s: update was not saved in the user store", c.name)
+			}
+			state := &SessionState{}
+			sessions.GetState(req, ctx.SigningKey, ctx.SessionStore, state)
+			if state.User == nil || state.User.FirstName != c.expectedFirstName {
+				t.Errorf("case %s: update was not saved in the session state", c.name)
+			}
+		}
+	}
+}
+
+//newTestUser returns a user with the password "password"
+func newTestUser(t *testing.T, email string, userName string) *users.User {
+	newUser := &users.NewUser{
+		Email:        email,
+		Password:     "password",
+		PasswordConf: "password",
+		UserName:     userName,
+	}
+	user, err := newUser.ToUser()
+	if err != nil {
+		t.Fatalf("error creating test user: %v", err)
+	}
+	return user
+}
+
+func TestSessionsHandler(t *testing.T) {
+	ctx := newTestContext(newTestUser(t, "user@test.com", "user"))
+
+	cases := []struct {
+		name           string
+		hint           string
+		method         string
+		contentType    string
+		body           string
+		expectedStatus int
+	}{
+		{
+			"Valid Credentials",
+			"Users with valid credentials should be signed in",
+			"POST",
+			"application/json",
+			`{"email": "user@test.com", "password": "password"}`,
+			http.StatusCreated,
+		},
+		{
+			"Wrong Password",
+			"Users with the wrong password must not be signed in",
+			"POST",
+			"application/json",
+			`{"email": "user@test.com", "password": "wrong password"}`,
+			http.StatusUnauthorized,
+		},
+		{
+			"Unknown Email",
+			"Unregistered emails must not be signed in",
+			"POST",
+			"application/json",
+			`{"email": "unknown@test.com", "password": "password"}`,
+			http.StatusUnauthorized,
+		},
+		{
+			"Wrong Method",
+			"Only POST is allowed",
+			"GET",
+			"",
+			"",
+			http.StatusMethodNotAllowed,
+		},
+		{
+			"Not JSON",
+			"Credentials must be JSON",
+			"POST",
+			"text/plain",
+			`{"email": "user@test.com", "password": "password"}`,
+			http.StatusUnsupportedMediaType,
+		},
+		{
+			"Invalid JSON",
+			"Request bodies that can't be decoded are bad requests",
+			"POST",
+			"application/json",
+			"{not json",
+			http.StatusBadRequest,
+		},
+	}
+
+	for _, c := range cases {
+		resp := httptest.NewRecorder()
+		req, _ := http.NewRequest(c.method, "/v1/sessions", strings.NewReader(c.body))
+		req.Header.Set("Content-Type", c.contentType)
+		ctx.SessionsHandler(resp, req)
+		if resp.Code != c.expectedStatus {
+			t.Errorf("case %s: incorrect response status code: expected %d but got %d\nHINT: %s",
+				c.name, c.expectedStatus, resp.Code, c.hint)
+			continue
+		}
+		auth := resp.Header().Get("Authorization")
+		if resp.Code != http.StatusCreated {
+			if len(auth) > 0 {
+				t.Errorf("case %s: no session should have begun, but got Authorization header %q", c.name, auth)
+			}
+			continue
+		}
+
+		user := &users.User{}
+		if err := json.NewDecoder(resp.Body).Decode(user); err != nil {
+			t.Fatalf("case %s: error decoding response body: %v", c.name, err)
+		}
+		if user.UserName != "user" {
+			t.Errorf("case %s: incorrect user in response: %+v", c.name, user)
+		}
+		req, _ = http.NewRequest("GET", "/v1/users/me", nil)
+		req.Header.Set("Authorization", auth)
+		state := &SessionState{}
+		if _, err := sessions.GetState(req, ctx.SigningKey, ctx.SessionStore, state); err != nil {
+			t.Errorf("case %s: error getting session state: %v", c.name, err)
+		}
+	}
+}
+
+func TestSessionsHandlerTiming(t *testing.T) {
+	ctx := newTestContext(newTestUser(t, "user@test.com", "user"))
+	signIn := func(email string) time.Duration {
+		resp := httptest.NewRecorder()
+		body := `{"email": "` + email + `", "password": "wrong password"}`
+		req, _ := http.NewRequest("POST", "/v1/sessions", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		start := time.Now()
+		ctx.SessionsHandler(resp, req)
+		return time.Since(start)
+	}
+
+	if len(dummyUser.PassHash) == 0 {
+		t.Fatalf("the dummy user must have a password hash, or comparing it returns at once")
+	}
+	registered := signIn("user@test.com")
+	unknown := signIn("unknown@test.com")
+	if unknown < registered/2 {
+		t.Errorf("signing in with an unknown email took %v, but with a registered email took %v: "+
+			"unknown emails must still compare a password hash", unknown, registered)
+	}
+}
+
+func TestSpecificSessionHandler(t *testing.T) {
+	cases := []struct {
+		name           string
+		hint           string
+		method         string
+		path           string
+		signedIn       bool
+		expectedStatus int
+	}{
+		{
+			"Sign Out",
+			"Deleting the current session should sign the user out",
+			"DELETE",
+			"/v1/sessions/mine",
+			true,
+			http.StatusOK,
+		},
+		{
+			"Other Session",
+			"Only the current session may be deleted",
+			"DELETE",
+			"/v1/sessions/other",
+			true,
+			http.StatusForbidden,
+		},
+		{
+			"Not Signed In",
+			"Users who aren't signed in have no session to end",
+			"DELETE",
+			"/v1/sessions/mine",
+			false,
+			http.StatusUnauthorized,
+		},
+		{
+			"Wrong Method",
+			"Only DELETE is allowed",
+			"GET",
+			"/v1/sessions/mine",
+			true,
+			http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, c := range cases {
+		ctx := newTestContext()
+		resp := httptest.NewRecorder()
+		req, _ := http.NewRequest(c.method, c.path, nil)
+		if c.signedIn {
+			req.Header.Set("Authorization", beginTestSession(t, ctx, &users.User{ID: 1, UserName: "user"}))
+		}
+		ctx.SpecificSessionHandler(resp, req)
+		if resp.Code != c.expectedStatus {
+			t.Errorf("case %s: incorrect response status code: expected %d but got %d\nHINT: %s",
+				c.name, c.expectedStatus, resp.Code, c.hint)
+			continue
+		}
+
+		state := &SessionState{}
+		_, err := sessions.GetState(req, ctx.SigningKey, ctx.SessionStore, state)
+		if c.expectedStatus == http.StatusOK && err != sessions.ErrStateNotFound {
+			t.Errorf("case %s: expected the session to be ended, but getting it returned %v", c.name, err)
+		}
+		if c.signedIn && c.expectedStatus != http.StatusOK && err != nil {
+			t.Errorf("case %s: expected the session to remain, but getting it returned %v", c.name, err)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+//ErrNoHealthyBackends is returned from Balancer.Acquire()
+//when every backend has been ejected
+var ErrNoHealthyBackends = errors.New("no healthy backends")
+
+//Default health check settings used by NewBalancer
+const (
+	//DefaultHealthInterval is how often backends are probed
+	DefaultHealthInterval = 10 * time.Second
+	//DefaultHealthTimeout is how long a probe may take
+	DefaultHealthTimeout = 2 * time.Second
+)
+
+//Strategy is how a Balancer chooses among its healthy backends
+type Strategy string
+
+//Strategy values
+const (
+	//RoundRobin takes turns among the backends
+	RoundRobin Strategy = "roundrobin"
+	//LeastConnections chooses the backend with the fewest requests
+	//in flight, taking turns among those tied for the fewest
+	LeastConnections Strategy = "leastconn"
+)
+
+//ParseStrategy parses a Strategy, returning RoundRobin for an empty string
+func ParseStrategy(s string) (Strategy, error) {
+	switch strategy := Strategy(strings.ToLower(strings.TrimSpace(s))); strategy {
+	case "":
+		return RoundRobin, nil
+	case RoundRobin, LeastConnections:
+		return strategy, nil
+	default:
+		return "", fmt.Errorf("invalid load balancing strategy %q: must be %s or %s", s, RoundRobin, LeastConnections)
+	}
+}
+
+//Backend is one instance of a service
+type Backend struct {
+	//URL is the backend's base URL
+	URL *url.URL
+
+	healthy bool
+	active  int
+}
+
+//Balancer spreads requests for a service across its backend instances.
+//Backends that fail health checks are ejected, and receive no requests
+//until they pass again.
+type Balancer struct {
+	//Strategy is how backends are chosen
+	Strategy Strategy
+	//HealthPath is the path, relative to each backend's URL, that
+	//health checks request. Backends are healthy if it responds with
+	//a 2xx status code. If empty, backends are never checked.
+	HealthPath string
+	//HealthInterval is how often backends are checked
+	HealthInterval time.Duration
+	//HealthTimeout is how long a backend may take to respond to a check
+	HealthTimeout time.Duration
+	//Client sends health check requests. If nil, http.DefaultClient is used.
+	Client *http.Client
+
+	mx       sync.Mutex
+	backends []*Backend
+	next     int
+}
+
+//NewBalancer constructs a new Balancer of the backends at `targets`,
+//all of which start healthy, using the RoundRobin strategy
+func NewBalancer(targets []*url.URL) *Balancer {
+	b := &Balancer{
+		Strategy:       RoundRobin,
+		HealthInterval: DefaultHealthInterval,
+		HealthTimeout:  DefaultHealthTimeout,
+	}
+	for _, target := range targets {
+		b.backends = append(b.backends, &Backend{URL: target, healthy: true})
+	}
+	return b
+}
+
+//Acquire chooses a healthy backend for a request, and counts the
+//request as in flight until it is passed to Release()
+func (b *Balancer) Acquire() (*Backend, error) {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	var chosen *Backend
+	chosenIndex := 0
+	//starts at the next backend in turn, so that among
+	//equally good backends each takes a turn
+	for i := 0; i < len(b.backends); i++ {
+		index := (b.next + i) % len(b.backends)
+		backend := b.backends[index]
+		if !backend.healthy {
+			continue
+		}
+		if chosen == nil || (b.Strategy == LeastConnections && backend.active < chosen.active) {
+			chosen, chosenIndex = backend, index
+		}
+		if b.Strategy != LeastConnections {
+			break
+		}
+	}
+	if chosen == nil {
+		return nil, ErrNoHealthyBackends
+	}
+	b.next = chosenIndex + 1
+	chosen.active++
+	return chosen, nil
+}
+
+//Release counts a request acquired from `backend` as finished
+func (b *Balancer) Release(backend *Backend) {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	backend.active--
+}
+
+//Healthy returns the backends that are currently healthy
+func (b *Balancer) Healthy() []*Backend {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	var healthy []*Backend
+	for _, backend := range b.backends {
+		if backend.healthy {
+			healthy = append(healthy, backend)
+		}
+	}
+	return healthy
+}
+
+//CheckHealth checks every backend at once, ejecting those that
+//fail and re-admitting those that pass after having failed
+func (b *Balancer) CheckHealth(ctx context.Context) {
+	if len(b.HealthPath) == 0 {
+		return
+	}
+	var wg sync.WaitGroup
+	for _, backend := range b.backends {
+		wg.Add(1)
+		go func(backend *Backend) {
+			defer wg.Done()
+			healthy := b.probe(ctx, backend)
+			b.mx.Lock()
+			backend.healthy = healthy
+			b.mx.Unlock()
+		}(backend)
+	}
+	wg.Wait()
+}
+
+//StartHealthChecks checks the backends' health every HealthInterval,
+//until `ctx` is done
+func (b *Balancer) StartHealthChecks(ctx context.Context) {
+	if len(b.HealthPath) == 0 || b.HealthInterval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(b.HealthInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				b.CheckHealth(ctx)
+			}
+		}
+	}()
+}
+
+//probe reports whether `backend` responds to a health check
+func (b *Balancer) probe(ctx context.Context, backend *Backend) bool {
+	if b.HealthTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, b.HealthTimeout)
+		defer cancel()
+	}
+	healthURL := *backend.URL
+	healthURL.Path = singleJoiningSlash(healthURL.Path, b.HealthPath)
+	req, err := http.NewRequestWithContext(ctx, "GET", healthURL.String(), nil)
+	if err != nil {
+		return false
+	}
+	client := b.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return resp.StatusCode >= 200 && resp.StatusCode < 300
+}
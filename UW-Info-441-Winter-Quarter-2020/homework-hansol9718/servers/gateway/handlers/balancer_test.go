@@ -0,0 +1,251 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+//testBackends are backend servers whose health can be switched
+type testBackends struct {
+	servers []*httptest.Server
+	healthy []int32
+	targets []*url.URL
+}
+
+//newTestBackends starts `n` healthy backends named "0", "1", ...
+//which respond to /health, and to every other path with their name
+func newTestBackends(n int) *testBackends {
+	tb := &testBackends{healthy: make([]int32, n)}
+	for i := 0; i < n; i++ {
+		i := i
+		tb.healthy[i] = 1
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/health" && atomic.LoadInt32(&tb.healthy[i]) == 0 {
+				http.Error(w, "unhealthy", http.StatusServiceUnavailable)
+				return
+			}
+			w.Header().Set("X-Backend", string(rune('0'+i)))
+		}))
+		target, _ := url.Parse(server.URL)
+		tb.servers = append(tb.servers, server)
+		tb.targets = append(tb.targets, target)
+	}
+	return tb
+}
+
+func (tb *testBackends) setHealthy(i int, healthy bool) {
+	if healthy {
+		atomic.StoreInt32(&tb.healthy[i], 1)
+	} else {
+		atomic.StoreInt32(&tb.healthy[i], 0)
+	}
+}
+
+func (tb *testBackends) Close() {
+	for _, server := range tb.servers {
+		server.Close()
+	}
+}
+
+//countBackends sends `n` requests through `handler`, and returns
+//how many were handled by each backend, and by none
+func countBackends(handler http.Handler, n int) map[string]int {
+	counts := map[string]int{}
+	for i := 0; i < n; i++ {
+		resp := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/v1/messages/1", nil)
+		handler.ServeHTTP(resp, req)
+		backend := resp.Header().Get("X-Backend")
+		if len(backend) == 0 {
+			backend = http.StatusText(resp.Code)
+		}
+		counts[backend]++
+	}
+	return counts
+}
+
+func TestBalancerRoundRobin(t *testing.T) {
+	backends := newTestBackends(3)
+	defer backends.Close()
+	balancer := NewBalancer(backends.targets)
+	proxy := newTestContext().NewServiceProxy(balancer)
+
+	counts := countBackends(proxy, 6)
+	if !reflect.DeepEqual(counts, map[string]int{"0": 2, "1": 2, "2": 2}) {
+		t.Errorf("expected requests to be spread evenly over all backends, but got %v", counts)
+	}
+}
+
+func TestBalancerLeastConnections(t *testing.T) {
+	backends := newTestBackends(3)
+	defer backends.Close()
+	balancer := NewBalancer(backends.targets)
+	balancer.Strategy = LeastConnections
+
+	acquire := func() int {
+		backend, err := balancer.Acquire()
+		if err != nil {
+			t.Fatalf("unexpected error acquiring backend: %v", err)
+		}
+		for i, target := range backends.targets {
+			if backend.URL == target {
+				return i
+			}
+		}
+		return -1
+	}
+	release := func(i int) {
+		for _, backend := range balancer.backends {
+			if backend.URL == backends.targets[i] {
+				balancer.Release(backend)
+			}
+		}
+	}
+
+	//with no requests in flight, backends take turns
+	first, second, third := acquire(), acquire(), acquire()
+	if first != 0 || second != 1 || third != 2 {
+		t.Fatalf("expected backends with no requests in flight to take turns, but got %d, %d, %d", first, second, third)
+	}
+	//backend 1 finishes its request, so has the fewest in flight
+	release(1)
+	if next := acquire(); next != 1 {
+		t.Errorf("expected the backend with the fewest requests in flight (1) to be chosen, but got %d", next)
+	}
+	//backends 0 and 2 tie, and 2 is next in turn after 1
+	release(0)
+	release(2)
+	if next := acquire(); next != 2 {
+		t.Errorf("expected the next of the backends tied for the fewest requests in flight (2) to be chosen, but got %d", next)
+	}
+}
+
+func TestBalancerHealthChecks(t *testing.T) {
+	backends := newTestBackends(3)
+	defer backends.Close()
+	balancer := NewBalancer(backends.targets)
+	balancer.HealthPath = "/health"
+	proxy := newTestContext().NewServiceProxy(balancer)
+
+	cases := []struct {
+		name           string
+		hint           string
+		healthy        []bool
+		expectedCounts map[string]int
+	}{
+		{
+			"All Healthy",
+			"Healthy backends should all receive requests",
+			[]bool{true, true, true},
+			map[string]int{"0": 2, "1": 2, "2": 2},
+		},
+		{
+			"One Failing",
+			"Backends that fail health checks should be ejected",
+			[]bool{true, false, true},
+			map[string]int{"0": 3, "2": 3},
+		},
+		{
+			"Two Failing",
+			"Backends that fail health checks should be ejected",
+			[]bool{false, false, true},
+			map[string]int{"2": 6},
+		},
+		{
+			"Recovered",
+			"Backends that pass health checks again should be re-admitted",
+			[]bool{true, false, true},
+			map[string]int{"0": 3, "2": 3},
+		},
+		{
+			"All Failing",
+			"With no healthy backends, the service is unavailable",
+			[]bool{false, false, false},
+			map[string]int{http.StatusText(http.StatusServiceUnavailable): 6},
+		},
+		{
+			"All Recovered",
+			"Backends that pass health checks again should be re-admitted",
+			[]bool{true, true, true},
+			map[string]int{"0": 2, "1": 2, "2": 2},
+		},
+	}
+
+	for _, c := range cases {
+		for i, healthy := range c.healthy {
+			backends.setHealthy(i, healthy)
+		}
+		balancer.CheckHealth(context.Background())
+		if counts := countBackends(proxy, 6); !reflect.DeepEqual(counts, c.expectedCounts) {
+			t.Errorf("case %s: incorrect requests per backend: expected %v but got %v\nHINT: %s",
+				c.name, c.expectedCounts, counts, c.hint)
+		}
+	}
+}
+
+func TestBalancerStartHealthChecks(t *testing.T) {
+	backends := newTestBackends(2)
+	defer backends.Close()
+	balancer := NewBalancer(backends.targets)
+	balancer.HealthPath = "/health"
+	balancer.HealthInterval = 10 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	balancer.StartHealthChecks(ctx)
+
+	waitFor := func(expected int) {
+		deadline := time.Now().Add(2 * time.Second)
+		for len(balancer.Healthy()) != expected && time.Now().Before(deadline) {
+			time.Sleep(5 * time.Millisecond)
+		}
+		if healthy := len(balancer.Healthy()); healthy != expected {
+			t.Fatalf("expected %d healthy backends but got %d", expected, healthy)
+		}
+	}
+	backends.setHealthy(0, false)
+	waitFor(1)
+	backends.setHealthy(0, true)
+	waitFor(2)
+}
+
+func TestBalancerBackendDown(t *testing.T) {
+	backends := newTestBackends(1)
+	backends.Close()
+	proxy := newTestContext().NewServiceProxy(NewBalancer(backends.targets))
+
+	resp := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/v1/messages/1", nil)
+	proxy.ServeHTTP(resp, req)
+	if resp.Code != http.StatusBadGateway {
+		t.Errorf("incorrect response status code: expected %d but got %d", http.StatusBadGateway, resp.Code)
+	}
+	if ctype := resp.Header().Get("Content-Type"); ctype != "application/json" {
+		t.Errorf("incorrect `Content-Type` header value: expected `application/json` but got `%s`", ctype)
+	}
+}
+
+func TestParseStrategy(t *testing.T) {
+	cases := []struct {
+		input       string
+		expected    Strategy
+		expectError bool
+	}{
+		{"", RoundRobin, false},
+		{"roundrobin", RoundRobin, false},
+		{" LeastConn ", LeastConnections, false},
+		{"random", "", true},
+	}
+	for _, c := range cases {
+		strategy, err := ParseStrategy(c.input)
+		if err != nil != c.expectError || strategy != c.expected {
+			t.Errorf("case %q: expected %q (error %t) but got %q (error %v)", c.input, c.expected, c.expectError, strategy, err)
+		}
+	}
+}
@@ -1,8 +1,12 @@
 package handlers
 
 import (
+	"io"
+	"time"
+
 	"github.com/UW-Info-441-Winter-Quarter-2020/homework-hansol9718/servers/gateway/models/users"
 	"github.com/UW-Info-441-Winter-Quarter-2020/homework-hansol9718/servers/gateway/sessions"
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-hansol9718/servers/gateway/summary"
 )
 
 //TODO: define a handler context struct that
@@ -16,4 +20,32 @@ type Context struct {
 	SigningKey   string
 	SessionStore sessions.Store
 	UserStore    users.Store
+	Summarizer   *summary.Summarizer
+	//SummaryTimeout is how long SummaryHandler waits for a
+	//page's summary. If zero, DefaultSummaryTimeout is used.
+	SummaryTimeout time.Duration
+	//MaxBatchSize is the most URLs SummariesHandler summarizes
+	//in one request. If zero, DefaultMaxBatchSize is used.
+	MaxBatchSize int
+	//BatchTimeout is how long SummariesHandler waits for a
+	//batch's summaries. If zero, DefaultBatchTimeout is used.
+	BatchTimeout time.Duration
+	//ImageProxy, if set, serves the preview images of the
+	//summaries the handlers respond with, instead of clients
+	//loading them from the pages' hosts themselves
+	ImageProxy *ImageProxy
+}
+
+//Close closes the session and user stores, if they hold
+//connections that need closing, returning the first error
+func (ctx *Context) Close() error {
+	var firstErr error
+	for _, store := range []interface{}{ctx.SessionStore, ctx.UserStore} {
+		if closer, ok := store.(io.Closer); ok {
+			if err := closer.Close(); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
 }
@@ -1,12 +1,79 @@
 package handlers
 
-/* TODO: implement a CORS middleware handler, as described
-in https://drstearns.github.io/tutorials/cors/ that responds
-with the following headers to all requests:
-
-  Access-Control-Allow-Origin: *
-  Access-Control-Allow-Methods: GET, PUT, POST, PATCH, DELETE
-  Access-Control-Allow-Headers: Content-Type, Authorization
-  Access-Control-Expose-Headers: Authorization
-  Access-Control-Max-Age: 600
-*/
+import (
+	"net/http"
+	"strings"
+)
+
+//CORS header values, as described in
+//https://drstearns.github.io/tutorials/cors/
+const (
+	corsAllowMethods  = "GET, PUT, POST, PATCH, DELETE"
+	corsAllowHeaders  = "Content-Type, Authorization"
+	corsExposeHeaders = "Authorization"
+	corsMaxAge        = "600"
+)
+
+//anyOrigin is the allowed origin that allows every origin
+const anyOrigin = "*"
+
+//CORS is a middleware handler that lets browser clients on
+//other origins call the wrapped handler, by adding the
+//Cross-Origin Resource Sharing headers to every response
+//and answering preflight requests itself
+type CORS struct {
+	//Handler is the wrapped handler
+	Handler http.Handler
+	//AllowedOrigins are the origins, like "https://example.com",
+	//that may call the handler. If empty, or it includes "*",
+	//every origin may.
+	AllowedOrigins []string
+}
+
+//NewCORS constructs a new CORS middleware handler wrapping `handler`
+//that allows `allowedOrigins`, or every origin if none are given
+func NewCORS(handler http.Handler, allowedOrigins ...string) *CORS {
+	return &CORS{Handler: handler, AllowedOrigins: allowedOrigins}
+}
+
+//ServeHTTP implements the http.Handler interface. Preflight
+//OPTIONS requests are answered with 200 and not passed on to
+//the wrapped handler.
+func (c *CORS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if origin, ok := c.allowOrigin(w, r); ok {
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Set("Access-Control-Allow-Methods", corsAllowMethods)
+		w.Header().Set("Access-Control-Allow-Headers", corsAllowHeaders)
+		w.Header().Set("Access-Control-Expose-Headers", corsExposeHeaders)
+		w.Header().Set("Access-Control-Max-Age", corsMaxAge)
+	}
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	c.Handler.ServeHTTP(w, r)
+}
+
+//allowOrigin returns the Access-Control-Allow-Origin header value
+//for `r`, and whether its origin is allowed at all. When only some
+//origins are allowed, the response depends on the request's Origin
+//header, so caches are told so with a Vary header.
+func (c *CORS) allowOrigin(w http.ResponseWriter, r *http.Request) (string, bool) {
+	if len(c.AllowedOrigins) == 0 {
+		return anyOrigin, true
+	}
+	for _, allowed := range c.AllowedOrigins {
+		if allowed == anyOrigin {
+			return anyOrigin, true
+		}
+	}
+
+	w.Header().Add("Vary", "Origin")
+	origin := r.Header.Get("Origin")
+	for _, allowed := range c.AllowedOrigins {
+		if len(origin) > 0 && strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			return origin, true
+		}
+	}
+	return "", false
+}
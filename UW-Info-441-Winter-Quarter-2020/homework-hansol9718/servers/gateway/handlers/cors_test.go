@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCORS(t *testing.T) {
+	cases := []struct {
+		name                string
+		hint                string
+		allowedOrigins      []string
+		method              string
+		origin              string
+		expectedStatus      int
+		expectedAllowOrigin string
+		expectedVary        string
+		expectedCalled      bool
+	}{
+		{
+			"Any Origin",
+			"With no allowed origins, every origin should be allowed",
+			nil,
+			"GET",
+			"https://example.com",
+			http.StatusTeapot,
+			"*",
+			"",
+			true,
+		},
+		{
+			"Wildcard Origin",
+			"Allowing `*` should allow every origin",
+			[]string{"https://client.test", "*"},
+			"GET",
+			"https://example.com",
+			http.StatusTeapot,
+			"*",
+			"",
+			true,
+		},
+		{
+			"Allowed Origin",
+			"Allowed origins should be echoed back, with Vary: Origin",
+			[]string{"https://client.test", "https://other.test"},
+			"POST",
+			"https://other.test",
+			http.StatusTeapot,
+			"https://other.test",
+			"Origin",
+			true,
+		},
+		{
+			"Disallowed Origin",
+			"Origins that aren't allowed should get no Access-Control-Allow-Origin header",
+			[]string{"https://client.test"},
+			"GET",
+			"https://evil.test",
+			http.StatusTeapot,
+			"",
+			"Origin",
+			true,
+		},
+		{
+			"No Origin",
+			"Same-origin requests have no Origin header, and should still be handled",
+			[]string{"https://client.test"},
+			"GET",
+			"",
+			http.StatusTeapot,
+			"",
+			"Origin",
+			true,
+		},
+		{
+			"Preflight",
+			"Preflight requests should be answered with 200 without calling the wrapped handler",
+			[]string{"https://client.test"},
+			"OPTIONS",
+			"https://client.test",
+			http.StatusOK,
+			"https://client.test",
+			"Origin",
+			false,
+		},
+		{
+			"Preflight Any Origin",
+			"Preflight requests should be answered with 200 without calling the wrapped handler",
+			nil,
+			"OPTIONS",
+			"https://example.com",
+			http.StatusOK,
+			"*",
+			"",
+			false,
+		},
+	}
+
+	for _, c := range cases {
+		called := false
+		handler := NewCORS(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			called = true
+			w.WriteHeader(http.StatusTeapot)
+		}), c.allowedOrigins...)
+
+		resp := httptest.NewRecorder()
+		req, _ := http.NewRequest(c.method, "/v1/users/me", nil)
+		if len(c.origin) > 0 {
+			req.Header.Set("Origin", c.origin)
+		}
+		handler.ServeHTTP(resp, req)
+
+		if resp.Code != c.expectedStatus {
+			t.Errorf("case %s: incorrect response status code: expected %d but got %d\nHINT: %s",
+				c.name, c.expectedStatus, resp.Code, c.hint)
+		}
+		if called != c.expectedCalled {
+			t.Errorf("case %s: expected the wrapped handler to be called to be %t but got %t\nHINT: %s",
+				c.name, c.expectedCalled, called, c.hint)
+		}
+		if allowOrigin := resp.Header().Get("Access-Control-Allow-Origin"); allowOrigin != c.expectedAllowOrigin {
+			t.Errorf("case %s: incorrect Access-Control-Allow-Origin: expected %q but got %q\nHINT: %s",
+				c.name, c.expectedAllowOrigin, allowOrigin, c.hint)
+		}
+		if vary := resp.Header().Get("Vary"); vary != c.expectedVary {
+			t.Errorf("case %s: incorrect Vary: expected %q but got %q\nHINT: %s",
+				c.name, c.expectedVary, vary, c.hint)
+		}
+
+		expected := map[string]string{
+			"Access-Control-Allow-Methods":  "GET, PUT, POST, PATCH, DELETE",
+			"Access-Control-Allow-Headers":  "Content-Type, Authorization",
+			"Access-Control-Expose-Headers": "Authorization",
+			"Access-Control-Max-Age":        "600",
+		}
+		for name, value := range expected {
+			if len(c.expectedAllowOrigin) == 0 {
+				value = ""
+			}
+			if actual := resp.Header().Get(name); actual != value {
+				t.Errorf("case %s: incorrect %s: expected %q but got %q", c.name, name, value, actual)
+			}
+		}
+	}
+}
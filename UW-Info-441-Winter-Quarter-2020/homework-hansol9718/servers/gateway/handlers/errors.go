@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+//Error codes used in ErrorResponse
+const (
+	//ErrCodeBadRequest means the request was invalid
+	ErrCodeBadRequest = "bad_request"
+	//ErrCodeUnauthorized means the request has no valid session
+	ErrCodeUnauthorized = "unauthorized"
+	//ErrCodeForbidden means the signed-in user may not make the request
+	ErrCodeForbidden = "forbidden"
+	//ErrCodeNotFound means the requested resource doesn't exist
+	ErrCodeNotFound = "not_found"
+	//ErrCodeMethodNotAllowed means the resource doesn't support
+	//the request method
+	ErrCodeMethodNotAllowed = "method_not_allowed"
+	//ErrCodeInternal means the gateway failed to handle the request
+	ErrCodeInternal = "internal_error"
+	//ErrCodeInvalidURL means the URL to fetch was missing,
+	//couldn't be parsed, or wasn't an http or https URL
+	ErrCodeInvalidURL = "invalid_url"
+	//ErrCodeForbiddenURL means the URL to fetch
+	//is on a network that may not be fetched from
+	ErrCodeForbiddenURL = "forbidden_url"
+	//ErrCodeUnsupportedMediaType means the request body, or the
+	//fetched resource, was not of a type that can be handled
+	ErrCodeUnsupportedMediaType = "unsupported_media_type"
+	//ErrCodeUpstreamError means the server the resource was fetched
+	//from couldn't be reached, or responded with an error
+	ErrCodeUpstreamError = "upstream_error"
+	//ErrCodeUpstreamTimeout means the server the resource
+	//was fetched from took too long to respond
+	ErrCodeUpstreamTimeout = "upstream_timeout"
+	//ErrCodeUpstreamTooLarge means the fetched resource
+	//was larger than the gateway will read
+	ErrCodeUpstreamTooLarge = "upstream_too_large"
+	//ErrCodeServiceUnavailable means no instance
+	//of the requested service is available
+	ErrCodeServiceUnavailable = "service_unavailable"
+	//ErrCodeTooManyRedirects means fetching the resource
+	//was redirected too many times
+	ErrCodeTooManyRedirects = "too_many_redirects"
+)
+
+//ErrorResponse is the JSON-encoded body of error responses
+type ErrorResponse struct {
+	//Code is a machine-readable error code, one of the ErrCode constants
+	Code string `json:"code"`
+	//Message is a human-readable description of the error
+	Message string `json:"message"`
+	//UpstreamStatus is the status code of the response from the
+	//server a resource was fetched from, if that is what failed
+	UpstreamStatus int `json:"upstreamStatus,omitempty"`
+}
+
+//writeError responds with `status` and `errResp` encoded as JSON.
+//Handlers should return after calling it.
+func writeError(w http.ResponseWriter, status int, errResp *ErrorResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(errResp)
+}
@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-hansol9718/servers/gateway/sessions"
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-hansol9718/servers/gateway/summary"
+)
+
+//ImageProxyPath is the path ImageProxy is served at,
+//which the URLs it signs point to
+const ImageProxyPath = "/v1/imageproxy"
+
+//Defaults of an ImageProxy
+const (
+	//DefaultMaxImageBytes is the largest image that should be
+	//proxied, which is the MaxBytes of the proxy's Fetcher
+	DefaultMaxImageBytes = 5 << 20
+	//DefaultImageMaxAge is how long clients may cache proxied images
+	DefaultImageMaxAge = 24 * time.Hour
+)
+
+//imageCSP is the Content-Security-Policy proxied images are served
+//with, so an SVG image opened directly can't run scripts on our origin
+const imageCSP = "default-src 'none'; style-src 'unsafe-inline'; sandbox"
+
+//ImageProxy is an http.Handler that serves images fetched from other
+//hosts, so clients can show a page's preview images without loading
+//them from third-party hosts themselves. The image's URL is given in
+//the `url` query string parameter, and must be signed with the proxy's
+//SigningKey in the `sig` parameter, so the proxy only fetches images
+//the gateway itself handed out and can't be used as an open relay.
+type ImageProxy struct {
+	//Origin is the scheme and host clients reach the gateway at,
+	//like "https://api.example.com", which the proxy's URLs point to.
+	//It is configured rather than taken from requests, whose Host
+	//header is whatever the client chose to send.
+	Origin string
+	//Fetcher fetches the images, refusing forbidden addresses.
+	//Images larger than its MaxBytes are not served.
+	Fetcher *summary.Fetcher
+	//SigningKey is the HMAC signing key of the proxy's URLs
+	SigningKey string
+	//MaxAge is how long clients may cache proxied images
+	MaxAge time.Duration
+}
+
+//NewImageProxy constructs a new ImageProxy that fetches images with
+//`fetcher`, and whose URLs point to `origin` and are signed with `signingKey`
+func NewImageProxy(fetcher *summary.Fetcher, origin string, signingKey string) *ImageProxy {
+	return &ImageProxy{
+		Origin:     strings.TrimSuffix(origin, "/"),
+		Fetcher:    fetcher,
+		SigningKey: signingKey,
+		MaxAge:     DefaultImageMaxAge,
+	}
+}
+
+//ServeHTTP implements the http.Handler interface. Only http and https
+//URLs of images are served; anything else the URL responds with is an
+//unsupported media type. Conditional requests are passed on to the
+//image's server, so clients can revalidate their cached copies.
+func (p *ImageProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+	imageURL := r.FormValue("url")
+	if len(imageURL) == 0 {
+		writeError(w, http.StatusBadRequest, &ErrorResponse{
+			Code:    ErrCodeInvalidURL,
+			Message: "the url query string parameter is required",
+		})
+		return
+	}
+	if !sessions.ValidSignature(p.SigningKey, imageURL, r.FormValue("sig")) {
+		writeError(w, http.StatusForbidden, &ErrorResponse{
+			Code:    ErrCodeForbidden,
+			Message: "the url's signature is invalid",
+		})
+		return
+	}
+
+	header := http.Header{}
+	header.Set("Accept", "image/*")
+	for _, name := range []string{"If-None-Match", "If-Modified-Since"} {
+		if value := r.Header.Get(name); len(value) > 0 {
+			header.Set(name, value)
+		}
+	}
+	resp, err := p.Fetcher.Fetch(r.Context(), imageURL, header)
+	if err != nil {
+		status, errResp := fetchError(err, "image")
+		writeError(w, status, errResp)
+		return
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotModified:
+		p.setCacheHeaders(w, resp.Header)
+		w.WriteHeader(http.StatusNotModified)
+		return
+	default:
+		status, errResp := fetchError(&summary.StatusError{StatusCode: resp.StatusCode}, "image")
+		writeError(w, status, errResp)
+		return
+	}
+	mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil || !strings.HasPrefix(mediaType, "image/") {
+		writeError(w, http.StatusUnsupportedMediaType, &ErrorResponse{
+			Code:    ErrCodeUnsupportedMediaType,
+			Message: "the url is not an image",
+		})
+		return
+	}
+	if p.Fetcher.MaxBytes > 0 && resp.ContentLength > p.Fetcher.MaxBytes {
+		status, errResp := fetchError(summary.ErrTooLarge, "image")
+		writeError(w, status, errResp)
+		return
+	}
+	//the image is read in full before responding,
+	//so errors reading it can still be reported
+	image, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		status, errResp := fetchError(err, "image")
+		writeError(w, status, errResp)
+		return
+	}
+
+	w.Header().Set("Content-Type", mediaType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(image)))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", imageCSP)
+	p.setCacheHeaders(w, resp.Header)
+	w.WriteHeader(http.StatusOK)
+	w.Write(image)
+}
+
+//setCacheHeaders sets the caching headers of a proxied image's
+//response, keeping the validators of the image's server's response
+//in `upstream` so clients can make conditional requests with them
+func (p *ImageProxy) setCacheHeaders(w http.ResponseWriter, upstream http.Header) {
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(p.MaxAge.Seconds())))
+	for _, name := range []string{"ETag", "Last-Modified"} {
+		if value := upstream.Get(name); len(value) > 0 {
+			w.Header().Set(name, value)
+		}
+	}
+}
+
+//ProxyURL returns the signed URL at the proxy's Origin that serves
+//`imageURL` through the proxy. URLs other than http and https URLs,
+//such as data URLs, are returned as they are.
+func (p *ImageProxy) ProxyURL(imageURL string) string {
+	u, err := url.Parse(imageURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return imageURL
+	}
+	query := url.Values{}
+	query.Set("url", imageURL)
+	query.Set("sig", sessions.Sign(p.SigningKey, imageURL))
+	return p.Origin + ImageProxyPath + "?" + query.Encode()
+}
+
+//RewriteSummary returns a copy of `page` whose preview images,
+//including its icons and its embed's thumbnail, are served through
+//the proxy. The original summary, which may be shared
+//with the summary cache, is left as it is.
+func (p *ImageProxy) RewriteSummary(page *summary.PageSummary) *summary.PageSummary {
+	rewritten := *page
+	rewritten.Icon = p.rewriteImage(page.Icon)
+	rewritten.Icons = p.rewriteImages(page.Icons)
+	rewritten.Images = p.rewriteImages(page.Images)
+	if page.Embed != nil && page.Embed.Thumbnail != nil {
+		embed := *page.Embed
+		embed.Thumbnail = p.rewriteImage(page.Embed.Thumbnail)
+		rewritten.Embed = &embed
+	}
+	return &rewritten
+}
+
+//rewriteImages returns copies of `images` served through the proxy
+func (p *ImageProxy) rewriteImages(images []*summary.PreviewImage) []*summary.PreviewImage {
+	if images == nil {
+		return nil
+	}
+	rewritten := make([]*summary.PreviewImage, len(images))
+	for i, image := range images {
+		rewritten[i] = p.rewriteImage(image)
+	}
+	return rewritten
+}
+
+//rewriteImage returns a copy of `image` served through the proxy
+func (p *ImageProxy) rewriteImage(image *summary.PreviewImage) *summary.PreviewImage {
+	if image == nil {
+		return nil
+	}
+	rewritten := *image
+	if len(image.URL) > 0 {
+		rewritten.URL = p.ProxyURL(image.URL)
+	}
+	if len(image.SecureURL) > 0 {
+		rewritten.SecureURL = p.ProxyURL(image.SecureURL)
+	}
+	return &rewritten
+}
+
+//proxyImages returns `page` with its preview images served through
+//the Context's ImageProxy, or `page` itself if it has none
+func (ctx *Context) proxyImages(page *summary.PageSummary) *summary.PageSummary {
+	if ctx.ImageProxy == nil {
+		return page
+	}
+	return ctx.ImageProxy.RewriteSummary(page)
+}
@@ -0,0 +1,272 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-hansol9718/servers/gateway/sessions"
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-hansol9718/servers/gateway/summary"
+)
+
+//png is the start of a PNG image
+var png = []byte("\x89PNG\r\n\x1a\n")
+
+//testImageProxy returns an ImageProxy that may fetch
+//from the loopback network test servers are on
+func testImageProxy() *ImageProxy {
+	fetcher := summary.NewFetcher()
+	fetcher.Allow, _ = summary.ParseNetworks("127.0.0.0/8, ::1")
+	fetcher.MaxBytes = 1024
+	return NewImageProxy(fetcher, "https://api.test.com/", "test key")
+}
+
+func TestImageProxy(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/image.png":
+			w.Header().Set("ETag", `"v1"`)
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("Content-Type", "image/png")
+			w.Write(png)
+		case "/page.html":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte("<html></html>"))
+		case "/large.png":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write(make([]byte, 2048))
+		case "/chunked.png":
+			w.Header().Set("Content-Type", "image/png")
+			for i := 0; i < 4; i++ {
+				w.Write(make([]byte, 512))
+				w.(http.Flusher).Flush()
+			}
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	proxy := testImageProxy()
+	signed := func(imageURL string) string {
+		return "url=" + url.QueryEscape(imageURL) + "&sig=" + url.QueryEscape(sessions.Sign("test key", imageURL))
+	}
+
+	cases := []struct {
+		name           string
+		hint           string
+		method         string
+		query          string
+		header         map[string]string
+		expectedStatus int
+		expectedCode   string
+	}{
+		{
+			"Image",
+			"Images at signed URLs should be served",
+			"GET",
+			signed(server.URL + "/image.png"),
+			nil,
+			http.StatusOK,
+			"",
+		},
+		{
+			"Conditional Request",
+			"Conditional requests should be passed on to the image's server",
+			"GET",
+			signed(server.URL + "/image.png"),
+			map[string]string{"If-None-Match": `"v1"`},
+			http.StatusNotModified,
+			"",
+		},
+		{
+			"Wrong Method",
+			"Only GET requests are allowed",
+			"POST",
+			signed(server.URL + "/image.png"),
+			nil,
+			http.StatusMethodNotAllowed,
+			ErrCodeMethodNotAllowed,
+		},
+		{
+			"Missing URL",
+			"The url query string parameter is required",
+			"GET",
+			"",
+			nil,
+			http.StatusBadRequest,
+			ErrCodeInvalidURL,
+		},
+		{
+			"Unsigned URL",
+			"URLs without a signature must not be fetched, or the proxy is an open relay",
+			"GET",
+			"url=" + url.QueryEscape(server.URL+"/image.png"),
+			nil,
+			http.StatusForbidden,
+			ErrCodeForbidden,
+		},
+		{
+			"Wrong Signature",
+			"A URL's signature isn't valid for any other URL",
+			"GET",
+			"url=" + url.QueryEscape(server.URL+"/page.html") + "&sig=" + url.QueryEscape(sessions.Sign("test key", server.URL+"/image.png")),
+			nil,
+			http.StatusForbidden,
+			ErrCodeForbidden,
+		},
+		{
+			"Not An Image",
+			"Only images should be served",
+			"GET",
+			signed(server.URL + "/page.html"),
+			nil,
+			http.StatusUnsupportedMediaType,
+			ErrCodeUnsupportedMediaType,
+		},
+		{
+			"Too Large",
+			"Images whose Content-Length is over the fetcher's MaxBytes should not be served",
+			"GET",
+			signed(server.URL + "/large.png"),
+			nil,
+			http.StatusBadGateway,
+			ErrCodeUpstreamTooLarge,
+		},
+		{
+			"Too Large Without Length",
+			"Images should not be served once more than the fetcher's MaxBytes has been read",
+			"GET",
+			signed(server.URL + "/chunked.png"),
+			nil,
+			http.StatusBadGateway,
+			ErrCodeUpstreamTooLarge,
+		},
+		{
+			"Upstream Error",
+			"Errors from the image's server should be reported with its status code",
+			"GET",
+			signed(server.URL + "/missing.png"),
+			nil,
+			http.StatusBadGateway,
+			ErrCodeUpstreamError,
+		},
+		{
+			"Forbidden Address",
+			"Even signed URLs may only be fetched from allowed networks",
+			"GET",
+			signed("http://169.254.169.254/image.png"),
+			nil,
+			http.StatusBadRequest,
+			ErrCodeForbiddenURL,
+		},
+	}
+
+	for _, c := range cases {
+		resp := httptest.NewRecorder()
+		req, _ := http.NewRequest(c.method, ImageProxyPath+"?"+c.query, nil)
+		for name, value := range c.header {
+			req.Header.Set(name, value)
+		}
+		proxy.ServeHTTP(resp, req)
+		if resp.Code != c.expectedStatus {
+			t.Errorf("case %s: incorrect response status code: expected %d but got %d\nHINT: %s",
+				c.name, c.expectedStatus, resp.Code, c.hint)
+			continue
+		}
+		if len(c.expectedCode) > 0 {
+			errResp := &ErrorResponse{}
+			if err := json.NewDecoder(resp.Body).Decode(errResp); err != nil || errResp.Code != c.expectedCode {
+				t.Errorf("case %s: expected error code %q but got %+v\nHINT: %s", c.name, c.expectedCode, errResp, c.hint)
+			}
+			continue
+		}
+		if cacheControl := resp.Header().Get("Cache-Control"); cacheControl != "public, max-age=86400" {
+			t.Errorf("case %s: incorrect Cache-Control header: %q", c.name, cacheControl)
+		}
+		if etag := resp.Header().Get("ETag"); etag != `"v1"` {
+			t.Errorf("case %s: the image's ETag should be kept, but got %q", c.name, etag)
+		}
+		if resp.Code != http.StatusOK {
+			continue
+		}
+		if contentType := resp.Header().Get("Content-Type"); contentType != "image/png" {
+			t.Errorf("case %s: incorrect Content-Type: expected image/png but got %q", c.name, contentType)
+		}
+		if resp.Header().Get("X-Content-Type-Options") != "nosniff" || len(resp.Header().Get("Content-Security-Policy")) == 0 {
+			t.Errorf("case %s: images should be served with nosniff and a Content-Security-Policy", c.name)
+		}
+		if resp.Body.String() != string(png) {
+			t.Errorf("case %s: incorrect image served: %q", c.name, resp.Body.String())
+		}
+	}
+}
+
+func TestSummaryHandlerImageProxy(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/image.png" {
+			w.Header().Set("Content-Type", "image/png")
+			w.Write(png)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><head>
+			<meta property="og:image" content="/image.png">
+			<meta property="og:image" content="data:image/png;base64,iVBORw0KGgo=">
+			<link rel="icon" href="/favicon.ico">
+			</head></html>`))
+	}))
+	defer server.Close()
+
+	summarizer := summary.NewSummarizer()
+	summarizer.Fetcher = summary.NewFetcher()
+	summarizer.Fetcher.Allow, _ = summary.ParseNetworks("127.0.0.0/8, ::1")
+	summarizer.Cache = summary.NewMemCache(10)
+	proxy := testImageProxy()
+	ctx := &Context{Summarizer: summarizer, ImageProxy: proxy}
+
+	//the second request gets the cached summary,
+	//which must not have been rewritten by the first
+	for i := 0; i < 2; i++ {
+		resp := httptest.NewRecorder()
+		//the request's Host isn't where clients reach the
+		//gateway, so it must not end up in proxy URLs
+		req, _ := http.NewRequest("GET", "http://internal:4000/v1/summary?url="+url.QueryEscape(server.URL+"/page.html"), nil)
+		ctx.SummaryHandler(resp, req)
+		if resp.Code != http.StatusOK {
+			t.Fatalf("incorrect response status code: expected %d but got %d", http.StatusOK, resp.Code)
+		}
+		pageSummary := &summary.PageSummary{}
+		if err := json.NewDecoder(resp.Body).Decode(pageSummary); err != nil {
+			t.Fatalf("error decoding response body: %v", err)
+		}
+		if len(pageSummary.Images) != 2 || pageSummary.Icon == nil {
+			t.Fatalf("expected 2 images and an icon, but got %d images and icon %+v", len(pageSummary.Images), pageSummary.Icon)
+		}
+
+		prefix := "https://api.test.com" + ImageProxyPath + "?"
+		if proxied := pageSummary.Images[0].URL; !strings.HasPrefix(proxied, prefix) {
+			t.Errorf("request %d: image URLs should be rewritten to proxy URLs at the proxy's origin, but got %s", i+1, proxied)
+		}
+		if icon := pageSummary.Icon.URL; icon != proxy.ProxyURL(server.URL+"/favicon.ico") {
+			t.Errorf("request %d: icon URLs should be rewritten to proxy URLs, but got %s", i+1, icon)
+		}
+		if dataURL := pageSummary.Images[1].URL; !strings.HasPrefix(dataURL, "data:") {
+			t.Errorf("request %d: data URLs should not be rewritten, but got %s", i+1, dataURL)
+		}
+
+		//the rewritten URL is served by the proxy
+		imageResp := httptest.NewRecorder()
+		imageReq, _ := http.NewRequest("GET", pageSummary.Images[0].URL, nil)
+		proxy.ServeHTTP(imageResp, imageReq)
+		if imageResp.Code != http.StatusOK || imageResp.Body.String() != string(png) {
+			t.Errorf("request %d: the rewritten image URL should serve the image, but got status %d", i+1, imageResp.Code)
+		}
+	}
+}
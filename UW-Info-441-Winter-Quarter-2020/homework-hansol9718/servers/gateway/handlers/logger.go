@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-hansol9718/servers/gateway/logging"
+)
+
+//headerRequestID is the header requests are identified by,
+//in log entries and when they are proxied to backends
+const headerRequestID = "X-Request-ID"
+
+//redacted replaces secrets in log entries
+const redacted = "REDACTED"
+
+//redactedHeaders are request headers whose values are never logged
+var redactedHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+}
+
+//redactedParams are query string parameters whose values are never
+//logged, like the session token websocket clients send as "auth"
+var redactedParams = map[string]bool{
+	"auth": true,
+}
+
+//logInfoKey is the request context key of the *logInfo
+//that handlers add details of the request to
+type logInfoKey struct{}
+
+//logInfo holds details of a request that only its handler knows
+type logInfo struct {
+	userID int64
+}
+
+//setLogUser records `userID` as the user who made the request `r`,
+//when the request is being logged by a RequestLogger
+func setLogUser(r *http.Request, userID int64) {
+	if info, ok := r.Context().Value(logInfoKey{}).(*logInfo); ok {
+		info.userID = userID
+	}
+}
+
+//RequestLogger is a middleware handler that logs every request as
+//JSON, identified by its X-Request-ID header. Requests without a
+//valid X-Request-ID are assigned one, which is added to the request,
+//so that it's forwarded to backends, and to the response.
+type RequestLogger struct {
+	//Handler is the wrapped handler
+	Handler http.Handler
+	//Log is the logger entries are written to
+	Log *logging.Logger
+}
+
+//NewRequestLogger constructs a new RequestLogger wrapping
+//`handler` that writes entries to `log`
+func NewRequestLogger(handler http.Handler, log *logging.Logger) *RequestLogger {
+	return &RequestLogger{Handler: handler, Log: log}
+}
+
+//ServeHTTP implements the http.Handler interface
+func (l *RequestLogger) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	id := r.Header.Get(headerRequestID)
+	if !logging.ValidRequestID(id) {
+		id = logging.NewRequestID()
+	}
+	r.Header.Set(headerRequestID, id)
+	w.Header().Set(headerRequestID, id)
+
+	info := &logInfo{}
+	ctx := logging.WithRequestID(r.Context(), id)
+	ctx = context.WithValue(ctx, logInfoKey{}, info)
+	rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+	l.Handler.ServeHTTP(rec, r.WithContext(ctx))
+
+	fields := logging.Fields{
+		"msg":       "request",
+		"requestId": id,
+		"method":    r.Method,
+		"path":      r.URL.Path,
+		"status":    rec.status,
+		"bytes":     rec.bytes,
+		"latencyMs": float64(time.Since(start).Microseconds()) / 1000,
+		"remote":    r.RemoteAddr,
+		"headers":   redactHeaders(r.Header),
+	}
+	if len(r.URL.RawQuery) > 0 {
+		fields["query"] = redactQuery(r.URL.RawQuery)
+	}
+	if info.userID != 0 {
+		fields["userId"] = info.userID
+	}
+	l.Log.Log(fields)
+}
+
+//redactHeaders returns `header` as a map of names to
+//comma-separated values, with secret values redacted
+func redactHeaders(header http.Header) map[string]string {
+	logged := make(map[string]string, len(header))
+	for name, values := range header {
+		if redactedHeaders[name] {
+			logged[name] = redacted
+			continue
+		}
+		logged[name] = ""
+		for i, value := range values {
+			if i > 0 {
+				logged[name] += ", "
+			}
+			logged[name] += value
+		}
+	}
+	return logged
+}
+
+//redactQuery returns `rawQuery` with secret parameter values redacted
+func redactQuery(rawQuery string) string {
+	params, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		//can't tell where the secrets are
+		return redacted
+	}
+	for name := range params {
+		if redactedParams[name] {
+			params[name] = []string{redacted}
+		}
+	}
+	return params.Encode()
+}
+
+//responseRecorder records the status code and
+//number of bytes of the response written to it
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	bytes       int64
+	wroteHeader bool
+}
+
+//WriteHeader implements the http.ResponseWriter interface
+func (rec *responseRecorder) WriteHeader(status int) {
+	if !rec.wroteHeader {
+		rec.status = status
+		rec.wroteHeader = true
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+//Write implements the http.ResponseWriter interface
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	rec.wroteHeader = true
+	n, err := rec.ResponseWriter.Write(b)
+	rec.bytes += int64(n)
+	return n, err
+}
+
+//Flush implements the http.Flusher interface, so that
+//proxied responses can be streamed
+func (rec *responseRecorder) Flush() {
+	if flusher, ok := rec.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+//Hijack implements the http.Hijacker interface, so
+//that websocket connections can be proxied
+func (rec *responseRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := rec.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("the response writer doesn't support hijacking")
+	}
+	rec.status = http.StatusSwitchingProtocols
+	rec.wroteHeader = true
+	return hijacker.Hijack()
+}
+
+//Unwrap returns the wrapped http.ResponseWriter,
+//for use by http.ResponseController
+func (rec *responseRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-hansol9718/servers/gateway/logging"
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-hansol9718/servers/gateway/models/users"
+)
+
+//decodeLogEntry decodes the single log entry in `buf`
+func decodeLogEntry(t *testing.T, buf *bytes.Buffer) map[string]interface{} {
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("expected one log entry, but got %q", buf.String())
+	}
+	entry := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatalf("error decoding log entry %q: %v", lines[0], err)
+	}
+	return entry
+}
+
+func TestRequestLogger(t *testing.T) {
+	cases := []struct {
+		name          string
+		hint          string
+		requestID     string
+		expectNewID   bool
+		target        string
+		authorization string
+		expectedQuery string
+		userID        int64
+	}{
+		{
+			"No Request ID",
+			"Requests without an X-Request-ID should be assigned one",
+			"",
+			true,
+			"/v1/summary?url=https%3A%2F%2Fexample.com",
+			"",
+			"url=https%3A%2F%2Fexample.com",
+			0,
+		},
+		{
+			"Request ID",
+			"Valid X-Request-IDs should be propagated",
+			"upstream-id-1",
+			false,
+			"/v1/users/me",
+			"Bearer secret-session-id",
+			"",
+			7,
+		},
+		{
+			"Invalid Request ID",
+			"Invalid X-Request-IDs should be replaced",
+			"id\nwith a forged log line",
+			true,
+			"/v1/ws?auth=secret-session-id&channel=1",
+			"",
+			"auth=REDACTED&channel=1",
+			7,
+		},
+	}
+
+	for _, c := range cases {
+		buf := &bytes.Buffer{}
+		var handlerID, contextID string
+		handler := NewRequestLogger(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handlerID = r.Header.Get(headerRequestID)
+			contextID = logging.RequestID(r.Context())
+			if c.userID != 0 {
+				setLogUser(r, c.userID)
+			}
+			w.WriteHeader(http.StatusTeapot)
+			w.Write([]byte("short and stout"))
+		}), logging.New(buf))
+
+		resp := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", c.target, nil)
+		if len(c.requestID) > 0 {
+			req.Header.Set(headerRequestID, c.requestID)
+		}
+		if len(c.authorization) > 0 {
+			req.Header.Set("Authorization", c.authorization)
+		}
+		handler.ServeHTTP(resp, req)
+
+		id := resp.Header().Get(headerRequestID)
+		if c.expectNewID && (id == c.requestID || !logging.ValidRequestID(id)) {
+			t.Errorf("case %s: expected a new request ID but got %q\nHINT: %s", c.name, id, c.hint)
+		}
+		if !c.expectNewID && id != c.requestID {
+			t.Errorf("case %s: expected request ID %q but got %q\nHINT: %s", c.name, c.requestID, id, c.hint)
+		}
+		if handlerID != id || contextID != id {
+			t.Errorf("case %s: the handler should get the request ID %q in its header and context, but got %q and %q",
+				c.name, id, handlerID, contextID)
+		}
+
+		entry := decodeLogEntry(t, buf)
+		expected := map[string]interface{}{
+			"msg":       "request",
+			"requestId": id,
+			"method":    "GET",
+			"path":      req.URL.Path,
+			"status":    float64(http.StatusTeapot),
+			"bytes":     float64(len("short and stout")),
+		}
+		if len(c.expectedQuery) > 0 {
+			expected["query"] = c.expectedQuery
+		}
+		if c.userID != 0 {
+			expected["userId"] = float64(c.userID)
+		}
+		for name, value := range expected {
+			if entry[name] != value {
+				t.Errorf("case %s: incorrect %s logged: expected %v but got %v\nHINT: %s", c.name, name, value, entry[name], c.hint)
+			}
+		}
+		if c.userID == 0 && entry["userId"] != nil {
+			t.Errorf("case %s: no user ID should be logged for anonymous requests, but got %v", c.name, entry["userId"])
+		}
+		if _, ok := entry["latencyMs"].(float64); !ok {
+			t.Errorf("case %s: the request's latency should be logged", c.name)
+		}
+		if strings.Contains(buf.String(), "secret-session-id") {
+			t.Errorf("case %s: session IDs must be redacted from logs, but got %s", c.name, buf.String())
+		}
+	}
+}
+
+func TestRequestLoggerProxy(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get(headerRequestID)))
+	}))
+	defer backend.Close()
+	target, _ := url.Parse(backend.URL)
+
+	user := &users.User{ID: 7, UserName: "user"}
+	ctx := newTestContext()
+	auth := beginTestSession(t, ctx, user)
+	buf := &bytes.Buffer{}
+	handler := NewRequestLogger(ctx.NewServiceProxy(NewBalancer([]*url.URL{target})), logging.New(buf))
+
+	resp := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/v1/channels", nil)
+	req.Header.Set("Authorization", auth)
+	handler.ServeHTTP(resp, req)
+
+	id := resp.Header().Get(headerRequestID)
+	if len(id) == 0 || resp.Body.String() != id {
+		t.Errorf("the request ID should be forwarded to the backend: expected %q but the backend got %q", id, resp.Body.String())
+	}
+	entry := decodeLogEntry(t, buf)
+	if entry["userId"] != float64(user.ID) {
+		t.Errorf("the signed-in user's ID should be logged for proxied requests, but got %v", entry["userId"])
+	}
+	if headers, _ := entry["headers"].(map[string]interface{}); headers["Authorization"] != redacted {
+		t.Errorf("the Authorization header should be redacted, but got %v", entry["headers"])
+	}
+}
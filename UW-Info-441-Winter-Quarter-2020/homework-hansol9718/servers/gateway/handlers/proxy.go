@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-hansol9718/servers/gateway/sessions"
+)
+
+//headerUser is the header proxied requests carry the
+//JSON-encoded signed-in user in
+const headerUser = "X-User"
+
+//ProxyRoute routes requests whose paths start with
+//any of its Prefixes to one of its Targets
+type ProxyRoute struct {
+	//Prefixes are path prefixes, like "/v1/channels"
+	Prefixes []string
+	//Targets are the base URLs of the backends that handle the route
+	Targets []*url.URL
+}
+
+//ParseProxyRoutes parses semicolon-separated proxy routes, each of
+//the form "<prefixes>=<addresses>", where both are comma-separated.
+//For example "/v1/channels,/v1/messages=messages1:80,messages2:80".
+//Addresses without a scheme are assumed to be http.
+func ParseProxyRoutes(spec string) ([]*ProxyRoute, error) {
+	var routes []*ProxyRoute
+	for _, s := range strings.Split(spec, ";") {
+		if len(strings.TrimSpace(s)) == 0 {
+			continue
+		}
+		parts := strings.SplitN(s, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid proxy route %q: must be <prefixes>=<addresses>", s)
+		}
+		route := &ProxyRoute{}
+		for _, prefix := range strings.Split(parts[0], ",") {
+			prefix = strings.TrimSpace(prefix)
+			if !strings.HasPrefix(prefix, "/") {
+				return nil, fmt.Errorf("invalid proxy route %q: prefix %q must start with /", s, prefix)
+			}
+			route.Prefixes = append(route.Prefixes, prefix)
+		}
+		for _, addr := range strings.Split(parts[1], ",") {
+			addr = strings.TrimSpace(addr)
+			if !strings.Contains(addr, "://") {
+				addr = "http://" + addr
+			}
+			target, err := url.Parse(addr)
+			if err != nil || len(target.Host) == 0 {
+				return nil, fmt.Errorf("invalid proxy route %q: invalid address %q", s, addr)
+			}
+			route.Targets = append(route.Targets, target)
+		}
+		routes = append(routes, route)
+	}
+	return routes, nil
+}
+
+//backendKey is the request context key of the
+//Backend a proxied request is sent to
+type backendKey struct{}
+
+//ServiceProxy is a reverse proxy to a service's backend instances.
+//Any X-User header the client sent is removed, and if the client is
+//signed in, the session's user is added as a JSON-encoded X-User
+//header, so that backends can trust it. The client's Authorization
+//header is removed too: the gateway authenticates the session, and
+//backends only need X-User. CORS headers in responses are removed,
+//as they are the gateway's job.
+type ServiceProxy struct {
+	//Balancer chooses the backend each request is sent to
+	Balancer *Balancer
+
+	ctx   *Context
+	proxy *httputil.ReverseProxy
+}
+
+//NewServiceProxy constructs a new ServiceProxy that sends requests
+//to the backends chosen by `balancer`
+func (ctx *Context) NewServiceProxy(balancer *Balancer) *ServiceProxy {
+	p := &ServiceProxy{Balancer: balancer, ctx: ctx}
+	p.proxy = &httputil.ReverseProxy{
+		Director: func(r *http.Request) {
+			target := r.Context().Value(backendKey{}).(*Backend).URL
+			r.URL.Scheme = target.Scheme
+			r.URL.Host = target.Host
+			r.URL.Path, r.URL.RawPath = joinURLPath(target, r.URL)
+			r.Host = target.Host
+			ctx.setUserHeader(r)
+			//the session token is only meant for the gateway
+			r.Header.Del("Authorization")
+		},
+		ModifyResponse: func(resp *http.Response) error {
+			//the gateway's CORS middleware has already set these
+			for name := range resp.Header {
+				if strings.HasPrefix(name, "Access-Control-") {
+					resp.Header.Del(name)
+				}
+			}
+			return nil
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			writeError(w, http.StatusBadGateway, &ErrorResponse{
+				Code:    ErrCodeUpstreamError,
+				Message: "error reaching the service",
+			})
+		},
+	}
+	return p
+}
+
+//ServeHTTP implements the http.Handler interface
+func (p *ServiceProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	backend, err := p.Balancer.Acquire()
+	if err != nil {
+		writeError(w, http.StatusServiceUnavailable, &ErrorResponse{
+			Code:    ErrCodeServiceUnavailable,
+			Message: "the service is unavailable",
+		})
+		return
+	}
+	defer p.Balancer.Release(backend)
+	p.proxy.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), backendKey{}, backend)))
+}
+
+//setUserHeader replaces any X-User header in `r` with
+//the JSON-encoded user of the request's session, if any
+func (ctx *Context) setUserHeader(r *http.Request) {
+	r.Header.Del(headerUser)
+	if ctx.SessionStore == nil {
+		return
+	}
+	state := &SessionState{}
+	if _, err := sessions.GetState(r, ctx.SigningKey, ctx.SessionStore, state); err != nil || state.User == nil {
+		return
+	}
+	setLogUser(r, state.User.ID)
+	if j, err := json.Marshal(state.User); err == nil {
+		r.Header.Set(headerUser, string(j))
+	}
+}
+
+//singleJoiningSlash joins URL paths `a` and `b` with exactly one slash
+func singleJoiningSlash(a string, b string) string {
+	aslash := strings.HasSuffix(a, "/")
+	bslash := strings.HasPrefix(b, "/")
+	switch {
+	case aslash && bslash:
+		return a + b[1:]
+	case !aslash && !bslash:
+		return a + "/" + b
+	}
+	return a + b
+}
+
+//joinURLPath joins the paths of `a` and `b` with exactly one slash,
+//returning both the decoded path and, if either URL's path has
+//escapes that decoding would lose, like %2F, the escaped path
+func joinURLPath(a *url.URL, b *url.URL) (path string, rawPath string) {
+	if len(a.RawPath) == 0 && len(b.RawPath) == 0 {
+		return singleJoiningSlash(a.Path, b.Path), ""
+	}
+	aPath := a.EscapedPath()
+	bPath := b.EscapedPath()
+	aSlash := strings.HasSuffix(aPath, "/")
+	bSlash := strings.HasPrefix(bPath, "/")
+	switch {
+	case aSlash && bSlash:
+		return a.Path + b.Path[1:], aPath + bPath[1:]
+	case !aSlash && !bSlash:
+		return a.Path + "/" + b.Path, aPath + "/" + bPath
+	}
+	return a.Path + b.Path, aPath + bPath
+}
@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-hansol9718/servers/gateway/models/users"
+)
+
+//newTestBackend returns a backend server that responds
+//with its name and the X-User header it received
+func newTestBackend(name string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("X-Backend", name)
+		w.Header().Set("X-Backend-Path", r.URL.Path)
+		w.Header().Set("X-Backend-Escaped-Path", r.URL.EscapedPath())
+		w.Header().Set("X-Backend-Authorization", r.Header.Get("Authorization"))
+		w.Write([]byte(r.Header.Get("X-User")))
+	}))
+}
+
+func TestServiceProxy(t *testing.T) {
+	backend := newTestBackend("messages")
+	defer backend.Close()
+	target, _ := url.Parse(backend.URL)
+
+	user := &users.User{ID: 7, UserName: "user", FirstName: "First", LastName: "Last"}
+	ctx := newTestContext()
+	auth := beginTestSession(t, ctx, user)
+	proxy := ctx.NewServiceProxy(NewBalancer([]*url.URL{target}))
+
+	cases := []struct {
+		name         string
+		hint         string
+		auth         string
+		clientXUser  string
+		expectedUser *users.User
+	}{
+		{
+			"Signed In",
+			"The signed-in user should be sent to the backend in X-User",
+			auth,
+			"",
+			user,
+		},
+		{
+			"Not Signed In",
+			"Requests without a session should have no X-User",
+			"",
+			"",
+			nil,
+		},
+		{
+			"Forged X-User",
+			"X-User headers sent by clients must be removed",
+			"",
+			`{"id": 1, "userName": "admin"}`,
+			nil,
+		},
+		{
+			"Forged X-User Signed In",
+			"X-User headers sent by clients must be replaced with the signed-in user",
+			auth,
+			`{"id": 1, "userName": "admin"}`,
+			user,
+		},
+		{
+			"Invalid Session",
+			"Requests with invalid sessions should have no X-User",
+			"Bearer invalid",
+			`{"id": 1, "userName": "admin"}`,
+			nil,
+		},
+	}
+
+	for _, c := range cases {
+		resp := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/v1/channels/3", nil)
+		if len(c.auth) > 0 {
+			req.Header.Set("Authorization", c.auth)
+		}
+		if len(c.clientXUser) > 0 {
+			req.Header.Set("X-User", c.clientXUser)
+		}
+		proxy.ServeHTTP(resp, req)
+
+		if resp.Code != http.StatusOK {
+			t.Fatalf("case %s: incorrect response status code: expected %d but got %d", c.name, http.StatusOK, resp.Code)
+		}
+		if path := resp.Header().Get("X-Backend-Path"); path != "/v1/channels/3" {
+			t.Errorf("case %s: incorrect path sent to backend: expected %q but got %q", c.name, "/v1/channels/3", path)
+		}
+		if auth := resp.Header().Get("X-Backend-Authorization"); len(auth) > 0 {
+			t.Errorf("case %s: the client's Authorization header should not be sent to backends, but got %q", c.name, auth)
+		}
+		if resp.Header().Get("Access-Control-Allow-Origin") != "" {
+			t.Errorf("case %s: the backend's CORS headers should have been removed", c.name)
+		}
+
+		var xUser *users.User
+		if resp.Body.Len() > 0 {
+			xUser = &users.User{}
+			if err := json.Unmarshal(resp.Body.Bytes(), xUser); err != nil {
+				t.Errorf("case %s: error decoding X-User header %q: %v", c.name, resp.Body.String(), err)
+				continue
+			}
+		}
+		if !reflect.DeepEqual(xUser, c.expectedUser) {
+			t.Errorf("case %s: incorrect X-User: expected %+v but got %+v\nHINT: %s", c.name, c.expectedUser, xUser, c.hint)
+		}
+	}
+}
+
+func TestServiceProxyEscapedPath(t *testing.T) {
+	backend := newTestBackend("files")
+	defer backend.Close()
+	target, _ := url.Parse(backend.URL + "/api/")
+	proxy := newTestContext().NewServiceProxy(NewBalancer([]*url.URL{target}))
+
+	resp := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/v1/files/a%2Fb", nil)
+	proxy.ServeHTTP(resp, req)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("incorrect response status code: expected %d but got %d", http.StatusOK, resp.Code)
+	}
+	if path := resp.Header().Get("X-Backend-Escaped-Path"); path != "/api/v1/files/a%2Fb" {
+		t.Errorf("escaped path segments should reach the backend as they were sent, but got %q", path)
+	}
+}
+
+func TestParseProxyRoutes(t *testing.T) {
+	cases := []struct {
+		name           string
+		spec           string
+		expectedRoutes []string
+		expectError    bool
+	}{
+		{
+			"Single Route",
+			"/v1/channels,/v1/messages=messages:80",
+			[]string{"/v1/channels,/v1/messages=http://messages:80"},
+			false,
+		},
+		{
+			"Multiple Routes",
+			" /v1/channels = messages1:80, messages2:80 ; /v1/photos=https://photos.test/api ;",
+			[]string{
+				"/v1/channels=http://messages1:80,http://messages2:80",
+				"/v1/photos=https://photos.test/api",
+			},
+			false,
+		},
+		{
+			"Empty",
+			"",
+			nil,
+			false,
+		},
+		{
+			"No Addresses",
+			"/v1/channels",
+			nil,
+			true,
+		},
+		{
+			"Relative Prefix",
+			"v1/channels=messages:80",
+			nil,
+			true,
+		},
+		{
+			"Empty Address",
+			"/v1/channels=messages:80,",
+			nil,
+			true,
+		},
+	}
+
+	for _, c := range cases {
+		routes, err := ParseProxyRoutes(c.spec)
+		if err != nil != c.expectError {
+			t.Errorf("case %s: expected error to be %t but got %v", c.name, c.expectError, err)
+			continue
+		}
+		var actual []string
+		for _, route := range routes {
+			s := ""
+			for i, prefix := range route.Prefixes {
+				if i > 0 {
+					s += ","
+				}
+				s += prefix
+			}
+			s += "="
+			for i, target := range route.Targets {
+				if i > 0 {
+					s += ","
+				}
+				s += target.String()
+			}
+			actual = append(actual, s)
+		}
+		if !reflect.DeepEqual(actual, c.expectedRoutes) {
+			t.Errorf("case %s: incorrect routes: expected %v but got %v", c.name, c.expectedRoutes, actual)
+		}
+	}
+}
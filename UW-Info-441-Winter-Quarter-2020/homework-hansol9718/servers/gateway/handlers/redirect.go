@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+//hstsValue is the Strict-Transport-Security header value,
+//telling browsers to only use HTTPS for two years
+const hstsValue = "max-age=63072000"
+
+//HSTS is a middleware handler that adds the
+//Strict-Transport-Security header to every response
+type HSTS struct {
+	//Handler is the wrapped handler
+	Handler http.Handler
+}
+
+//NewHSTS constructs a new HSTS middleware handler wrapping `handler`
+func NewHSTS(handler http.Handler) *HSTS {
+	return &HSTS{Handler: handler}
+}
+
+//ServeHTTP implements the http.Handler interface
+func (h *HSTS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Strict-Transport-Security", hstsValue)
+	h.Handler.ServeHTTP(w, r)
+}
+
+//HTTPSRedirect permanently redirects plain HTTP
+//requests to the same URL using HTTPS
+type HTTPSRedirect struct {
+	//Port is the port HTTPS is served on. If empty
+	//or "443", redirects use the default port.
+	Port string
+}
+
+//NewHTTPSRedirect constructs a new HTTPSRedirect to HTTPS on `port`
+func NewHTTPSRedirect(port string) *HTTPSRedirect {
+	return &HTTPSRedirect{Port: port}
+}
+
+//ServeHTTP implements the http.Handler interface
+func (h *HTTPSRedirect) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	host := r.Host
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		host = hostname
+	} else {
+		host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	}
+	if len(host) == 0 {
+		http.Error(w, "missing Host header", http.StatusBadRequest)
+		return
+	}
+	if len(h.Port) > 0 && h.Port != "443" {
+		host = net.JoinHostPort(host, h.Port)
+	} else if ip := net.ParseIP(host); ip != nil && ip.To4() == nil {
+		//IPv6 addresses must be bracketed even without a port
+		host = "[" + host + "]"
+	}
+	w.Header().Set("Strict-Transport-Security", hstsValue)
+	http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHTTPSRedirect(t *testing.T) {
+	cases := []struct {
+		name             string
+		hint             string
+		port             string
+		host             string
+		target           string
+		expectedStatus   int
+		expectedLocation string
+	}{
+		{
+			"Default Port",
+			"Requests should be redirected to the same URL using https",
+			"443",
+			"api.example.com",
+			"/v1/summary?url=https%3A%2F%2Fexample.com%2F",
+			http.StatusMovedPermanently,
+			"https://api.example.com/v1/summary?url=https%3A%2F%2Fexample.com%2F",
+		},
+		{
+			"Host With Port",
+			"The port of the plain HTTP listener should be removed",
+			"",
+			"api.example.com:80",
+			"/v1/users/me",
+			http.StatusMovedPermanently,
+			"https://api.example.com/v1/users/me",
+		},
+		{
+			"Other HTTPS Port",
+			"HTTPS ports other than 443 should be included in the redirect",
+			"4443",
+			"localhost:8080",
+			"/",
+			http.StatusMovedPermanently,
+			"https://localhost:4443/",
+		},
+		{
+			"IPv6 Host",
+			"IPv6 hosts should stay bracketed",
+			"443",
+			"[::1]:80",
+			"/",
+			http.StatusMovedPermanently,
+			"https://[::1]/",
+		},
+		{
+			"IPv6 Host Other Port",
+			"IPv6 hosts should stay bracketed",
+			"4443",
+			"[::1]",
+			"/",
+			http.StatusMovedPermanently,
+			"https://[::1]:4443/",
+		},
+		{
+			"Missing Host",
+			"Requests without a Host header can't be redirected",
+			"443",
+			"",
+			"/",
+			http.StatusBadRequest,
+			"",
+		},
+	}
+
+	for _, c := range cases {
+		resp := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", c.target, nil)
+		req.Host = c.host
+		NewHTTPSRedirect(c.port).ServeHTTP(resp, req)
+
+		if resp.Code != c.expectedStatus {
+			t.Errorf("case %s: incorrect response status code: expected %d but got %d\nHINT: %s",
+				c.name, c.expectedStatus, resp.Code, c.hint)
+		}
+		if location := resp.Header().Get("Location"); location != c.expectedLocation {
+			t.Errorf("case %s: incorrect Location: expected %q but got %q\nHINT: %s",
+				c.name, c.expectedLocation, location, c.hint)
+		}
+		if c.expectedStatus == http.StatusMovedPermanently && resp.Header().Get("Strict-Transport-Security") != hstsValue {
+			t.Errorf("case %s: redirects should include the Strict-Transport-Security header", c.name)
+		}
+	}
+}
+
+func TestHSTS(t *testing.T) {
+	handler := NewHSTS(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	resp := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/v1/summary", nil)
+	handler.ServeHTTP(resp, req)
+	if resp.Code != http.StatusTeapot {
+		t.Errorf("incorrect response status code: expected %d but got %d", http.StatusTeapot, resp.Code)
+	}
+	if hsts := resp.Header().Get("Strict-Transport-Security"); hsts != hstsValue {
+		t.Errorf("incorrect Strict-Transport-Security: expected %q but got %q", hstsValue, hsts)
+	}
+}
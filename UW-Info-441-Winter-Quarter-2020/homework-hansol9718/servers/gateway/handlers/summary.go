@@ -1,246 +1,237 @@
 package handlers
 
 import (
-	"io"
-	"net/http"
-	"strings"
-	"golang.org/x/net/html"
-	"net/url"
-	"strconv"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-hansol9718/servers/gateway/summary"
 )
 
-//PreviewImage represents a preview image for a page
-type PreviewImage struct {
-	URL       string `json:"url,omitempty"`
-	SecureURL string `json:"secureURL,omitempty"`
-	Type      string `json:"type,omitempty"`
-	Width     int    `json:"width,omitempty"`
-	Height    int    `json:"height,omitempty"`
-	Alt       string `json:"alt,omitempty"`
-}
+//Default limits of SummaryHandler and SummariesHandler
+const (
+	//DefaultSummaryTimeout is how long a page's summary is waited for
+	DefaultSummaryTimeout = 30 * time.Second
+	//DefaultMaxBatchSize is the most URLs summarized in one request
+	DefaultMaxBatchSize = 20
+	//DefaultBatchTimeout is how long a batch's summaries are waited for
+	DefaultBatchTimeout = 10 * time.Second
+)
 
-//PageSummary represents summary properties for a web page
-type PageSummary struct {
-	Type        string          `json:"type,omitempty"`
-	URL         string          `json:"url,omitempty"`
-	Title       string          `json:"title,omitempty"`
-	SiteName    string          `json:"siteName,omitempty"`
-	Description string          `json:"description,omitempty"`
-	Author      string          `json:"author,omitempty"`
-	Keywords    []string        `json:"keywords,omitempty"`
-	Icon        *PreviewImage   `json:"icon,omitempty"`
-	Images      []*PreviewImage `json:"images,omitempty"`
-}
+//maxBatchBytes is the most that is read from a batch request's body
+const maxBatchBytes = 1 << 20
 
-func SummaryHandler(w http.ResponseWriter, r *http.Request) {
-	
-	w.Header().Add("Access-Control-Allow-Origin", "*")
-	w.Header().Add("Content-Type", "application/json" )
+//BatchSummary is the result for one URL of a batch of summaries:
+//either the page's Summary, or the Error that prevented it
+type BatchSummary struct {
+	Summary *summary.PageSummary `json:"summary,omitempty"`
+	Error   *ErrorResponse       `json:"error,omitempty"`
+}
 
+//SummaryHandler responds with a JSON-encoded summary.PageSummary
+//of the page at the URL given in the `url` query string parameter.
+//The optional `iconSize` parameter is the size in pixels the client
+//will show the page's icon at, which the summary's Icon is chosen for,
+//and the optional `deep` parameter, if true, has the page's body read
+//for an excerpt of its main content, as SUMMARYDEEP does for every page.
+//If the Context has an ImageProxy, the summary's images are served by it.
+//Errors are reported with a JSON-encoded ErrorResponse, including an
+//upstream_timeout error for pages not summarized within the Context's
+//SummaryTimeout, however many resources the page links to are fetched.
+func (ctx *Context) SummaryHandler(w http.ResponseWriter, r *http.Request) {
 	URL := r.FormValue("url")
 	if URL == "" {
-		http.Error(w,"URL supply error", 400)
+		writeError(w, http.StatusBadRequest, &ErrorResponse{
+			Code:    ErrCodeInvalidURL,
+			Message: "the url query string parameter is required",
+		})
+		return
 	}
-
-	body, err := fetchHTML(URL)
-	defer body.Close()
-	if err != nil {
-		http.Error(w, "URL fetch error", 400)
+	iconSize := 0
+	if s := r.FormValue("iconSize"); len(s) > 0 {
+		size, err := strconv.Atoi(s)
+		if err != nil || size <= 0 {
+			writeError(w, http.StatusBadRequest, &ErrorResponse{
+				Code:    ErrCodeBadRequest,
+				Message: "the iconSize query string parameter must be a positive number of pixels",
+			})
+			return
+		}
+		iconSize = size
+	}
+	opts, ok := summaryOptions(w, r)
+	if !ok {
+		return
 	}
 
-	pageSummary, err := extractSummary(URL, body)
+	timeout := ctx.SummaryTimeout
+	if timeout <= 0 {
+		timeout = DefaultSummaryTimeout
+	}
+	summaryCtx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+	pageSummary, cacheStatus, err := ctx.Summarizer.SummarizeURL(summaryCtx, URL, opts)
 	if err != nil {
-		http.Error(w, "extracting summary error", 400)
+		status, errResp := fetchError(err, "page")
+		writeError(w, status, errResp)
+		return
+	}
+	if iconSize > 0 && len(pageSummary.Icons) > 0 {
+		//the summary may be shared with the cache,
+		//so the icon is chosen on a copy of it
+		sized := *pageSummary
+		sized.Icon = summary.BestIcon(pageSummary.Icons, iconSize)
+		pageSummary = &sized
 	}
+	pageSummary = ctx.proxyImages(pageSummary)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Cache", string(cacheStatus))
 	json.NewEncoder(w).Encode(pageSummary)
-	return
 }
 
-
-func fetchHTML(pageURL string) (io.ReadCloser, error) {
-	
-	resp, err := http.Get(pageURL)
-	if err != nil {
-		return nil, err
+//SummariesHandler summarizes a batch of pages. POST requests with a
+//JSON array of URLs as the body are responded to with a JSON object
+//mapping each URL to a BatchSummary. Requests for more than the
+//Context's MaxBatchSize distinct URLs are bad requests. As with
+//SummaryHandler, the `deep` query string parameter has the pages'
+//bodies read, and images are served by the Context's ImageProxy if set.
+//
+//Pages are summarized concurrently. Those not summarized within the
+//Context's BatchTimeout have an upstream_timeout error, so the
+//response doesn't wait on a slow site for longer than that.
+func (ctx *Context) SummariesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
 	}
-
-	if resp.StatusCode >= 400 {
-		return nil, errors.New("StatusCode error")
+	if !isJSON(r) {
+		unsupportedMediaType(w)
+		return
 	}
-
-	ctype := resp.Header.Get("Content-Type")
-	if !strings.HasPrefix(ctype, "text/html") {
-		return nil, errors.New("not a valid content type")
+	opts, ok := summaryOptions(w, r)
+	if !ok {
+		return
 	}
 
-	return resp.Body, nil
-}
-
-func extractSummary(pageURL string, htmlStream io.ReadCloser) (*PageSummary, error) {
-	
-	tokenizer := html.NewTokenizer(htmlStream)
-	page := new(PageSummary)
-	HTMLTitle := false
-	description := false
-	for {
-		tokenType := tokenizer.Next()
-		if tokenType == html.ErrorToken {
-			err := tokenizer.Err()
-			if err == io.EOF {
-				break
-			}
-			//log.Fatalf("error tokenizing HTML: %v", tokenizer.Err())
-			return nil, fmt.Errorf("error tokenizing HTML: %v", err)
-		}
-		if tokenType == html.EndTagToken {
-			token := tokenizer.Token()
-			if "head" == token.Data {
-				break
-			}
-		}
-		token := tokenizer.Token()
-		if "title" == token.Data {
-			if HTMLTitle == false {
-				tokenType  = tokenizer.Next()
-				if tokenType == html.TextToken {
-					page.Title = tokenizer.Token().Data
-					HTMLTitle = true
-				}
-			}
-		}
-
-		if "link" == token.Data {
-			iconLink, iconType, iconHeight, iconWidth, check := iconHelper(token, "icon")
-			if check {
-				iconLink = absoluteURL(iconLink)
-				p := &PreviewImage{}
-				p.URL = iconLink
-				p.Type = iconType
-				p.Width = iconHeight
-				p.Height = iconWidth
-				page.Icon = p
-			}
-		}	
+	var URLs []string
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxBatchBytes)).Decode(&URLs); err != nil {
+		writeError(w, http.StatusBadRequest, &ErrorResponse{
+			Code:    ErrCodeBadRequest,
+			Message: "error decoding URLs: " + err.Error(),
+		})
+		return
+	}
+	maxSize := ctx.MaxBatchSize
+	if maxSize <= 0 {
+		maxSize = DefaultMaxBatchSize
+	}
+	distinct := make(map[string]bool, len(URLs))
+	for _, URL := range URLs {
+		distinct[URL] = true
+	}
+	if len(distinct) > maxSize {
+		writeError(w, http.StatusBadRequest, &ErrorResponse{
+			Code:    ErrCodeBadRequest,
+			Message: fmt.Sprintf("at most %d URLs may be summarized at once", maxSize),
+		})
+		return
+	}
 
-		if "meta" == token.Data {
-			propTitle, check := extractHelper(token, "og:title")
-			if check {
-				page.Title = propTitle
-				HTMLTitle = true
-			}
-			propType, check := extractHelper(token, "og:type")
-			if check {
-				page.Type = propType
-			}
-			propSiteName, check := extractHelper(token, "og:site_name")
-			if check {
-				page.SiteName = propSiteName
-			}
-			propDesc, check := extractHelper(token, "og:description")
-			if check {
-				page.Description = propDesc
-				description = true
-			} 
-			if !check && description == false {
-				propDesc2, check2 := extractHelper(token, "description")
-				if check2 {
-					page.Description = propDesc2
-				}
-			}
-			propAuthor, check := extractHelper(token, "author")
-			if check{
-				page.Author = propAuthor
-			}
-			propURL, check := extractHelper(token, "og:url")
-			if check {
-				page.URL = propURL
-			}
-			propKeywords, check := extractHelper(token, "keywords")
-			if check {
-				s := strings.Split(propKeywords, ",")
-				for i, val := range s {
-					s[i] = strings.TrimSpace(val)
-				}
-				page.Keywords = s
-			}
-			imageLink, check := extractHelper(token, "og:image")
-			if check {
-				imageLink = absoluteURL(imageLink)
-				p := &PreviewImage{}
-				p.URL = imageLink
-				page.Images = append(page.Images, p)
-			}
-			secureLink, check := extractHelper(token, "og:image:secure_url")
-			if check {
-				secureLink = absoluteURL(secureLink)
-				page.Images[len(page.Images) - 1].SecureURL = secureLink
-			}
-			imageType, check := extractHelper(token, "og:image:type")
-			if check {
-				page.Images[len(page.Images) - 1].Type = imageType
-			}
-			imageW, check := extractHelper(token, "og:image:width")
-			if check {
-				page.Images[len(page.Images) - 1].Width, _ = strconv.Atoi(imageW)
-			}
-			imageH, check := extractHelper(token, "og:image:height")
-			if check {
-				page.Images[len(page.Images) - 1].Height, _ = strconv.Atoi(imageH)
-			}
-			alt, check := extractHelper(token, "og:image:alt") 
-			if check {
-				page.Images[len(page.Images) - 1].Alt = alt
-			}
+	timeout := ctx.BatchTimeout
+	if timeout <= 0 {
+		timeout = DefaultBatchTimeout
+	}
+	batchCtx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+	batcher := summary.NewBatcher(ctx.Summarizer)
+	batcher.Options = opts
+	results := batcher.SummarizeURLs(batchCtx, URLs)
+
+	summaries := make(map[string]*BatchSummary, len(results))
+	for URL, result := range results {
+		if result.Err != nil {
+			_, errResp := fetchError(result.Err, "page")
+			summaries[URL] = &BatchSummary{Error: errResp}
+			continue
 		}
+		summaries[URL] = &BatchSummary{Summary: ctx.proxyImages(result.Summary)}
 	}
-	return page, nil
+	writeJSON(w, http.StatusOK, summaries)
 }
-	
-	
-func extractHelper(t html.Token, prop string) (content string, check bool) {
-	for _, attr := range t.Attr {
-		if (attr.Key == "property" || attr.Key == "name") && attr.Val == prop {
-			check = true
-		}
 
-		if attr.Key == "content" {
-			content = attr.Val
+//summaryOptions returns the summary.Options given in the query string
+//of `r`. If they are invalid, it responds with a bad request error and
+//returns false.
+func summaryOptions(w http.ResponseWriter, r *http.Request) (summary.Options, bool) {
+	opts := summary.Options{}
+	if s := r.URL.Query().Get("deep"); len(s) > 0 {
+		deep, err := strconv.ParseBool(s)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, &ErrorResponse{
+				Code:    ErrCodeBadRequest,
+				Message: "the deep query string parameter must be true or false",
+			})
+			return opts, false
 		}
+		opts.Deep = deep
 	}
-
-	return
+	return opts, true
 }
 
-func iconHelper(t html.Token, prop string) (href string, typ string, h int, w int, check bool) {
-	for _, attr := range t.Attr {
-		if attr.Key =="rel" && attr.Val == prop {
-			check = true
+//fetchError returns the response status code and ErrorResponse for
+//an error fetching a URL, where `noun` names what was being fetched,
+//like "page" or "image". Problems with the requested URL are bad
+//requests; problems fetching from a working URL are upstream failures.
+//The messages don't include the error itself, which may reveal the
+//addresses the URL resolved to.
+func fetchError(err error, noun string) (int, *ErrorResponse) {
+	var statusErr *summary.StatusError
+	switch {
+	case errors.Is(err, summary.ErrInvalidURL), errors.Is(err, summary.ErrUnsupportedScheme):
+		return http.StatusBadRequest, &ErrorResponse{
+			Code:    ErrCodeInvalidURL,
+			Message: "the url must be an absolute http or https URL",
+		}
+	case errors.Is(err, summary.ErrForbiddenAddress):
+		return http.StatusBadRequest, &ErrorResponse{
+			Code:    ErrCodeForbiddenURL,
+			Message: noun + "s may not be fetched from the url's host",
 		}
-		if attr.Key == "href" {
-			href = attr.Val
+	case errors.Is(err, summary.ErrNotHTML):
+		return http.StatusUnsupportedMediaType, &ErrorResponse{
+			Code:    ErrCodeUnsupportedMediaType,
+			Message: "the url is not an HTML page",
 		}
-		if attr.Key == "type" {
-			typ = attr.Val
+	case errors.Is(err, summary.ErrTimeout):
+		return http.StatusGatewayTimeout, &ErrorResponse{
+			Code:    ErrCodeUpstreamTimeout,
+			Message: "timed out fetching the " + noun,
 		}
-		if attr.Key == "sizes" {
-			sizes := strings.Split(attr.Val, "x")
-			intW, _ := strconv.Atoi(sizes[0])
-			w = intW
-			if len(sizes) > 1 {
-				intH, _ := strconv.Atoi(sizes[1])
-				h = intH
-			}
+	case errors.Is(err, summary.ErrTooLarge):
+		return http.StatusBadGateway, &ErrorResponse{
+			Code:    ErrCodeUpstreamTooLarge,
+			Message: "the " + noun + " is too large",
+		}
+	case errors.Is(err, summary.ErrTooManyRedirects):
+		return http.StatusBadGateway, &ErrorResponse{
+			Code:    ErrCodeTooManyRedirects,
+			Message: "the " + noun + " redirected too many times",
+		}
+	case errors.As(err, &statusErr):
+		return http.StatusBadGateway, &ErrorResponse{
+			Code:           ErrCodeUpstreamError,
+			Message:        fmt.Sprintf("the %s's server responded with status code %d", noun, statusErr.StatusCode),
+			UpstreamStatus: statusErr.StatusCode,
+		}
+	default:
+		return http.StatusBadGateway, &ErrorResponse{
+			Code:    ErrCodeUpstreamError,
+			Message: "error fetching the " + noun,
 		}
 	}
-	return
 }
-
-func absoluteURL (u string) (abs string) {
-	relative, _ := url.Parse(u)
-	base, _ := url.Parse("http://test.com")
-	abs = base.ResolveReference(relative).String()
-	return 
-}
\ No newline at end of file
@@ -2,406 +2,556 @@ package handlers
 
 import (
 	"encoding/json"
-	"io"
-	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"reflect"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-hansol9718/servers/gateway/summary"
 )
 
-func TestExtractSummary(t *testing.T) {
-	pagePrologue := "<html><head>"
-	pageEiplogue := "</head><body></body></html>"
-	pageURL := "http://test.com/test.html"
+func TestSummaryHandler(t *testing.T) {
+	//verify that response has
+	// - correct response status code
+	// - correct Content-Type header
+	resp := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/v1/summary?url=http://ogp.me", nil)
+	ctx := &Context{Summarizer: summary.NewSummarizer()}
+	ctx.SummaryHandler(resp, req)
+	if resp.Code != http.StatusOK {
+		t.Errorf("incorrect response status code: expected %d but got %d", http.StatusOK, resp.Code)
+	}
+	expectedctype := "application/json"
+	ctype := resp.Header().Get("Content-Type")
+	if len(ctype) == 0 {
+		t.Errorf("No `Content-Type` header found in the response: must be there start with `%s`", expectedctype)
+	} else if !strings.HasPrefix(ctype, expectedctype) {
+		t.Errorf("incorrect `Content-Type` header value: expected it to start with `%s` but got `%s`", expectedctype, ctype)
+	}
+}
+
+func TestSummaryHandlerEmbed(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	mux.HandleFunc("/video.html", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(`<html><head><title>test video</title>
+			<link rel="alternate" type="application/json+oembed" href="/oembed?url=` + server.URL + `/video.html">
+			</head></html>`))
+	})
+	mux.HandleFunc("/oembed", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"version": "1.0", "type": "video", "html": "<iframe></iframe>", "width": 640, "height": 360}`))
+	})
+
+	//the test server is on the loopback network,
+	//which summaries may not normally be fetched from
+	summarizer := summary.NewSummarizer()
+	summarizer.Fetcher = summary.NewFetcher()
+	summarizer.Fetcher.Allow, _ = summary.ParseNetworks("127.0.0.0/8, ::1")
+
+	resp := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/v1/summary?url="+server.URL+"/video.html", nil)
+	ctx := &Context{Summarizer: summarizer}
+	ctx.SummaryHandler(resp, req)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("incorrect response status code: expected %d but got %d", http.StatusOK, resp.Code)
+	}
+
+	pageSummary := &summary.PageSummary{}
+	if err := json.NewDecoder(resp.Body).Decode(pageSummary); err != nil {
+		t.Fatalf("error decoding response body: %v", err)
+	}
+	if pageSummary.Embed == nil {
+		t.Fatal("expected an `embed` object in the response, but there wasn't one")
+	}
+	if pageSummary.Embed.Type != "video" || pageSummary.Embed.HTML != "<iframe></iframe>" {
+		t.Errorf("incorrect embed: expected a video with html %q but got a %s with html %q",
+			"<iframe></iframe>", pageSummary.Embed.Type, pageSummary.Embed.HTML)
+	}
+}
+
+func TestSummaryHandlerIconSize(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(`<html><head>
+			<link rel="icon" href="/16.png" sizes="16x16">
+			<link rel="icon" href="/64.png" sizes="64x64">
+			<link rel="apple-touch-icon" href="/180.png" sizes="180x180">
+			</head></html>`))
+	}))
+	defer server.Close()
+
+	summarizer := summary.NewSummarizer()
+	summarizer.Fetcher = summary.NewFetcher()
+	summarizer.Fetcher.Allow, _ = summary.ParseNetworks("127.0.0.0/8, ::1")
+	summarizer.Cache = summary.NewMemCache(10)
+	ctx := &Context{Summarizer: summarizer}
+
 	cases := []struct {
-		name            string
-		hint            string
-		html            string
-		expectedSummary *PageSummary
+		name           string
+		hint           string
+		iconSize       string
+		expectedStatus int
+		expectedIcon   string
 	}{
 		{
-			"Open Graph Type",
-			`Make sure you are reading the <meta property="og:type" content="..."> element`,
-			pagePrologue + `<meta property="og:type" content="test type">` + pageEiplogue,
-			&PageSummary{
-				Type: "test type",
-			},
-		},
-		{
-			"Open Graph URL",
-			`Make sure you are reading the <meta property="og:url" content="..."> element`,
-			pagePrologue + `<meta property="og:url" content="http://test.com">` + pageEiplogue,
-			&PageSummary{
-				URL: "http://test.com",
-			},
-		},
-		{
-			"Open Graph Title",
-			`Make sure you are reading the <meta property="og:title" content="..."> element`,
-			pagePrologue + `<meta property="og:title" content="test title">` + pageEiplogue,
-			&PageSummary{
-				Title: "test title",
-			},
-		},
-		{
-			"Open Graph Site name",
-			`Make sure you are reading the <meta property="og:site_name" content="..."> element`,
-			pagePrologue + `<meta property="og:site_name" content="test site name">` + pageEiplogue,
-			&PageSummary{
-				SiteName: "test site name",
-			},
-		},
-		{
-			"Open Graph Description",
-			`Make sure you are reading the <meta property="og:description" content="..."> element`,
-			pagePrologue + `<meta property="og:description" content="test description">` + pageEiplogue,
-			&PageSummary{
-				Description: "test description",
-			},
-		},
-		{
-			"Open Graph Image",
-			`Make sure you are reading the <meta property="og:image" content="..."> element`,
-			pagePrologue + `<meta property="og:image" content="http://test.com/test.png">` + pageEiplogue,
-			&PageSummary{
-				Images: []*PreviewImage{
-					{
-						URL: "http://test.com/test.png",
-					},
-				},
-			},
-		},
-		{
-			"Open Graph Structured Image",
-			`Make sure you are handling the image structured properties, as described in http://ogp.me/#structured`,
-			pagePrologue + `
-			<meta property="og:image" content="http://test.com/test.png">
-			<meta property="og:image:secure_url" content="https://test.com/test.png">
-			<meta property="og:image:type" content="image/png">
-			<meta property="og:image:width" content="300">
-			<meta property="og:image:height" content="300">
-			<meta property="og:image:alt" content="test alt">
-			` + pageEiplogue,
-			&PageSummary{
-				Images: []*PreviewImage{
-					{
-						URL:       "http://test.com/test.png",
-						SecureURL: "https://test.com/test.png",
-						Type:      "image/png",
-						Width:     300,
-						Height:    300,
-						Alt:       "test alt",
-					},
-				},
-			},
+			"Default Size",
+			"Without an iconSize, the icon should be chosen for the default size",
+			"",
+			http.StatusOK,
+			"/64.png",
 		},
 		{
-			"Open Graph Multiple Images",
-			`Make sure you are handling multiple images, as described in http://ogp.me/#array`,
-			pagePrologue + `
-			<meta property="og:image" content="http://test.com/test1.png">
-			<meta property="og:image:width" content="100">
-			<meta property="og:image:height" content="100">
-			<meta property="og:image:alt" content="test alt 1">
-			<meta property="og:image" content="http://test.com/test2.png">
-			<meta property="og:image" content="http://test.com/test3.png">
-			<meta property="og:image:alt" content="test alt 3">
-			` + pageEiplogue,
-			&PageSummary{
-				Images: []*PreviewImage{
-					{
-						URL:    "http://test.com/test1.png",
-						Width:  100,
-						Height: 100,
-						Alt:    "test alt 1",
-					},
-					{
-						URL: "http://test.com/test2.png",
-					},
-					{
-						URL: "http://test.com/test3.png",
-						Alt: "test alt 3",
-					},
-				},
-			},
+			"Requested Size",
+			"The icon should be chosen for the requested iconSize",
+			"120",
+			http.StatusOK,
+			"/180.png",
 		},
 		{
-			"All Open Graph Props",
-			"Make sure you are handling all of the Open Graph properties listed in the assignment",
-			pagePrologue + `
-			<meta property="og:type" content="test type">
-			<meta property="og:url" content="http://test.com">
-			<meta property="og:title" content="test title">
-			<meta property="og:site_name" content="test site name">
-			<meta property="og:description" content="test description">
-			<meta property="og:image" content="http://test.com/test.png">
-			` + pageEiplogue,
-			&PageSummary{
-				Type:        "test type",
-				URL:         "http://test.com",
-				Title:       "test title",
-				SiteName:    "test site name",
-				Description: "test description",
-				Images: []*PreviewImage{
-					{
-						URL: "http://test.com/test.png",
-					},
-				},
-			},
+			"Cached Summary",
+			"Choosing an icon for one request shouldn't change the cached summary",
+			"",
+			http.StatusOK,
+			"/64.png",
 		},
 		{
-			"HTML Title",
-			`Make sure you get the page title from the <title> element if not Open Graph title property is available`,
-			pagePrologue + `<title>HTML Page Title</title>` + pageEiplogue,
-			&PageSummary{
-				Title: "HTML Page Title",
-			},
+			"Invalid Size",
+			"iconSize must be a positive number",
+			"-1",
+			http.StatusBadRequest,
+			"",
 		},
+	}
+
+	for _, c := range cases {
+		resp := httptest.NewRecorder()
+		target := "/v1/summary?url=" + url.QueryEscape(server.URL+"/page.html")
+		if len(c.iconSize) > 0 {
+			target += "&iconSize=" + c.iconSize
+		}
+		req, _ := http.NewRequest("GET", target, nil)
+		ctx.SummaryHandler(resp, req)
+		if resp.Code != c.expectedStatus {
+			t.Errorf("case %s: incorrect response status code: expected %d but got %d\nHINT: %s",
+				c.name, c.expectedStatus, resp.Code, c.hint)
+			continue
+		}
+		if resp.Code != http.StatusOK {
+			continue
+		}
+		pageSummary := &summary.PageSummary{}
+		if err := json.NewDecoder(resp.Body).Decode(pageSummary); err != nil {
+			t.Fatalf("case %s: error decoding response body: %v", c.name, err)
+		}
+		if pageSummary.Icon == nil || pageSummary.Icon.URL != server.URL+c.expectedIcon {
+			t.Errorf("case %s: incorrect icon: expected %s but got %+v\nHINT: %s",
+				c.name, server.URL+c.expectedIcon, pageSummary.Icon, c.hint)
+		}
+		if len(pageSummary.Icons) != 3 {
+			t.Errorf("case %s: expected all 3 icons, but got %d", c.name, len(pageSummary.Icons))
+		}
+	}
+}
+
+func TestSummaryHandlerDeep(t *testing.T) {
+	story := "The ferry will run every half hour in the summer, the county said, and every hour in the winter."
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(`<html><head><title>Ferry schedule</title></head>
+			<body><article><p>` + story + `</p></article></body></html>`))
+	}))
+	defer server.Close()
+
+	summarizer := summary.NewSummarizer()
+	summarizer.Fetcher = summary.NewFetcher()
+	summarizer.Fetcher.Allow, _ = summary.ParseNetworks("127.0.0.0/8, ::1")
+	summarizer.Cache = summary.NewMemCache(10)
+	ctx := &Context{Summarizer: summarizer}
+
+	//each case is a request made after the previous one
+	cases := []struct {
+		name            string
+		hint            string
+		deep            string
+		expectedStatus  int
+		expectedExcerpt string
+		expectedCache   string
+	}{
 		{
-			"HTML Description",
-			`Make sure you get the page description from the <meta name="author" content="..."> tag if no Open Graph description is available`,
-			pagePrologue + `<meta name="description" content="test description">` + pageEiplogue,
-			&PageSummary{
-				Description: "test description",
-			},
+			"Shallow",
+			"Without deep, only the page's head should be read",
+			"",
+			http.StatusOK,
+			"",
+			"MISS",
 		},
 		{
-			"HTML Author",
-			`Make sure you get the page author from the <meta name="author" content="..."> tag`,
-			pagePrologue + `<meta name="author" content="test author">` + pageEiplogue,
-			&PageSummary{
-				Author: "test author",
-			},
+			"Deep",
+			"With deep, the page's main content should be read, rather than the cached shallow summary used",
+			"1",
+			http.StatusOK,
+			story,
+			"MISS",
 		},
 		{
-			"HTML Keywords With Spaces",
-			`Make sure you get the page keywords from the <meta name="keywords" content="..."> tag`,
-			pagePrologue + `<meta name="keywords" content="one, two, three">` + pageEiplogue,
-			&PageSummary{
-				Keywords: []string{"one", "two", "three"},
-			},
+			"Shallow Cached",
+			"The deep summary must not replace the cached shallow one",
+			"false",
+			http.StatusOK,
+			"",
+			"HIT",
 		},
 		{
-			"HTML Keywords With No Spaces",
-			`Make sure you get the page keywords from the <meta name="keywords" content="..."> tag`,
-			pagePrologue + `<meta name="keywords" content="one,two,three">` + pageEiplogue,
-			&PageSummary{
-				Keywords: []string{"one", "two", "three"},
-			},
+			"Deep Cached",
+			"Deep summaries should be cached too",
+			"true",
+			http.StatusOK,
+			story,
+			"HIT",
 		},
 		{
-			"HTML Icon",
-			`Make sure you get the page icon from the <link rel="icon" href="..."> tag`,
-			pagePrologue + `<link rel="icon" href="http://test.com/test.png">` + pageEiplogue,
-			&PageSummary{
-				Icon: &PreviewImage{
-					URL: "http://test.com/test.png",
-				},
-			},
+			"Invalid",
+			"deep must be true or false",
+			"sometimes",
+			http.StatusBadRequest,
+			"",
+			"",
 		},
+	}
+
+	for _, c := range cases {
+		resp := httptest.NewRecorder()
+		target := "/v1/summary?url=" + url.QueryEscape(server.URL+"/page.html")
+		if len(c.deep) > 0 {
+			target += "&deep=" + c.deep
+		}
+		req, _ := http.NewRequest("GET", target, nil)
+		ctx.SummaryHandler(resp, req)
+		if resp.Code != c.expectedStatus {
+			t.Errorf("case %s: incorrect response status code: expected %d but got %d\nHINT: %s",
+				c.name, c.expectedStatus, resp.Code, c.hint)
+			continue
+		}
+		if resp.Code != http.StatusOK {
+			continue
+		}
+		pageSummary := &summary.PageSummary{}
+		if err := json.NewDecoder(resp.Body).Decode(pageSummary); err != nil {
+			t.Fatalf("case %s: error decoding response body: %v", c.name, err)
+		}
+		if pageSummary.Excerpt != c.expectedExcerpt || resp.Header().Get("X-Cache") != c.expectedCache {
+			t.Errorf("case %s: expected excerpt %q from cache status %s, but got %q from %s\nHINT: %s",
+				c.name, c.expectedExcerpt, c.expectedCache, pageSummary.Excerpt, resp.Header().Get("X-Cache"), c.hint)
+		}
+	}
+}
+
+func TestSummaryHandlerDeadline(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	//the fetcher's own timeout is longer than the handler's deadline
+	summarizer := summary.NewSummarizer()
+	summarizer.Fetcher = summary.NewFetcher()
+	summarizer.Fetcher.Allow, _ = summary.ParseNetworks("127.0.0.0/8, ::1")
+	ctx := &Context{Summarizer: summarizer, SummaryTimeout: 200 * time.Millisecond}
+
+	start := time.Now()
+	resp := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/v1/summary?url="+url.QueryEscape(server.URL), nil)
+	ctx.SummaryHandler(resp, req)
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("the summary should end at the handler's deadline, but took %v", elapsed)
+	}
+	errResp := &ErrorResponse{}
+	json.NewDecoder(resp.Body).Decode(errResp)
+	if resp.Code != http.StatusGatewayTimeout || errResp.Code != ErrCodeUpstreamTimeout {
+		t.Errorf("summaries that miss the deadline should be an upstream timeout, but got %d %+v", resp.Code, errResp)
+	}
+}
+
+func TestSummaryHandlerErrors(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/slow.html", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(5 * time.Second):
+		case <-r.Context().Done():
+		}
+	})
+	mux.HandleFunc("/huge.html", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte("<html><head><title>" + strings.Repeat("a", 10000) + "</title></head></html>"))
+	})
+	mux.HandleFunc("/loop.html", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/loop.html", http.StatusFound)
+	})
+	mux.HandleFunc("/image.png", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte("not really a png"))
+	})
+	mux.HandleFunc("/broken.html", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "broken", http.StatusInternalServerError)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	//a server that has gone away, so can't be connected to
+	gone := httptest.NewServer(mux)
+	gone.Close()
+
+	summarizer := summary.NewSummarizer()
+	summarizer.Fetcher = summary.NewFetcher()
+	summarizer.Fetcher.Allow, _ = summary.ParseNetworks("127.0.0.0/8, ::1")
+	summarizer.Fetcher.Timeout = 100 * time.Millisecond
+	summarizer.Fetcher.MaxBytes = 1000
+	ctx := &Context{Summarizer: summarizer}
+
+	cases := []struct {
+		name                   string
+		hint                   string
+		URL                    string
+		expectedStatus         int
+		expectedCode           string
+		expectedUpstreamStatus int
+	}{
 		{
-			"HTML Icon With Sizes",
-			`Make sure you parse the 'sizes' attribute to get the icon height and width`,
-			pagePrologue + `<link rel="icon" href="http://test.com/test.png" sizes="100x200">` + pageEiplogue,
-			&PageSummary{
-				Icon: &PreviewImage{
-					URL:    "http://test.com/test.png",
-					Height: 100,
-					Width:  200,
-				},
-			},
+			"Missing URL",
+			"Requests without a url are bad requests",
+			"",
+			http.StatusBadRequest,
+			ErrCodeInvalidURL,
+			0,
 		},
 		{
-			"HTML Icon With Size Any",
-			`The sizes attribute of the <link rel="icon"> tag may have the value "any" to indicate no size preference`,
-			pagePrologue + `<link rel="icon" href="http://test.com/test.png" sizes="any">` + pageEiplogue,
-			&PageSummary{
-				Icon: &PreviewImage{
-					URL: "http://test.com/test.png",
-				},
-			},
+			"Unsupported Scheme",
+			"Only http and https URLs can be summarized",
+			"ftp://example.com/",
+			http.StatusBadRequest,
+			ErrCodeInvalidURL,
+			0,
 		},
 		{
-			"HTML Icon With Type",
-			`Make sure you read the 'type' attribute to get the icon type`,
-			pagePrologue + `<link rel="icon" href="http://test.com/test.png" type="image/png">` + pageEiplogue,
-			&PageSummary{
-				Icon: &PreviewImage{
-					URL:  "http://test.com/test.png",
-					Type: "image/png",
-				},
-			},
+			"Forbidden Address",
+			"URLs on private networks can't be summarized",
+			"http://10.0.0.1/",
+			http.StatusBadRequest,
+			ErrCodeForbiddenURL,
+			0,
 		},
 		{
-			"Self-Closing Meta",
-			"Make sure you are handling self-closing <meta ... /> tags",
-			pagePrologue + `<meta property="og:title" content="Open Graph Title"/>` + pageEiplogue,
-			&PageSummary{
-				Title: "Open Graph Title",
-			},
+			"Not HTML",
+			"URLs that aren't HTML pages are an unsupported media type",
+			server.URL + "/image.png",
+			http.StatusUnsupportedMediaType,
+			ErrCodeUnsupportedMediaType,
+			0,
 		},
 		{
-			"Attribute Order",
-			"HTML elements and attributes can be in any order; don't assume a particular order",
-			pagePrologue + `
-			<meta content="test title" property="og:title">
-			<meta content="test type" property="og:type">
-			<meta content="http://test.com/test.png" property="og:image">
-			<meta content="test site name" property="og:site_name">
-			<meta content="test description" property="og:description">
-			<meta content="http://test.com" property="og:url">
-			` + pageEiplogue,
-			&PageSummary{
-				Type:        "test type",
-				URL:         "http://test.com",
-				Title:       "test title",
-				SiteName:    "test site name",
-				Description: "test description",
-				Images: []*PreviewImage{
-					{
-						URL: "http://test.com/test.png",
-					},
-				},
-			},
+			"Not Found",
+			"Pages that aren't found are an upstream failure, with the upstream status",
+			server.URL + "/missing.html",
+			http.StatusBadGateway,
+			ErrCodeUpstreamError,
+			http.StatusNotFound,
 		},
 		{
-			"HTML and Open Graph Title",
-			`Make sure the <meta property="og:title"> overrides the HTML <title> element`,
-			pagePrologue + `
-			<meta property="og:title" content="Open Graph Title"/>
-			<title>HTML Page Title</title>` + pageEiplogue,
-			&PageSummary{
-				Title: "Open Graph Title",
-			},
+			"Server Error",
+			"Pages whose server fails are an upstream failure, with the upstream status",
+			server.URL + "/broken.html",
+			http.StatusBadGateway,
+			ErrCodeUpstreamError,
+			http.StatusInternalServerError,
 		},
 		{
-			"HTML and Open Graph Description",
-			`Make sure the <meta property="og:description"> overrides the HTML <meta name="description"> element`,
-			pagePrologue + `
-			<meta property="og:description" content="og description"/>
-			<meta name="description" content="html description">` + pageEiplogue,
-			&PageSummary{
-				Description: "og description",
-			},
+			"Server Down",
+			"Pages whose server can't be reached are an upstream failure",
+			gone.URL + "/index.html",
+			http.StatusBadGateway,
+			ErrCodeUpstreamError,
+			0,
 		},
 		{
-			"Relative Image URL",
-			"Remember to resolve relative image URLs to absolute ones using the page URL as a base",
-			pagePrologue + `<meta property="og:image" content="/test.png"/>` + pageEiplogue,
-			&PageSummary{
-				Images: []*PreviewImage{
-					{
-						URL: "http://test.com/test.png",
-					},
-				},
-			},
+			"Timeout",
+			"Pages that take too long to fetch are a gateway timeout",
+			server.URL + "/slow.html",
+			http.StatusGatewayTimeout,
+			ErrCodeUpstreamTimeout,
+			0,
 		},
 		{
-			"Relative Icon URL",
-			"Remember to resolve relative HTML Icon URLs to absolute ones using the page URL as a base",
-			pagePrologue + `<link rel="icon" href="/test.png"/>` + pageEiplogue,
-			&PageSummary{
-				Icon: &PreviewImage{
-					URL: "http://test.com/test.png",
-				},
-			},
+			"Too Large",
+			"Pages too large to fetch are a bad gateway",
+			server.URL + "/huge.html",
+			http.StatusBadGateway,
+			ErrCodeUpstreamTooLarge,
+			0,
 		},
 		{
-			"Empty Input",
-			"A URL might return an empty page",
-			"",
-			&PageSummary{},
+			"Too Many Redirects",
+			"Pages that redirect too many times are a bad gateway",
+			server.URL + "/loop.html",
+			http.StatusBadGateway,
+			ErrCodeTooManyRedirects,
+			0,
 		},
 	}
 
 	for _, c := range cases {
-		summary, err := extractSummary(pageURL, ioutil.NopCloser(strings.NewReader(c.html)))
-		if err != nil && err != io.EOF {
-			t.Errorf("case %s: unexpected error %v\nHINT: %s\n", c.name, err, c.hint)
+		resp := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/v1/summary?url="+url.QueryEscape(c.URL), nil)
+		ctx.SummaryHandler(resp, req)
+		if resp.Code != c.expectedStatus {
+			t.Errorf("case %s: incorrect response status code: expected %d but got %d\nHINT: %s",
+				c.name, c.expectedStatus, resp.Code, c.hint)
 		}
-		if summary == nil {
-			t.Errorf("case: %s: returned summary struct is nil", c.name)
+		if ctype := resp.Header().Get("Content-Type"); !strings.HasPrefix(ctype, "application/json") {
+			t.Errorf("case %s: incorrect `Content-Type` header value: expected it to start with `application/json` but got `%s`",
+				c.name, ctype)
+		}
+		errResp := &ErrorResponse{}
+		if err := json.NewDecoder(resp.Body).Decode(errResp); err != nil {
+			t.Errorf("case %s: error decoding response body: %v", c.name, err)
 			continue
 		}
-		if !reflect.DeepEqual(summary, c.expectedSummary) {
-			//reflect.DeepEqual considers a non-nil empty slice to be different
-			//than a nill slice, so check for those cases first
-			if c.expectedSummary.Images == nil && summary.Images != nil {
-				t.Errorf("case %s: expected nil `Images` slice, but got a non-nill slice", c.name)
-			} else if c.expectedSummary.Keywords == nil && summary.Keywords != nil {
-				t.Errorf("case %s: expected nil `Keywords` slice, but got a non-nill slice", c.name)
-			} else if c.expectedSummary.Icon == nil && summary.Icon != nil {
-				t.Errorf("case %s: expected nil `Icon` pointer, but got a non-nill pointer", c.name)
-			} else {
-				expectedJSON, _ := json.MarshalIndent(c.expectedSummary, "", "  ")
-				actualJSON, _ := json.MarshalIndent(summary, "", "  ")
-				t.Errorf("case %s: incorrect result:\nEXPECTED: %s\nACTUAL: %s\nHINT: %s\n",
-					c.name, string(expectedJSON), string(actualJSON), c.hint)
-			}
+		if errResp.Code != c.expectedCode || errResp.UpstreamStatus != c.expectedUpstreamStatus {
+			t.Errorf("case %s: incorrect error: expected code %q and upstream status %d but got %q and %d\nHINT: %s",
+				c.name, c.expectedCode, c.expectedUpstreamStatus, errResp.Code, errResp.UpstreamStatus, c.hint)
+		}
+		if len(errResp.Message) == 0 {
+			t.Errorf("case %s: expected an error message but there wasn't one", c.name)
 		}
 	}
 }
 
-func TestFetchHTML(t *testing.T) {
+func TestSummariesHandler(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/page.html", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(`<html><head><title>test page</title></head></html>`))
+	})
+	mux.HandleFunc("/slow.html", func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	summarizer := summary.NewSummarizer()
+	summarizer.Fetcher = summary.NewFetcher()
+	summarizer.Fetcher.Allow, _ = summary.ParseNetworks("127.0.0.0/8, ::1")
+	ctx := &Context{Summarizer: summarizer, MaxBatchSize: 3, BatchTimeout: 200 * time.Millisecond}
+
+	page := server.URL + "/page.html"
 	cases := []struct {
-		name        string
-		hint        string
-		URL         string
-		expectError bool
+		name              string
+		hint              string
+		method            string
+		contentType       string
+		body              string
+		expectedStatus    int
+		expectedSummaries map[string]*BatchSummary
 	}{
 		{
-			"Valid URL",
-			"This is a valid HTML page, so this should work",
-			"https://info344-a17.github.io/tests/ogall.html",
-			false,
+			"Batch",
+			"Each distinct URL should map to its summary, or the error that prevented it",
+			"POST",
+			"application/json",
+			`["` + page + `", "` + page + `", "ftp://test.com/", "` + server.URL + `/slow.html"]`,
+			http.StatusOK,
+			map[string]*BatchSummary{
+				page: {Summary: &summary.PageSummary{Title: "test page"}},
+				"ftp://test.com/": {Error: &ErrorResponse{
+					Code:    ErrCodeInvalidURL,
+					Message: "the url must be an absolute http or https URL",
+				}},
+				server.URL + "/slow.html": {Error: &ErrorResponse{
+					Code:    ErrCodeUpstreamTimeout,
+					Message: "timed out fetching the page",
+				}},
+			},
 		},
 		{
-			"Not Found URL",
-			"Remember to check the response status code",
-			"https://info344-a17.github.io/tests/not-found.html",
-			true,
+			"Empty Batch",
+			"An empty batch has no summaries",
+			"POST",
+			"application/json",
+			`[]`,
+			http.StatusOK,
+			map[string]*BatchSummary{},
 		},
 		{
-			"Non-HTML URL",
-			"Remember to check the response content-type to ensure it's an HTML page",
-			"https://info344-a17.github.io/tests/test.png",
-			true,
+			"Too Many URLs",
+			"Batches larger than the maximum are bad requests",
+			"POST",
+			"application/json",
+			`["http://a.test/", "http://b.test/", "http://c.test/", "http://d.test/"]`,
+			http.StatusBadRequest,
+			nil,
+		},
+		{
+			"Invalid JSON",
+			"The body must be a JSON array of URLs",
+			"POST",
+			"application/json",
+			`{"url": "http://a.test/"}`,
+			http.StatusBadRequest,
+			nil,
+		},
+		{
+			"Not JSON",
+			"The body must be JSON",
+			"POST",
+			"text/plain",
+			page,
+			http.StatusUnsupportedMediaType,
+			nil,
+		},
+		{
+			"Wrong Method",
+			"Batches must be POSTed",
+			"GET",
+			"",
+			"",
+			http.StatusMethodNotAllowed,
+			nil,
 		},
 	}
 
 	for _, c := range cases {
-		stream, err := fetchHTML(c.URL)
-
-		if err != nil && !c.expectError {
-			t.Errorf("case %s: unexpected error %v\nHINT: %s", c.name, err, c.hint)
+		resp := httptest.NewRecorder()
+		req, _ := http.NewRequest(c.method, "/v1/summaries", strings.NewReader(c.body))
+		if len(c.contentType) > 0 {
+			req.Header.Set("Content-Type", c.contentType)
 		}
-		if c.expectError && err == nil {
-			t.Errorf("case %s: expected error but didn't get one\nHINT: %s", c.name, c.hint)
+		start := time.Now()
+		ctx.SummariesHandler(resp, req)
+		if elapsed := time.Since(start); elapsed > 2*time.Second {
+			t.Errorf("case %s: the batch should end at its deadline, but took %v", c.name, elapsed)
 		}
-
-		if stream != nil {
-			stream.Close()
+		if resp.Code != c.expectedStatus {
+			t.Errorf("case %s: incorrect response status code: expected %d but got %d\nHINT: %s",
+				c.name, c.expectedStatus, resp.Code, c.hint)
+			continue
+		}
+		if c.expectedSummaries == nil {
+			continue
+		}
+		summaries := map[string]*BatchSummary{}
+		if err := json.NewDecoder(resp.Body).Decode(&summaries); err != nil {
+			t.Fatalf("case %s: error decoding response body: %v", c.name, err)
+		}
+		if !reflect.DeepEqual(summaries, c.expectedSummaries) {
+			expectedJSON, _ := json.MarshalIndent(c.expectedSummaries, "", "  ")
+			actualJSON, _ := json.MarshalIndent(summaries, "", "  ")
+			t.Errorf("case %s: incorrect summaries:\nEXPECTED: %s\nACTUAL: %s\nHINT: %s\n",
+				c.name, string(expectedJSON), string(actualJSON), c.hint)
 		}
-	}
-}
-
-func TestSummaryHandler(t *testing.T) {
-	//verify that response has
-	// - correct response status code
-	// - correct Content-Type header
-	resp := httptest.NewRecorder()
-	req, _ := http.NewRequest("GET", "/v1/summary?url=http://ogp.me", nil)
-	SummaryHandler(resp, req)
-	if resp.Code != http.StatusOK {
-		t.Errorf("incorrect response status code: expected %d but got %d", http.StatusOK, resp.Code)
-	}
-	expectedctype := "application/json"
-	ctype := resp.Header().Get("Content-Type")
-	if len(ctype) == 0 {
-		t.Errorf("No `Content-Type` header found in the response: must be there start with `%s`", expectedctype)
-	} else if !strings.HasPrefix(ctype, expectedctype) {
-		t.Errorf("incorrect `Content-Type` header value: expected it to start with `%s` but got `%s`", expectedctype, ctype)
 	}
 }
@@ -0,0 +1,94 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+//Fields are the fields of a log entry
+type Fields map[string]interface{}
+
+//Logger writes log entries as lines of JSON
+type Logger struct {
+	mx  sync.Mutex
+	out io.Writer
+	now func() time.Time
+}
+
+//Default is the logger that writes to standard error
+var Default = New(os.Stderr)
+
+//New constructs a new Logger writing to `out`
+func New(out io.Writer) *Logger {
+	return &Logger{out: out, now: time.Now}
+}
+
+//Log writes an entry with `fields`, plus a "time" field. A nil
+//Logger discards entries, so that logging can be optional.
+func (l *Logger) Log(fields Fields) {
+	if l == nil {
+		return
+	}
+	entry := make(Fields, len(fields)+1)
+	for name, value := range fields {
+		entry[name] = value
+	}
+	entry["time"] = l.now().UTC().Format(time.RFC3339Nano)
+	j, err := json.Marshal(entry)
+	if err != nil {
+		j, _ = json.Marshal(Fields{"time": entry["time"], "error": "error encoding log entry: " + err.Error()})
+	}
+	l.mx.Lock()
+	defer l.mx.Unlock()
+	l.out.Write(append(j, '\n'))
+}
+
+//maxRequestIDLength is the longest request ID that is propagated
+const maxRequestIDLength = 128
+
+//requestIDKey is the context key of the request ID
+type requestIDKey struct{}
+
+//NewRequestID returns a new random request ID
+func NewRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+//ValidRequestID reports whether `id`, typically from a client or
+//another proxy, is reasonable to propagate and log: not empty, not
+//too long, and only letters, digits and "-", "_", ".", or ":"
+func ValidRequestID(id string) bool {
+	if len(id) == 0 || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+//WithRequestID returns a copy of `ctx` carrying the request ID `id`
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+//RequestID returns the request ID `ctx` carries, or "" if none
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
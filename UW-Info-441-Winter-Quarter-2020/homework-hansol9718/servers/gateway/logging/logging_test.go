@@ -0,0 +1,69 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogger(t *testing.T) {
+	buf := &bytes.Buffer{}
+	l := New(buf)
+	l.now = func() time.Time { return time.Date(2020, 2, 1, 12, 0, 0, 0, time.UTC) }
+	l.Log(Fields{"msg": "request", "status": 200})
+	l.Log(Fields{"msg": "line\nbreak"})
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected one line per entry, but got %q", buf.String())
+	}
+	entry := Fields{}
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatalf("error decoding log entry %q: %v", lines[0], err)
+	}
+	expected := Fields{"msg": "request", "status": 200.0, "time": "2020-02-01T12:00:00Z"}
+	for name, value := range expected {
+		if entry[name] != value {
+			t.Errorf("incorrect %s: expected %v but got %v", name, value, entry[name])
+		}
+	}
+
+	var nilLogger *Logger
+	nilLogger.Log(Fields{"msg": "discarded"})
+}
+
+func TestRequestID(t *testing.T) {
+	cases := []struct {
+		id    string
+		valid bool
+	}{
+		{NewRequestID(), true},
+		{"req-1_2.3:4", true},
+		{"", false},
+		{strings.Repeat("a", maxRequestIDLength), true},
+		{strings.Repeat("a", maxRequestIDLength+1), false},
+		{"id\nforged log line", false},
+		{"id with spaces", false},
+		{`"quoted"`, false},
+	}
+
+	for _, c := range cases {
+		if valid := ValidRequestID(c.id); valid != c.valid {
+			t.Errorf("case %q: expected valid to be %t but got %t", c.id, c.valid, valid)
+		}
+	}
+
+	if NewRequestID() == NewRequestID() {
+		t.Errorf("request IDs should be unique")
+	}
+	ctx := WithRequestID(context.Background(), "abc")
+	if id := RequestID(ctx); id != "abc" {
+		t.Errorf("incorrect request ID: expected %q but got %q", "abc", id)
+	}
+	if id := RequestID(context.Background()); id != "" {
+		t.Errorf("contexts without a request ID should return \"\", but got %q", id)
+	}
+}
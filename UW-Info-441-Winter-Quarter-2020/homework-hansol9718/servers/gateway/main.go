@@ -1,29 +1,35 @@
 package main
 import (
+	"context"
+	"crypto/tls"
 	"fmt"
 	"os"
+	"os/signal"
 	"log"
+	"net"
 	"net/http"
+	"strings"
+	"syscall"
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-hansol9718/servers/gateway/certs"
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-hansol9718/servers/gateway/config"
 	"github.com/UW-Info-441-Winter-Quarter-2020/homework-hansol9718/servers/gateway/handlers"
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-hansol9718/servers/gateway/logging"
 )
 
 //main is the main entry point for the server
 func main() {
-	addr := os.Getenv("ADDR")
-	if len(addr) == 0 {
-		addr = ":443"
+	//settings are read from environment variables, and from
+	//the file named by CONFIGFILE if set; see the config package
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
 	}
-	tlsKeyPath := os.Getenv("TLSKEY")
-    tlsCertPath := os.Getenv("TLSCERT")
-
-	if len(tlsKeyPath) == 0 {
-		fmt.Print("empty KeyPath")
-		os.Exit(3)
-	}
-	if len(tlsCertPath) == 0 {
-		fmt.Print("empty CertPath")
-		os.Exit(2)
+	ctx, err := cfg.NewContext()
+	if err != nil {
+		log.Fatal(err)
 	}
+
 	  /*
 	- Create a new mux for the web server.
 	*/
@@ -32,7 +38,53 @@ func main() {
 	- Tell the mux to call your handlers.SummaryHandler function
 	  when the "/v1/summary" URL path is requested.
 	  */
-	mux.HandleFunc("/v1/summary", handlers.SummaryHandler)
+	mux.HandleFunc("/v1/summary", ctx.SummaryHandler)
+	//link previews for many URLs at once, up to BATCHMAX of them
+	mux.HandleFunc("/v1/summaries", ctx.SummariesHandler)
+	//with IMAGEPROXYKEY and IMAGEPROXYORIGIN set, summaries' images
+	//are served through the gateway at signed /v1/imageproxy URLs
+	if ctx.ImageProxy != nil {
+		mux.Handle(handlers.ImageProxyPath, ctx.ImageProxy)
+	}
+
+	//user accounts are stored in the MySQL database at DSN,
+	//and their sessions are signed with SESSIONKEY
+	mux.HandleFunc("/v1/users", ctx.UsersHandler)
+	mux.HandleFunc("/v1/users/", ctx.SpecificUserHandler)
+	mux.HandleFunc("/v1/sessions", ctx.SessionsHandler)
+	mux.HandleFunc("/v1/sessions/", ctx.SpecificSessionHandler)
+
+	//background work, like health checks, stops with the server
+	background, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+
+	//requests for microservices, like the messages service, are
+	//proxied to them as configured in PROXYROUTES, for example
+	//"/v1/channels,/v1/messages=messages1:80,messages2:80".
+	//Requests are spread across each route's backends using the
+	//PROXYBALANCE strategy, and backends that don't respond to
+	//PROXYHEALTHPATH with a 2xx status are ejected until they do
+	for _, route := range cfg.ProxyRoutes {
+		balancer := handlers.NewBalancer(route.Targets)
+		balancer.Strategy = cfg.ProxyBalance
+		balancer.HealthPath = cfg.ProxyHealthPath
+		balancer.StartHealthChecks(background)
+		proxy := ctx.NewServiceProxy(balancer)
+		for _, prefix := range route.Prefixes {
+			prefix = strings.TrimSuffix(prefix, "/")
+			mux.Handle(prefix, proxy)
+			mux.Handle(prefix+"/", proxy)
+		}
+	}
+
+	//browser clients may call the API from the comma-separated
+	//origins in ALLOWEDORIGINS, or from any origin if it's not set
+	var handler http.Handler = handlers.NewCORS(mux, cfg.AllowedOrigins...)
+	if cfg.TLS() {
+		handler = handlers.NewHSTS(handler)
+	}
+	//every request is logged as a line of JSON on stderr
+	handler = handlers.NewRequestLogger(handler, logging.Default)
 
 	  /*
 	- Start a web server listening on the address you read from
@@ -40,6 +92,42 @@ func main() {
 	  the root handler. Use log.Fatal() to report any errors
 	  that occur when trying to start the web server.
 	*/
-	log.Printf("server is listening at %s...", addr)
-	log.Fatal(http.ListenAndServeTLS(addr, tlsCertPath, tlsKeyPath, mux))
+	//on SIGTERM or SIGINT, like when the container is stopped,
+	//in-flight requests are finished before the stores are closed
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGTERM, syscall.SIGINT)
+	srv := newServer(cfg.Addr, handler)
+	listeners := []*listener{{srv, srv.ListenAndServe}}
+	if cfg.TLS() {
+		//renewed certificates are picked up without restarting
+		certManager, err := certs.NewManager(cfg.TLSCertPath, cfg.TLSKeyPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		certManager.Watch(background, certs.DefaultReloadInterval)
+		srv.TLSConfig = &tls.Config{GetCertificate: certManager.GetCertificate}
+		listeners[0].listen = func() error {
+			return srv.ListenAndServeTLS("", "")
+		}
+	} else {
+		//DEVMODE without TLSKEY and TLSCERT, for local development
+		log.Printf("no TLS key and certificate: serving plain HTTP in dev mode")
+	}
+	//port 80 traffic is redirected to HTTPS when REDIRECTADDR is set
+	if len(cfg.RedirectAddr) > 0 {
+		_, port, _ := net.SplitHostPort(cfg.Addr)
+		redirectSrv := newServer(cfg.RedirectAddr, handlers.NewHTTPSRedirect(port))
+		listeners = append(listeners, &listener{redirectSrv, redirectSrv.ListenAndServe})
+		log.Printf("redirecting HTTP at %s to HTTPS...", cfg.RedirectAddr)
+	}
+	log.Printf("server is listening at %s...", cfg.Addr)
+	err = serve(stop, shutdownTimeout, listeners...)
+	stopBackground()
+	if closeErr := ctx.Close(); closeErr != nil {
+		log.Printf("error closing stores: %v", closeErr)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("server stopped")
 }
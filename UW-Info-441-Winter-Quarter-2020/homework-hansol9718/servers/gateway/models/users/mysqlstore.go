@@ -29,6 +29,9 @@ func (s *MySQLStore) GetByID(id int64) (*User, error) {
 	u := &User{}
 	err := s.db.QueryRow(GetID, id).Scan(&u.ID, &u.Email, &u.PassHash, &u.UserName,
 		&u.FirstName, &u.LastName, &u.PhotoURL)
+	if err == sql.ErrNoRows {
+		return nil, ErrUserNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -39,6 +42,9 @@ func (s *MySQLStore) GetByEmail(email string) (*User, error) {
 	u := &User{}
 	err := s.db.QueryRow(GetEmail, email).Scan(&u.ID, &u.Email, &u.PassHash, &u.UserName,
 		&u.FirstName, &u.LastName, &u.PhotoURL)
+	if err == sql.ErrNoRows {
+		return nil, ErrUserNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -50,6 +56,9 @@ func (s *MySQLStore) GetByUserName(username string) (*User, error) {
 	u := &User{}
 	err := s.db.QueryRow(GetUserName, username).Scan(&u.ID, &u.Email, &u.PassHash, &u.UserName,
 		&u.FirstName, &u.LastName, &u.PhotoURL)
+	if err == sql.ErrNoRows {
+		return nil, ErrUserNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -86,4 +95,7 @@ func (s *MySQLStore) Delete(id int64) error {
 	return nil
 }
 
-
+//Close closes the store's database
+func (s *MySQLStore) Close() error {
+	return s.db.Close()
+}
@@ -47,8 +47,8 @@ func TestGetByID(t *testing.T) {
 	mock.ExpectQuery(regexp.QuoteMeta(GetID)).WithArgs(2).WillReturnError(sql.ErrNoRows)
 	_, err = store.GetByID(2)
 
-	if err == nil {
-		t.Errorf("expected error: %v", sql.ErrNoRows)
+	if err != ErrUserNotFound {
+		t.Errorf("expected error: %v", ErrUserNotFound)
 	}
 	err = mock.ExpectationsWereMet()
 	if err != nil {
@@ -85,8 +85,8 @@ func TestGetByEmail(t *testing.T) {
 	mock.ExpectQuery(regexp.QuoteMeta(GetEmail)).WithArgs("idk@uw.edu").WillReturnError(sql.ErrNoRows)
 	_, err = store.GetByEmail("idk@uw.edu")
 
-	if err == nil {
-		t.Errorf("expected error: %v", sql.ErrNoRows)
+	if err != ErrUserNotFound {
+		t.Errorf("expected error: %v", ErrUserNotFound)
 	}
 	err = mock.ExpectationsWereMet()
 	if err != nil {
@@ -122,8 +122,8 @@ func TestGetByUserName(t *testing.T) {
 	mock.ExpectQuery(regexp.QuoteMeta(GetUserName)).WithArgs("ughugh").WillReturnError(sql.ErrNoRows)
 	_, err = store.GetByUserName("ughugh")
 
-	if err == nil {
-		t.Errorf("expected error: %v", sql.ErrNoRows)
+	if err != ErrUserNotFound {
+		t.Errorf("expected error: %v", ErrUserNotFound)
 	}
 	err = mock.ExpectationsWereMet()
 	if err != nil {
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+)
+
+//Server timeouts
+const (
+	//readTimeout is how long clients may take to send a request
+	readTimeout = 10 * time.Second
+	//writeTimeout is how long a response may take. It must be well over
+	//the summary handlers' deadlines, handlers.DefaultSummaryTimeout and
+	//BATCHTIMEOUT, so that slow summaries end with an upstream_timeout
+	//error rather than the connection being closed mid-response.
+	writeTimeout = 60 * time.Second
+	//idleTimeout is how long keep-alive connections are kept open
+	idleTimeout = 2 * time.Minute
+	//shutdownTimeout is how long in-flight requests may take
+	//to finish once the server is shutting down
+	shutdownTimeout = 30 * time.Second
+)
+
+//newServer constructs the gateway's http.Server
+func newServer(addr string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:         addr,
+		Handler:      handler,
+		ReadTimeout:  readTimeout,
+		WriteTimeout: writeTimeout,
+		IdleTimeout:  idleTimeout,
+	}
+}
+
+//listener is a server and the function that starts it
+type listener struct {
+	srv *http.Server
+	//listen starts the server, like srv.ListenAndServeTLS,
+	//returning once it stops
+	listen func() error
+}
+
+//serve runs the `listeners` until a signal is received from `stop`, or
+//any of them fails. They then all stop accepting connections and wait
+//up to `timeout` for in-flight requests to finish.
+func serve(stop <-chan os.Signal, timeout time.Duration, listeners ...*listener) error {
+	errs := make(chan error, len(listeners))
+	for _, l := range listeners {
+		go func(l *listener) {
+			errs <- l.listen()
+		}(l)
+	}
+
+	var serveErr error
+	select {
+	case serveErr = <-errs:
+	case sig := <-stop:
+		log.Printf("received %v, draining connections...", sig)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	shutdownErrs := make(chan error, len(listeners))
+	for _, l := range listeners {
+		go func(l *listener) {
+			shutdownErrs <- l.srv.Shutdown(ctx)
+		}(l)
+	}
+	for range listeners {
+		if err := <-shutdownErrs; err != nil && serveErr == nil {
+			serveErr = fmt.Errorf("error draining connections: %v", err)
+		}
+	}
+	return serveErr
+}
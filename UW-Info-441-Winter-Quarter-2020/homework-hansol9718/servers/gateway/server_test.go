@@ -0,0 +1,135 @@
+package main
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+//startTestServer serves `handler` on a loopback port until a signal
+//is sent on the returned channel, returning its URL and a channel
+//that receives the error serve() returns
+func startTestServer(t *testing.T, handler http.Handler, timeout time.Duration) (string, chan<- os.Signal, <-chan error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("error listening: %v", err)
+	}
+	srv := newServer(ln.Addr().String(), handler)
+	stop := make(chan os.Signal, 1)
+	done := make(chan error, 1)
+	go func() {
+		done <- serve(stop, timeout, &listener{srv, func() error { return srv.Serve(ln) }})
+	}()
+	return "http://" + ln.Addr().String(), stop, done
+}
+
+func TestServeDrainsInFlightRequests(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	url, stop, done := startTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.Write([]byte("finished"))
+	}), 5*time.Second)
+
+	type result struct {
+		body string
+		err  error
+	}
+	results := make(chan result, 1)
+	go func() {
+		resp, err := http.Get(url)
+		if err != nil {
+			results <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		results <- result{string(body), err}
+	}()
+
+	<-started
+	stop <- syscall.SIGTERM
+	//gives the server time to stop listening before the request finishes
+	time.Sleep(100 * time.Millisecond)
+	if _, err := net.DialTimeout("tcp", url[len("http://"):], time.Second); err == nil {
+		t.Errorf("the server should stop accepting connections once it is shutting down")
+	}
+	select {
+	case err := <-done:
+		t.Fatalf("the server stopped before its in-flight request finished: %v", err)
+	default:
+	}
+
+	close(release)
+	res := <-results
+	if res.err != nil || res.body != "finished" {
+		t.Errorf("the in-flight request should complete during shutdown, but got %q, %v", res.body, res.err)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("unexpected error shutting down: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("the server should stop once in-flight requests finish")
+	}
+}
+
+func TestServeShutdownTimeout(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	url, stop, done := startTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	}), 100*time.Millisecond)
+
+	go http.Get(url)
+	<-started
+	stop <- syscall.SIGINT
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Errorf("expected an error when in-flight requests don't finish before the timeout")
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("the server should stop once the shutdown timeout passes")
+	}
+}
+
+func TestServeListenerError(t *testing.T) {
+	url, stopOther, otherDone := startTestServer(t, http.NotFoundHandler(), 5*time.Second)
+	defer func() {
+		stopOther <- syscall.SIGTERM
+		<-otherDone
+	}()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("error listening: %v", err)
+	}
+	srv := newServer(ln.Addr().String(), http.NotFoundHandler())
+	failing := newServer(url[len("http://"):], http.NotFoundHandler())
+
+	done := make(chan error, 1)
+	go func() {
+		done <- serve(make(chan os.Signal), 5*time.Second,
+			&listener{srv, func() error { return srv.Serve(ln) }},
+			&listener{failing, failing.ListenAndServe})
+	}()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Errorf("expected the error from the listener that failed to start")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("the server should stop when any listener fails")
+	}
+	if _, err := net.DialTimeout("tcp", ln.Addr().String(), time.Second); err == nil {
+		t.Errorf("the other listeners should be shut down when one fails")
+	}
+}
@@ -77,6 +77,11 @@ func (rs *RedisStore) Delete(sid SessionID) error {
 	return nil
 }
 
+//Close closes the store's redis client
+func (rs *RedisStore) Close() error {
+	return rs.Client.Close()
+}
+
 //getRedisKey() returns the redis key to use for the SessionID
 func (sid SessionID) getRedisKey() string {
 	//convert the SessionID to a string and add the prefix "sid:" to keep
@@ -48,9 +48,7 @@ func NewSessionID(signingKey string) (SessionID, error) {
     if err != nil {
         return InvalidSessionID, fmt.Errorf("error generating salt: %v", err)
     }
-	h := hmac.New(sha256.New, []byte(signingKey))
-	h.Write(slice)
-	signature := h.Sum(nil)
+	signature := sign(signingKey, slice)
 	ss := append(slice, signature...)
 	encodedSlice := SessionID(base64.URLEncoding.EncodeToString(ss))
 	
@@ -70,9 +68,7 @@ func ValidateID(id string, signingKey string) (SessionID, error) {
 	idPortion := decode[:idLength]
 	previousSig := decode[idLength:]
 
-	h := hmac.New(sha256.New, []byte(signingKey))
-	h.Write([]byte(idPortion))
-	signature := h.Sum(nil)
+	signature := sign(signingKey, idPortion)
 	if hmac.Equal(previousSig, signature) {
 		return SessionID(id), nil
 	}
@@ -0,0 +1,36 @@
+package sessions
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+)
+
+//Sign returns the base64 URL encoded HMAC signature of `data` using
+//`signingKey` as the HMAC signing key, signed the same way as the ID
+//portion of a SessionID. It signs other values the server hands out
+//and needs to trust when they come back, such as image proxy URLs.
+func Sign(signingKey string, data string) string {
+	return base64.URLEncoding.EncodeToString(sign(signingKey, []byte(data)))
+}
+
+//ValidSignature reports whether `signature` is the signature Sign
+//returns for `data` and `signingKey`. Nothing is valid for an empty
+//`signingKey`, as anyone could produce those signatures.
+func ValidSignature(signingKey string, data string, signature string) bool {
+	if len(signingKey) == 0 {
+		return false
+	}
+	decoded, err := base64.URLEncoding.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal(decoded, sign(signingKey, []byte(data)))
+}
+
+//sign returns the HMAC-SHA256 hash of `data` using `signingKey`
+func sign(signingKey string, data []byte) []byte {
+	h := hmac.New(sha256.New, []byte(signingKey))
+	h.Write(data)
+	return h.Sum(nil)
+}
@@ -0,0 +1,63 @@
+package sessions
+
+import "testing"
+
+func TestSignature(t *testing.T) {
+	signature := Sign("test key", "https://test.com/image.png")
+
+	cases := []struct {
+		name       string
+		hint       string
+		signingKey string
+		data       string
+		signature  string
+		expected   bool
+	}{
+		{
+			"Valid Signature",
+			"Signatures returned by Sign should be valid for the same key and data",
+			"test key",
+			"https://test.com/image.png",
+			signature,
+			true,
+		},
+		{
+			"Different Data",
+			"Signatures must not be valid for any other data",
+			"test key",
+			"https://test.com/other.png",
+			signature,
+			false,
+		},
+		{
+			"Different Key",
+			"Signatures must not be valid for any other signing key",
+			"other key",
+			"https://test.com/image.png",
+			signature,
+			false,
+		},
+		{
+			"Not Base64",
+			"Signatures that can't be decoded are invalid",
+			"test key",
+			"https://test.com/image.png",
+			"not base64!",
+			false,
+		},
+		{
+			"Empty Signing Key",
+			"Nothing is valid for an empty signing key, as anyone could sign it",
+			"",
+			"https://test.com/image.png",
+			Sign("", "https://test.com/image.png"),
+			false,
+		},
+	}
+
+	for _, c := range cases {
+		if valid := ValidSignature(c.signingKey, c.data, c.signature); valid != c.expected {
+			t.Errorf("case %s: expected ValidSignature to be %t but it was %t\nHINT: %s", c.name, c.expected, valid, c.hint)
+		}
+	}
+}
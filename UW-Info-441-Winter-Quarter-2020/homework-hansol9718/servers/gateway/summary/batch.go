@@ -0,0 +1,141 @@
+package summary
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+//Default limits of a Batcher
+const (
+	//DefaultBatchWorkers is the most pages summarized at once
+	DefaultBatchWorkers = 8
+	//DefaultBatchHostLimit is the most pages on the
+	//same host summarized at once
+	DefaultBatchHostLimit = 2
+)
+
+//BatchResult is the result of summarizing one page of a batch:
+//either its Summary, or the error that prevented it
+type BatchResult struct {
+	Summary     *PageSummary
+	CacheStatus CacheStatus
+	Err         error
+}
+
+//Batcher summarizes batches of pages concurrently, without
+//sending too many requests to any one host at once
+type Batcher struct {
+	//Summarizer summarizes each page
+	Summarizer *Summarizer
+	//Workers is the most pages summarized at once
+	Workers int
+	//HostLimit is the most pages on the same host
+	//summarized at once
+	HostLimit int
+	//Options are the settings every page is summarized with
+	Options Options
+}
+
+//NewBatcher constructs a new Batcher that summarizes
+//pages with `summarizer`, with the default limits
+func NewBatcher(summarizer *Summarizer) *Batcher {
+	return &Batcher{
+		Summarizer: summarizer,
+		Workers:    DefaultBatchWorkers,
+		HostLimit:  DefaultBatchHostLimit,
+	}
+}
+
+//SummarizeURLs summarizes the pages at `pageURLs` using the
+//Summarizer's SummarizeURL, and returns the result for each URL.
+//Identical URLs are only summarized once.
+//
+//Pages are summarized concurrently, up to the Batcher's limits.
+//Once `ctx` is done, the pages still being summarized are
+//canceled, and those not yet started fail with ErrTimeout,
+//so one slow host can't hold up the batch past its deadline.
+func (b *Batcher) SummarizeURLs(ctx context.Context, pageURLs []string) map[string]*BatchResult {
+	results := make(map[string]*BatchResult, len(pageURLs))
+	for _, pageURL := range pageURLs {
+		results[pageURL] = &BatchResult{}
+	}
+
+	workers := make(chan struct{}, limit(b.Workers))
+	hosts := map[string]chan struct{}{}
+	wg := sync.WaitGroup{}
+	for pageURL, result := range results {
+		host := batchHost(pageURL)
+		if _, ok := hosts[host]; !ok {
+			hosts[host] = make(chan struct{}, limit(b.HostLimit))
+		}
+		wg.Add(1)
+		go func(pageURL string, result *BatchResult, hostSlots chan struct{}) {
+			defer wg.Done()
+			//a host slot is taken before a worker, so pages waiting
+			//for a busy host don't hold up pages on other hosts
+			if !acquire(ctx, hostSlots) {
+				result.Err = batchTimeout(ctx)
+				return
+			}
+			defer release(hostSlots)
+			if !acquire(ctx, workers) {
+				result.Err = batchTimeout(ctx)
+				return
+			}
+			defer release(workers)
+			result.Summary, result.CacheStatus, result.Err = b.Summarizer.SummarizeURL(ctx, pageURL, b.Options)
+		}(pageURL, result, hosts[host])
+	}
+	wg.Wait()
+	return results
+}
+
+//batchHost returns the host of `pageURL`, which is
+//what the Batcher's HostLimit applies to
+func batchHost(pageURL string) string {
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Host)
+}
+
+//limit returns `n`, or 1 if `n` is not positive
+func limit(n int) int {
+	if n < 1 {
+		return 1
+	}
+	return n
+}
+
+//acquire takes a slot from the semaphore `slots`, reporting
+//whether it did so before `ctx` was done
+func acquire(ctx context.Context, slots chan struct{}) bool {
+	//a done context wins over a free slot
+	if ctx.Err() != nil {
+		return false
+	}
+	select {
+	case slots <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+//release gives back a slot taken from the semaphore `slots`
+func release(slots chan struct{}) {
+	<-slots
+}
+
+//batchTimeout returns the error for pages that weren't
+//summarized because the batch's context was done first
+func batchTimeout(ctx context.Context) error {
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("%w: the batch's deadline passed before the page was fetched", ErrTimeout)
+	}
+	return ctx.Err()
+}
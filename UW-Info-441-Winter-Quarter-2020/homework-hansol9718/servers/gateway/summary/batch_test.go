@@ -0,0 +1,121 @@
+package summary
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+//concurrencyCounter tracks the most requests in flight at once
+type concurrencyCounter struct {
+	mx       sync.Mutex
+	inFlight int
+	max      int
+}
+
+func (c *concurrencyCounter) begin() {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	c.inFlight++
+	if c.inFlight > c.max {
+		c.max = c.inFlight
+	}
+}
+
+func (c *concurrencyCounter) end() {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	c.inFlight--
+}
+
+func (c *concurrencyCounter) maxInFlight() int {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	return c.max
+}
+
+func TestBatcherLimits(t *testing.T) {
+	total := &concurrencyCounter{}
+	newServer := func(perHost *concurrencyCounter) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			total.begin()
+			perHost.begin()
+			time.Sleep(20 * time.Millisecond)
+			perHost.end()
+			total.end()
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprintf(w, "<html><head><title>%s</title></head></html>", r.URL.Path)
+		}))
+	}
+	hostA, hostB := &concurrencyCounter{}, &concurrencyCounter{}
+	serverA, serverB := newServer(hostA), newServer(hostB)
+	defer serverA.Close()
+	defer serverB.Close()
+
+	var pageURLs []string
+	for i := 0; i < 6; i++ {
+		pageURLs = append(pageURLs, fmt.Sprintf("%s/a%d", serverA.URL, i), fmt.Sprintf("%s/b%d", serverB.URL, i))
+	}
+	//duplicates are only summarized once
+	pageURLs = append(pageURLs, serverA.URL+"/a0", serverB.URL+"/b0")
+
+	summarizer := NewSummarizer(&HTMLMetaExtractor{})
+	summarizer.Fetcher = loopbackFetcher()
+	batcher := &Batcher{Summarizer: summarizer, Workers: 3, HostLimit: 2}
+	results := batcher.SummarizeURLs(context.Background(), pageURLs)
+
+	if len(results) != 12 {
+		t.Errorf("expected a result for each of the 12 distinct URLs, but got %d", len(results))
+	}
+	for pageURL, result := range results {
+		if result.Err != nil {
+			t.Errorf("unexpected error summarizing %s: %v", pageURL, result.Err)
+		} else if expected := pageURL[len(serverA.URL):]; result.Summary.Title != expected {
+			t.Errorf("incorrect summary for %s: expected title %q but got %q", pageURL, expected, result.Summary.Title)
+		}
+	}
+	if max := total.maxInFlight(); max > 3 {
+		t.Errorf("at most 3 pages should be fetched at once, but %d were", max)
+	}
+	if maxA, maxB := hostA.maxInFlight(), hostB.maxInFlight(); maxA > 2 || maxB > 2 {
+		t.Errorf("at most 2 pages should be fetched from each host at once, but %d and %d were", maxA, maxB)
+	}
+}
+
+func TestBatcherDeadline(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer slow.Close()
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html><head><title>fast</title></head></html>"))
+	}))
+	defer fast.Close()
+
+	summarizer := NewSummarizer(&HTMLMetaExtractor{})
+	summarizer.Fetcher = loopbackFetcher()
+	batcher := &Batcher{Summarizer: summarizer, Workers: 2, HostLimit: 1}
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	results := batcher.SummarizeURLs(ctx, []string{slow.URL + "/1", slow.URL + "/2", fast.URL})
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("the batch should end at its deadline, but took %v", elapsed)
+	}
+	if result := results[fast.URL]; result.Err != nil || result.Summary.Title != "fast" {
+		t.Errorf("a slow host shouldn't hold up pages on other hosts, but got %+v", result)
+	}
+	//the second slow page is still waiting for the host when the deadline passes
+	for _, pageURL := range []string{slow.URL + "/1", slow.URL + "/2"} {
+		if err := results[pageURL].Err; !errors.Is(err, ErrTimeout) {
+			t.Errorf("pages not summarized by the deadline should fail with ErrTimeout, but %s got %v", pageURL, err)
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package summary
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+//blockTags are the block-level elements the text of a page's
+//body is divided into Blocks by
+var blockTags = map[string]bool{
+	"address": true, "article": true, "blockquote": true, "dd": true,
+	"div": true, "dl": true, "dt": true, "figcaption": true,
+	"figure": true, "h1": true, "h2": true, "h3": true, "h4": true,
+	"h5": true, "h6": true, "header": true, "li": true, "main": true,
+	"ol": true, "p": true, "pre": true, "section": true, "table": true,
+	"td": true, "th": true, "tr": true, "ul": true,
+}
+
+//skippedTags are the elements whose content is never
+//part of a page's readable text
+var skippedTags = map[string]bool{
+	"aside": true, "audio": true, "button": true, "canvas": true,
+	"footer": true, "iframe": true, "math": true, "nav": true,
+	"noscript": true, "object": true, "script": true, "select": true,
+	"style": true, "svg": true, "template": true, "textarea": true,
+	"video": true,
+}
+
+//Body holds the readable content of a page's body:
+//the text of its block-level elements, and its images
+type Body struct {
+	//Blocks holds the text of the body's block-level
+	//elements, in document order
+	Blocks []Block
+	//Images holds the body's <img> elements, in document order
+	Images []BodyImage
+	//elements holds the body's block-level elements, which
+	//Blocks and Images refer to by index. The first is the
+	//<body> itself.
+	elements []element
+}
+
+//Block is the text directly inside a block-level element: its own
+//text and that of the inline elements in it, but not that of the
+//block-level elements nested inside it
+type Block struct {
+	//Tag is the name of the element
+	Tag string
+	//Text is the element's text, with whitespace collapsed
+	Text string
+	//LinkLength is how many bytes of Text are the text of links
+	LinkLength int
+	//element is the index of the element in Body.elements
+	element int
+}
+
+//BodyImage represents an <img> element in a page's body
+type BodyImage struct {
+	//Token is the <img> element's token
+	Token html.Token
+	//element is the index of the innermost block-level
+	//element containing the image in Body.elements
+	element int
+}
+
+//element represents a block-level element in a page's body
+type element struct {
+	tag string
+	//hints are the element's class and id, which
+	//often say what kind of content it holds
+	hints string
+	//parent is the index of the block-level element
+	//containing this one, or -1 for the <body>
+	parent int
+}
+
+//within reports whether the element at index `i` is the element
+//at index `ancestor`, or is nested inside it
+func (b *Body) within(i int, ancestor int) bool {
+	for ; i >= 0; i = b.elements[i].parent {
+		if i == ancestor {
+			return true
+		}
+	}
+	return false
+}
+
+//bodyParser collects the Body of a page from its tokens
+type bodyParser struct {
+	body *Body
+	//open holds the indices of the open block-level
+	//elements, innermost last
+	open []int
+	//skipTag is the name of the skipped element being read,
+	//and skipDepth the number of them that are open
+	skipTag   string
+	skipDepth int
+	//links is the number of open <a> elements
+	links int
+	//text is the text read since the last Block,
+	//and linkLength how many bytes of it are links
+	text       strings.Builder
+	linkLength int
+}
+
+//newBodyParser constructs a new bodyParser, ready for
+//the tokens that follow the <body> start tag
+func newBodyParser() *bodyParser {
+	return &bodyParser{
+		body: &Body{elements: []element{{tag: "body", parent: -1}}},
+		open: []int{0},
+	}
+}
+
+//token reads the next token of the body
+func (p *bodyParser) token(tokenType html.TokenType, token html.Token) {
+	if p.skipDepth > 0 {
+		if token.Data == p.skipTag {
+			switch tokenType {
+			case html.StartTagToken:
+				p.skipDepth++
+			case html.EndTagToken:
+				p.skipDepth--
+			}
+		}
+		return
+	}
+	switch tokenType {
+	case html.TextToken:
+		p.text.WriteString(token.Data)
+		if p.links > 0 {
+			p.linkLength += len(collapseSpace(token.Data))
+		}
+	case html.StartTagToken, html.SelfClosingTagToken:
+		p.start(token, tokenType == html.SelfClosingTagToken)
+	case html.EndTagToken:
+		p.end(token.Data)
+	}
+}
+
+//start reads the start tag `token`
+func (p *bodyParser) start(token html.Token, selfClosing bool) {
+	tag := token.Data
+	switch {
+	case skippedTags[tag]:
+		if !selfClosing {
+			p.skipTag, p.skipDepth = tag, 1
+		}
+	case tag == "img":
+		p.body.Images = append(p.body.Images, BodyImage{Token: token, element: p.current()})
+	case tag == "br":
+		p.text.WriteByte(' ')
+	case tag == "a":
+		if !selfClosing {
+			p.links++
+		}
+	case blockTags[tag]:
+		p.flush()
+		//a <p> is closed by the next block-level element,
+		//and an <li> by the next <li>
+		if current := p.body.elements[p.current()].tag; current == "p" || current == "li" && tag == "li" {
+			p.open = p.open[:len(p.open)-1]
+		}
+		if selfClosing {
+			return
+		}
+		class, _ := attr(token, "class")
+		id, _ := attr(token, "id")
+		p.body.elements = append(p.body.elements, element{
+			tag:    tag,
+			hints:  strings.TrimSpace(class + " " + id),
+			parent: p.current(),
+		})
+		p.open = append(p.open, len(p.body.elements)-1)
+	}
+}
+
+//end reads the end tag of the element `tag`
+func (p *bodyParser) end(tag string) {
+	switch {
+	case tag == "a":
+		if p.links > 0 {
+			p.links--
+		}
+	case blockTags[tag]:
+		//close the innermost open element with this
+		//name, along with any left open inside it
+		for i := len(p.open) - 1; i > 0; i-- {
+			if p.body.elements[p.open[i]].tag == tag {
+				p.flush()
+				p.open = p.open[:i]
+				return
+			}
+		}
+	}
+}
+
+//current returns the index of the innermost open block-level element
+func (p *bodyParser) current() int {
+	return p.open[len(p.open)-1]
+}
+
+//flush adds the text read since the last Block, if
+//there is any, as a Block of the current element
+func (p *bodyParser) flush() {
+	text := collapseSpace(p.text.String())
+	if len(text) > 0 {
+		current := p.current()
+		linkLength := p.linkLength
+		if linkLength > len(text) {
+			linkLength = len(text)
+		}
+		p.body.Blocks = append(p.body.Blocks, Block{
+			Tag:        p.body.elements[current].tag,
+			Text:       text,
+			LinkLength: linkLength,
+			element:    current,
+		})
+	}
+	p.text.Reset()
+	p.linkLength = 0
+}
+
+//finish returns the Body once all its tokens have been read
+func (p *bodyParser) finish() *Body {
+	p.flush()
+	return p.body
+}
+
+//collapseSpace returns `s` with leading and trailing whitespace
+//removed, and every other run of whitespace replaced by one space
+func collapseSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,156 @@
+package summary
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//ErrCacheMiss is returned from SummaryCache.Get() when
+//no summary was cached for the requested page URL
+var ErrCacheMiss = errors.New("no summary was found in the summary cache")
+
+//CacheStatus tells whether a summary was served from a SummaryCache
+type CacheStatus string
+
+//CacheStatus values, which are also the values of the X-Cache header
+//the summary handler responds with
+const (
+	//CacheHit means the summary was served from the cache, either
+	//because it was still fresh, or because the origin server
+	//confirmed it was unchanged
+	CacheHit CacheStatus = "HIT"
+	//CacheMiss means the page was fetched and summarized
+	CacheMiss CacheStatus = "MISS"
+)
+
+//CacheEntry is a PageSummary saved in a SummaryCache, along with
+//when it stops being fresh and the validators needed to ask the
+//origin server whether it has changed since
+type CacheEntry struct {
+	Summary      *PageSummary `json:"summary"`
+	Expires      time.Time    `json:"expires"`
+	ETag         string       `json:"etag,omitempty"`
+	LastModified string       `json:"lastModified,omitempty"`
+}
+
+//Fresh reports whether the entry can be served
+//without revalidating it at time `now`
+func (e *CacheEntry) Fresh(now time.Time) bool {
+	return now.Before(e.Expires)
+}
+
+//SummaryCache represents a cache of page summaries, keyed by page URL.
+//Summarizer normalizes the URLs, and marks those of deep summaries.
+//Like sessions.Store, this is an abstract interface that can be
+//implemented in process memory, or in a shared store like redis.
+type SummaryCache interface {
+	//Get returns the entry previously saved for `pageURL`,
+	//or ErrCacheMiss if there is none. The entry may be stale.
+	Get(pageURL string) (*CacheEntry, error)
+
+	//Set saves `entry` for `pageURL`, replacing any previous entry.
+	//Stale entries are kept for a while so that they can be revalidated.
+	Set(pageURL string, entry *CacheEntry) error
+}
+
+//cacheKey returns the key the summary of `pageURL` is cached under.
+//The URL's scheme and host are lowercased, an empty path is made "/"
+//and its fragment, which is never sent to the page's server, removed,
+//so that the ways of writing the same URL share a cache entry. Deep
+//summaries are marked with a "#deep" fragment, which can't be part
+//of any normalized URL.
+func cacheKey(pageURL string, deep bool) string {
+	key := pageURL
+	if u, err := url.Parse(strings.TrimSpace(pageURL)); err == nil {
+		u.Scheme = strings.ToLower(u.Scheme)
+		u.Host = strings.ToLower(u.Host)
+		if len(u.Path) == 0 && len(u.Host) > 0 {
+			u.Path = "/"
+		}
+		u.Fragment = ""
+		key = u.String()
+	}
+	if deep {
+		key += "#deep"
+	}
+	return key
+}
+
+//cacheEntry returns the entry that caches `summary`, using the
+//freshness information and validators in the response headers
+//`header` received at time `now`. Responses without freshness
+//information stay fresh for `defaultTTL`. It returns nil if the
+//response may not be cached, or would be of no use in a cache.
+func cacheEntry(summary *PageSummary, header http.Header, now time.Time, defaultTTL time.Duration) *CacheEntry {
+	entry := &CacheEntry{
+		Summary:      summary,
+		ETag:         header.Get("ETag"),
+		LastModified: header.Get("Last-Modified"),
+	}
+
+	maxAge, sharedMaxAge, noCache := -1, -1, false
+	for _, directive := range strings.Split(header.Get("Cache-Control"), ",") {
+		name := strings.ToLower(strings.TrimSpace(directive))
+		value := ""
+		if i := strings.Index(name, "="); i >= 0 {
+			name, value = name[:i], strings.Trim(name[i+1:], `"`)
+		}
+		switch name {
+		case "no-store", "private":
+			return nil
+		case "no-cache":
+			noCache = true
+		case "max-age":
+			maxAge = parseSeconds(value)
+		case "s-maxage":
+			sharedMaxAge = parseSeconds(value)
+		}
+	}
+	//we are a shared cache, so s-maxage overrides max-age,
+	//and no-cache means we must always revalidate
+	if sharedMaxAge >= 0 {
+		maxAge = sharedMaxAge
+	}
+	if noCache {
+		maxAge = 0
+	}
+
+	switch {
+	case maxAge >= 0:
+		//the response may have already spent some
+		//of its lifetime in other caches
+		age := parseSeconds(header.Get("Age"))
+		if age < 0 {
+			age = 0
+		}
+		entry.Expires = now.Add(time.Duration(maxAge-age) * time.Second)
+	case len(header.Get("Expires")) > 0:
+		//invalid dates, such as "0", mean already expired
+		expires, err := http.ParseTime(header.Get("Expires"))
+		if err != nil {
+			expires = now
+		}
+		entry.Expires = expires
+	default:
+		entry.Expires = now.Add(defaultTTL)
+	}
+
+	if !entry.Fresh(now) && len(entry.ETag) == 0 && len(entry.LastModified) == 0 {
+		return nil
+	}
+	return entry
+}
+
+//parseSeconds parses a delta-seconds header value,
+//returning -1 if it is missing or invalid
+func parseSeconds(value string) int {
+	n, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || n < 0 {
+		return -1
+	}
+	return n
+}
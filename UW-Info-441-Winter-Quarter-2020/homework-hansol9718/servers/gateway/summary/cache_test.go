@@ -0,0 +1,317 @@
+package summary
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCacheEntry(t *testing.T) {
+	now := time.Date(2020, time.February, 1, 12, 0, 0, 0, time.UTC)
+	cases := []struct {
+		name            string
+		hint            string
+		header          map[string]string
+		expectCached    bool
+		expectedExpires time.Time
+	}{
+		{
+			"No Freshness Information",
+			"Responses without Cache-Control or Expires should use the default TTL",
+			map[string]string{},
+			true,
+			now.Add(time.Minute),
+		},
+		{
+			"Max Age",
+			"Make sure you read the Cache-Control max-age directive",
+			map[string]string{"Cache-Control": "public, max-age=300"},
+			true,
+			now.Add(300 * time.Second),
+		},
+		{
+			"Shared Max Age",
+			"s-maxage overrides max-age in a shared cache",
+			map[string]string{"Cache-Control": "s-maxage=600, max-age=300"},
+			true,
+			now.Add(600 * time.Second),
+		},
+		{
+			"Age",
+			"Time already spent in other caches counts against max-age",
+			map[string]string{"Cache-Control": "max-age=300", "Age": "100"},
+			true,
+			now.Add(200 * time.Second),
+		},
+		{
+			"Max Age Overrides Expires",
+			"max-age takes precedence over the Expires header",
+			map[string]string{"Cache-Control": "max-age=300", "Expires": "Sat, 01 Feb 2020 13:00:00 GMT"},
+			true,
+			now.Add(300 * time.Second),
+		},
+		{
+			"Expires",
+			"Make sure you read the Expires header when there is no max-age",
+			map[string]string{"Expires": "Sat, 01 Feb 2020 13:00:00 GMT"},
+			true,
+			now.Add(time.Hour),
+		},
+		{
+			"Invalid Expires With Validator",
+			"An invalid Expires date means the response has already expired",
+			map[string]string{"Expires": "0", "ETag": `"v1"`},
+			true,
+			now,
+		},
+		{
+			"No Cache With Validator",
+			"no-cache responses may be stored, but must always be revalidated",
+			map[string]string{"Cache-Control": "no-cache, max-age=300", "Last-Modified": "Sat, 01 Feb 2020 11:00:00 GMT"},
+			true,
+			now,
+		},
+		{
+			"No Cache Without Validator",
+			"Responses that are never fresh and can't be revalidated are useless in a cache",
+			map[string]string{"Cache-Control": "no-cache"},
+			false,
+			time.Time{},
+		},
+		{
+			"No Store",
+			"no-store responses must never be cached",
+			map[string]string{"Cache-Control": "no-store", "ETag": `"v1"`},
+			false,
+			time.Time{},
+		},
+		{
+			"Private",
+			"private responses must not be stored in a shared cache",
+			map[string]string{"Cache-Control": "private, max-age=300"},
+			false,
+			time.Time{},
+		},
+	}
+
+	summary := &PageSummary{Title: "test title"}
+	for _, c := range cases {
+		header := http.Header{}
+		for name, value := range c.header {
+			header.Set(name, value)
+		}
+		entry := cacheEntry(summary, header, now, time.Minute)
+		if !c.expectCached {
+			if entry != nil {
+				t.Errorf("case %s: expected no cache entry but got one\nHINT: %s", c.name, c.hint)
+			}
+			continue
+		}
+		if entry == nil {
+			t.Errorf("case %s: expected a cache entry but got none\nHINT: %s", c.name, c.hint)
+			continue
+		}
+		if !entry.Expires.Equal(c.expectedExpires) {
+			t.Errorf("case %s: incorrect expiry: expected %v but got %v\nHINT: %s", c.name, c.expectedExpires, entry.Expires, c.hint)
+		}
+		if entry.ETag != header.Get("ETag") || entry.LastModified != header.Get("Last-Modified") {
+			t.Errorf("case %s: validators were not saved in the cache entry", c.name)
+		}
+	}
+}
+
+func TestCacheKey(t *testing.T) {
+	key := cacheKey("http://test.com/page.html", false)
+	for _, same := range []string{"HTTP://Test.com/page.html", "http://test.com/page.html#section", " http://test.com/page.html"} {
+		if cacheKey(same, false) != key {
+			t.Errorf("%q should have the same cache key as http://test.com/page.html, but got %q", same, cacheKey(same, false))
+		}
+	}
+	if cacheKey("http://test.com/Page.html", false) == key {
+		t.Errorf("URLs whose paths differ should have different cache keys")
+	}
+	if cacheKey("http://test.com", false) != cacheKey("http://test.com/", false) {
+		t.Errorf("URLs with an empty path should have the same cache key as those with path /")
+	}
+	if deep := cacheKey("http://test.com/page.html", true); deep == key || deep == cacheKey("http://test.com/page.html#deep", false) {
+		t.Errorf("deep summaries should be cached separately from shallow ones, but got %q", deep)
+	}
+}
+
+func TestMemCache(t *testing.T) {
+	entry := &CacheEntry{
+		Summary: &PageSummary{Title: "test title"},
+		Expires: time.Now().Add(time.Hour).UTC().Round(0),
+		ETag:    `"v1"`,
+	}
+	cache := NewMemCache(2)
+
+	if _, err := cache.Get("http://test.com/1"); err != ErrCacheMiss {
+		t.Errorf("incorrect error when getting an entry that was never cached: expected %v but got %v", ErrCacheMiss, err)
+	}
+
+	if err := cache.Set("http://test.com/1", entry); err != nil {
+		t.Fatalf("error caching entry: %v", err)
+	}
+	entryRet, err := cache.Get("http://test.com/1")
+	if err != nil {
+		t.Fatalf("error getting cached entry: %v", err)
+	}
+	if !reflect.DeepEqual(entry, entryRet) {
+		jexp, _ := json.MarshalIndent(entry, "", "  ")
+		jact, _ := json.MarshalIndent(entryRet, "", "  ")
+		t.Errorf("incorrect entry retrieved:\nEXPECTED\n%s\nACTUAL\n%s", string(jexp), string(jact))
+	}
+
+	//changing a summary after it's cached must not change the cached copy
+	entryRet.Summary.Title = "changed title"
+	if entryRet, _ = cache.Get("http://test.com/1"); entryRet.Summary.Title != "test title" {
+		t.Errorf("cached summary was changed by modifying a summary returned from the cache")
+	}
+
+	//page 1 is now the most recently used, so page 2 is evicted
+	cache.Set("http://test.com/2", entry)
+	cache.Get("http://test.com/1")
+	cache.Set("http://test.com/3", entry)
+	if cache.Len() != 2 {
+		t.Errorf("incorrect number of cached entries: expected 2 but got %d", cache.Len())
+	}
+	if _, err := cache.Get("http://test.com/2"); err != ErrCacheMiss {
+		t.Errorf("expected the least recently used entry to be evicted")
+	}
+	for _, pageURL := range []string{"http://test.com/1", "http://test.com/3"} {
+		if _, err := cache.Get(pageURL); err != nil {
+			t.Errorf("error getting cached entry for %s: %v", pageURL, err)
+		}
+	}
+}
+
+func TestSummarizerCache(t *testing.T) {
+	requests := 0
+	conditional := 0
+	cacheControl := "max-age=60"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		//pages without icons are probed for /favicon.ico,
+		//which isn't a request for the page
+		if r.URL.Path == "/favicon.ico" {
+			http.NotFound(w, r)
+			return
+		}
+		requests++
+		w.Header().Set("Cache-Control", cacheControl)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			conditional++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte(`<html><head><title>test title</title></head></html>`))
+	}))
+	defer server.Close()
+
+	summarizer := NewSummarizer(&HTMLMetaExtractor{})
+	summarizer.Fetcher = loopbackFetcher()
+	summarizer.Cache = NewMemCache(10)
+
+	//each case is a request made after the previous one,
+	//whose response is sent with the case's Cache-Control
+	cases := []struct {
+		name                string
+		hint                string
+		path                string
+		cacheControl        string
+		expectedStatus      CacheStatus
+		expectedRequests    int
+		expectedConditional int
+	}{
+		{
+			"First Request",
+			"Pages that aren't cached yet must be fetched",
+			"/",
+			"max-age=0",
+			CacheMiss,
+			1,
+			0,
+		},
+		{
+			"Stale",
+			"Stale summaries should be revalidated with If-None-Match, and reused if the page hasn't changed",
+			"/",
+			"max-age=60",
+			CacheHit,
+			2,
+			1,
+		},
+		{
+			"Fresh",
+			"Fresh summaries should be served from the cache without fetching the page",
+			"/",
+			"max-age=0",
+			CacheHit,
+			2,
+			1,
+		},
+		{
+			"No Store",
+			"Pages served with Cache-Control: no-store must be fetched",
+			"/nostore",
+			"no-store",
+			CacheMiss,
+			3,
+			1,
+		},
+		{
+			"No Store Again",
+			"Pages served with Cache-Control: no-store must never be cached",
+			"/nostore",
+			"no-store",
+			CacheMiss,
+			4,
+			1,
+		},
+	}
+	for _, c := range cases {
+		cacheControl = c.cacheControl
+		summary, status, err := summarizer.SummarizeURL(context.Background(), server.URL+c.path, Options{})
+		if err != nil {
+			t.Fatalf("case %s: unexpected error: %v", c.name, err)
+		}
+		if summary.Title != "test title" {
+			t.Errorf("case %s: incorrect title: expected %q but got %q", c.name, "test title", summary.Title)
+		}
+		if status != c.expectedStatus {
+			t.Errorf("case %s: incorrect cache status: expected %s but got %s\nHINT: %s", c.name, c.expectedStatus, status, c.hint)
+		}
+		if requests != c.expectedRequests || conditional != c.expectedConditional {
+			t.Errorf("case %s: expected %d requests (%d conditional) but there were %d (%d conditional)\nHINT: %s",
+				c.name, c.expectedRequests, c.expectedConditional, requests, conditional, c.hint)
+		}
+	}
+}
+
+func TestSummarizerNotModifiedWithoutCache(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotModified)
+	}))
+	defer server.Close()
+
+	cache := NewMemCache(10)
+	summarizer := NewSummarizer(&HTMLMetaExtractor{})
+	summarizer.Fetcher = loopbackFetcher()
+	summarizer.Cache = cache
+
+	_, _, err := summarizer.SummarizeURL(context.Background(), server.URL, Options{})
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusNotModified {
+		t.Errorf("a 304 response to a request without validators should be an upstream error, but got %v", err)
+	}
+	if cache.Len() != 0 {
+		t.Errorf("nothing should be cached for a 304 response without a cached summary")
+	}
+}
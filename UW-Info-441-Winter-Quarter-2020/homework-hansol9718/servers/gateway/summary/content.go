@@ -0,0 +1,274 @@
+package summary
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/html"
+)
+
+//MaxExcerptLength is the most characters a summary's Excerpt
+//has, not counting the ellipsis added when it is cut short
+const MaxExcerptLength = 300
+
+//WordsPerMinute is the reading speed a summary's ReadingTime
+//is estimated with
+const WordsPerMinute = 200
+
+//minBlockLength is the length of the shortest Block that is scored
+//as a paragraph of content. Shorter ones are mostly headings,
+//captions, bylines and the like.
+const minBlockLength = 25
+
+//maxScoredBlocks and maxScoredElements are the most Blocks and
+//elements of a body that are scored when finding its main content.
+//Those after them are left out, so huge pages can't make it slow.
+const (
+	maxScoredBlocks   = 5000
+	maxScoredElements = 5000
+)
+
+//minLeadImageWidth and minLeadImageHeight are the smallest
+//declared dimensions of an image used as a preview image
+const (
+	minLeadImageWidth  = 200
+	minLeadImageHeight = 100
+)
+
+//positiveHints and negativeHints match the class and id
+//names of elements that usually hold, or don't hold,
+//a page's main content
+var (
+	positiveHints = regexp.MustCompile(`(?i)article|body|content|entry|main|page|post|story|text|blog`)
+	negativeHints = regexp.MustCompile(`(?i)comment|meta|footer|sidebar|menu|masthead|banner|sponsor|promo|related|share|social|widget|popup|cookie|newsletter|subscribe|advert|\bads?\b`)
+)
+
+//tagScores are the scores block-level elements start with,
+//according to how likely they are to hold the main content
+var tagScores = map[string]float64{
+	"article": 5, "div": 5, "main": 5,
+	"blockquote": 3, "pre": 3, "td": 3,
+	"address": -3, "dd": -3, "dl": -3, "dt": -3, "li": -3, "ol": -3, "ul": -3,
+	"h1": -5, "h2": -5, "h3": -5, "h4": -5, "h5": -5, "h6": -5, "th": -5,
+}
+
+//headingTags are the heading elements, whose text is
+//counted but left out of excerpts
+var headingTags = map[string]bool{
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+}
+
+//paragraphTags are the elements whose Blocks score for the element
+//containing them. Other elements' Blocks score for the element itself.
+var paragraphTags = map[string]bool{
+	"blockquote": true, "dd": true, "li": true, "p": true, "pre": true, "td": true,
+}
+
+//ContentExtractor extracts a page's main content from its body, which
+//is only available when the page was parsed with ParseDocumentBody.
+//
+//The main content is found in the manner of Readability: each paragraph
+//of text adds to the score of the element containing it, and half as
+//much to that element's parent, according to its length and number of
+//commas. Elements with class or id names like "comment" or "sidebar"
+//are penalized, as are elements that are mostly links. The text of the
+//highest scoring element becomes the summary's Excerpt, WordCount and
+//ReadingTime, in minutes.
+//
+//The first image in the body declared to be at least 200x100 pixels,
+//or the first image in the main content if its size isn't declared,
+//becomes the summary's preview image.
+type ContentExtractor struct{}
+
+//Extract implements the Extractor interface
+func (e *ContentExtractor) Extract(doc *Document) *PageSummary {
+	if doc.Body == nil {
+		return nil
+	}
+	page := &PageSummary{}
+	main := doc.Body.mainContent()
+	if main >= 0 {
+		var paragraphs []string
+		inside := doc.Body.descendants(main)
+		for _, block := range doc.Body.Blocks {
+			if !inside[block.element] || 2*block.LinkLength > len(block.Text) {
+				continue
+			}
+			page.WordCount += len(strings.Fields(block.Text))
+			if len(block.Text) >= minBlockLength && !headingTags[block.Tag] {
+				paragraphs = append(paragraphs, block.Text)
+			}
+		}
+		page.Excerpt = excerpt(strings.Join(paragraphs, " "), MaxExcerptLength)
+		page.ReadingTime = (page.WordCount + WordsPerMinute - 1) / WordsPerMinute
+	}
+	if img := doc.leadImage(main); img != nil {
+		page.Images = []*PreviewImage{img}
+	}
+	return page
+}
+
+//mainContent returns the index of the element holding the
+//body's main content, or -1 if it has no paragraphs of text
+func (b *Body) mainContent() int {
+	blocks, elements := b.Blocks, b.elements
+	if len(blocks) > maxScoredBlocks {
+		blocks = blocks[:maxScoredBlocks]
+	}
+	if len(elements) > maxScoredElements {
+		elements = elements[:maxScoredElements]
+	}
+
+	//each block's text is counted for its own element, then
+	//every element's totals are added to its parent's, which
+	//comes before it, so each element ends up with the totals
+	//of all the blocks nested inside it
+	text := make([]int, len(elements))
+	links := make([]int, len(elements))
+	scores := make([]float64, len(elements))
+	scored := make([]bool, len(elements))
+	add := func(i int, score float64) {
+		if !scored[i] {
+			scores[i], scored[i] = b.initialScore(i), true
+		}
+		scores[i] += score
+	}
+	for _, block := range blocks {
+		if block.element >= len(elements) {
+			continue
+		}
+		text[block.element] += len(block.Text)
+		links[block.element] += block.LinkLength
+		if len(block.Text) < minBlockLength {
+			continue
+		}
+		score := 1 + float64(strings.Count(block.Text, ","))
+		if length := float64(len(block.Text) / 100); length < 3 {
+			score += length
+		} else {
+			score += 3
+		}
+		container := block.element
+		if paragraphTags[block.Tag] && container > 0 {
+			container = elements[container].parent
+		}
+		add(container, score)
+		if parent := elements[container].parent; parent >= 0 {
+			add(parent, score/2)
+		}
+	}
+	for i := len(elements) - 1; i > 0; i-- {
+		parent := elements[i].parent
+		text[parent] += text[i]
+		links[parent] += links[i]
+	}
+
+	//elements are compared in document order, so the
+	//first of any that score the same is chosen
+	best, bestScore := -1, 0.0
+	for i := range elements {
+		if !scored[i] {
+			continue
+		}
+		score := scores[i]
+		if text[i] > 0 {
+			score *= 1 - float64(links[i])/float64(text[i])
+		}
+		if best < 0 || score > bestScore {
+			best, bestScore = i, score
+		}
+	}
+	return best
+}
+
+//initialScore returns the score the element at index `i`
+//starts with, according to its name and hints
+func (b *Body) initialScore(i int) float64 {
+	score := tagScores[b.elements[i].tag]
+	if hints := b.elements[i].hints; len(hints) > 0 {
+		if positiveHints.MatchString(hints) {
+			score += 25
+		}
+		if negativeHints.MatchString(hints) {
+			score -= 25
+		}
+	}
+	return score
+}
+
+//descendants reports, for each element, whether it is the element
+//at index `ancestor` or is nested inside it. An element's parent
+//always comes before it, so they are found in one pass.
+func (b *Body) descendants(ancestor int) []bool {
+	inside := make([]bool, len(b.elements))
+	for i, e := range b.elements {
+		inside[i] = i == ancestor || e.parent >= 0 && inside[e.parent]
+	}
+	return inside
+}
+
+//leadImage returns the image to use as the page's preview image, as
+//described for ContentExtractor, given the index `main` of the element
+//holding its main content. It returns nil if no image is suitable.
+func (doc *Document) leadImage(main int) *PreviewImage {
+	for _, img := range doc.Body.Images {
+		src, _ := attr(img.Token, "src")
+		src = strings.TrimSpace(src)
+		if len(src) == 0 || strings.HasPrefix(strings.ToLower(src), "data:") {
+			continue
+		}
+		width, widthOK := imageDimension(img.Token, "width")
+		height, heightOK := imageDimension(img.Token, "height")
+		if widthOK && width < minLeadImageWidth || heightOK && height < minLeadImageHeight {
+			continue
+		}
+		if (!widthOK || !heightOK) && (main < 0 || !doc.Body.within(img.element, main)) {
+			continue
+		}
+		alt, _ := attr(img.Token, "alt")
+		return &PreviewImage{
+			URL:    doc.Resolve(src),
+			Width:  width,
+			Height: height,
+			Alt:    alt,
+		}
+	}
+	return nil
+}
+
+//imageDimension returns the `width` or `height` attribute `key` of the
+//<img> token `t`, and whether it is declared as a number of pixels
+func imageDimension(t html.Token, key string) (int, bool) {
+	val, ok := attr(t, key)
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.Atoi(strings.TrimSuffix(strings.TrimSpace(val), "px"))
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
+
+//excerpt returns `text` cut short at a word boundary to at most
+//`max` characters, followed by an ellipsis if it was cut
+func excerpt(text string, max int) string {
+	runes := []rune(text)
+	if len(runes) <= max {
+		return text
+	}
+	cut := runes[:max]
+	if !unicode.IsSpace(runes[max]) {
+		for i := len(cut) - 1; i > 0; i-- {
+			if unicode.IsSpace(cut[i]) {
+				cut = cut[:i]
+				break
+			}
+		}
+	}
+	return strings.TrimRightFunc(string(cut), func(r rune) bool {
+		return unicode.IsSpace(r) || unicode.IsPunct(r)
+	}) + "…"
+}
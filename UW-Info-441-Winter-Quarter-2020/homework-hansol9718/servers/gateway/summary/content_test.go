@@ -0,0 +1,220 @@
+package summary
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestContentExtractor(t *testing.T) {
+	pageURL := "http://test.com/news/story.html"
+	paragraphs := []string{
+		"The city council voted on Tuesday to extend the light rail line north, adding four stations by 2030.",
+		"Supporters said the extension, which has been debated for a decade, would ease traffic on the bridge.",
+		"Opponents questioned the cost, estimated at two billion dollars, and asked for a public vote.",
+	}
+	article := `<article class="story">
+		<h1>Council extends light rail</h1>
+		<p>` + paragraphs[0] + `</p>
+		<img src="/img/map.png" alt="Map of the new stations">
+		<p>` + paragraphs[1] + `</p>
+		<p>` + paragraphs[2] + `</p>
+	</article>`
+	chrome := func(content string) string {
+		return `<body>
+		<header><img src="/logo.png" width="120" height="40"><a href="/">Home</a></header>
+		<nav><ul><li><a href="/news">News</a></li><li><a href="/sports">Sports</a></li></ul></nav>
+		<div id="main">` + content + `</div>
+		<div class="comments">
+			<p>I can't believe they are spending this much money on trains, when the roads need fixing.</p>
+			<p>Great news, finally! I have been waiting for this for years, and so have my neighbours.</p>
+		</div>
+		<ul class="related">
+			<li><a href="/a">Bus fares to rise in March, with discounts for students</a></li>
+			<li><a href="/b">Bridge repairs will close two lanes over the weekend</a></li>
+		</ul>
+		<footer><p>Copyright 2020, The Daily Test. All rights reserved.</p></footer>
+		</body>`
+	}
+	words := 4 //in the heading
+	for _, p := range paragraphs {
+		words += len(strings.Fields(p))
+	}
+	longParagraph := strings.Repeat("All work and no play makes Jack a dull boy. ", 100)
+
+	cases := []struct {
+		name            string
+		hint            string
+		deep            bool
+		html            string
+		expectedSummary *PageSummary
+	}{
+		{
+			"Article",
+			"The text of the highest scoring element should be summarized, and its first image used",
+			true,
+			`<html><head><title>Light rail</title></head>` + chrome(article) + `</html>`,
+			&PageSummary{
+				Title:       "Light rail",
+				Excerpt:     strings.Join(paragraphs, " "),
+				WordCount:   words,
+				ReadingTime: 1,
+				Images:      []*PreviewImage{{URL: "http://test.com/img/map.png", Alt: "Map of the new stations"}},
+			},
+		},
+		{
+			"Not Deep",
+			"The body should only be read in deep mode",
+			false,
+			`<html><head><title>Light rail</title></head>` + chrome(article) + `</html>`,
+			&PageSummary{
+				Title: "Light rail",
+			},
+		},
+		{
+			"Open Graph Image",
+			"An og:image should take precedence over images in the body",
+			true,
+			`<html><head><meta property="og:image" content="/og.png"></head>` + chrome(article) + `</html>`,
+			&PageSummary{
+				Excerpt:     strings.Join(paragraphs, " "),
+				WordCount:   words,
+				ReadingTime: 1,
+				Images:      []*PreviewImage{{URL: "http://test.com/og.png"}},
+			},
+		},
+		{
+			"Declared Image Size",
+			"Images declared too small should be skipped, and images with no declared size used only in the main content",
+			true,
+			`<html><head></head><body>
+			<div class="sidebar"><img src="/undeclared.png"></div>
+			<div class="post">
+				<img src="/pixel.gif" width="1" height="1">
+				<img src="/wide.jpg" width="640px" height="50">
+				<p>` + paragraphs[0] + `</p>
+			</div>
+			<img src="/banner.jpg" width="600" height="300">
+			</body></html>`,
+			&PageSummary{
+				Excerpt:     paragraphs[0],
+				WordCount:   len(strings.Fields(paragraphs[0])),
+				ReadingTime: 1,
+				Images:      []*PreviewImage{{URL: "http://test.com/banner.jpg", Width: 600, Height: 300}},
+			},
+		},
+		{
+			"Long Text",
+			"The excerpt should be cut at a word boundary, and the reading time rounded up",
+			true,
+			`<html><head></head><body><div><p>` + longParagraph + `</p></div></body></html>`,
+			&PageSummary{
+				Excerpt:     excerpt(collapseSpace(longParagraph), MaxExcerptLength),
+				WordCount:   1000,
+				ReadingTime: 5,
+			},
+		},
+		{
+			"Unclosed Elements",
+			"Pages may leave out </head>, </p> and </li> tags",
+			true,
+			`<html><head><title>Untidy</title><body><div class="entry">
+			<p>` + paragraphs[0] + `
+			<p>` + paragraphs[1] + `
+			<ul><li>first item<li>second item</ul>
+			</div>`,
+			&PageSummary{
+				Title:       "Untidy",
+				Excerpt:     paragraphs[0] + " " + paragraphs[1],
+				WordCount:   len(strings.Fields(paragraphs[0]+" "+paragraphs[1])) + 4,
+				ReadingTime: 1,
+			},
+		},
+		{
+			"Scripts And Styles",
+			"The content of scripts, styles and the like isn't text",
+			true,
+			`<html><head></head><body><div>
+			<script>var message = "this is not part of the text, at all";</script>
+			<style>p { font-family: Georgia, serif, and so on, and so forth }</style>
+			<p>` + paragraphs[2] + `<noscript><img src="/fallback.png" width="800" height="600"></noscript></p>
+			</div></body></html>`,
+			&PageSummary{
+				Excerpt:     paragraphs[2],
+				WordCount:   len(strings.Fields(paragraphs[2])),
+				ReadingTime: 1,
+			},
+		},
+		{
+			"Empty Body",
+			"Pages without text shouldn't have an excerpt",
+			true,
+			`<html><head><title>Nothing here</title></head><body></body></html>`,
+			&PageSummary{
+				Title: "Nothing here",
+			},
+		},
+	}
+
+	for _, c := range cases {
+		summarizer := NewSummarizer()
+		summarizer.Deep = c.deep
+		summary, err := summarizer.Summarize(context.Background(), pageURL, strings.NewReader(c.html))
+		if err != nil {
+			t.Errorf("case %s: unexpected error %v\nHINT: %s\n", c.name, err, c.hint)
+			continue
+		}
+		if !reflect.DeepEqual(summary, c.expectedSummary) {
+			expectedJSON, _ := json.MarshalIndent(c.expectedSummary, "", "  ")
+			actualJSON, _ := json.MarshalIndent(summary, "", "  ")
+			t.Errorf("case %s: incorrect result:\nEXPECTED: %s\nACTUAL: %s\nHINT: %s\n",
+				c.name, string(expectedJSON), string(actualJSON), c.hint)
+		}
+	}
+}
+
+func TestContentExtractorLargePage(t *testing.T) {
+	story := "The ferry will run every half hour in the summer, the county said, and every hour in the winter."
+	var page strings.Builder
+	page.WriteString(`<html><body><article><p>` + story + `</p></article>`)
+	//far more blocks and elements than are scored, each
+	//nested inside the last, after the main content
+	for i := 0; i < 3*maxScoredElements; i++ {
+		page.WriteString(`<div class="related"><a href="/more">Read more stories like this one</a>`)
+	}
+	page.WriteString(`</body></html>`)
+
+	summarizer := NewSummarizer()
+	summarizer.Deep = true
+	summary, err := summarizer.Summarize(context.Background(), "http://test.com/", strings.NewReader(page.String()))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if summary.Excerpt != story {
+		t.Errorf("the main content should be found among the first blocks of a large page, but the excerpt was %q", summary.Excerpt)
+	}
+}
+
+func TestExcerpt(t *testing.T) {
+	cases := []struct {
+		text     string
+		max      int
+		expected string
+	}{
+		{"short enough", 20, "short enough"},
+		{"exactly twelve", 14, "exactly twelve"},
+		{"cut between words here", 12, "cut between…"},
+		{"cut in the middle of a word", 13, "cut in the…"},
+		{"trailing punctuation, removed", 22, "trailing punctuation…"},
+		{"éèêë ünïcödé", 8, "éèêë…"},
+		{"unbrokenword", 5, "unbro…"},
+	}
+
+	for _, c := range cases {
+		if actual := excerpt(c.text, c.max); actual != c.expected {
+			t.Errorf("case %q: expected %q but got %q", c.text, c.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package summary
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+//Document holds the parts of an HTML page's head
+//that Extractors read summary properties from, and
+//in deep mode, the readable content of its body
+type Document struct {
+	//URL is the URL the page was served from
+	URL *url.URL
+	//Base is the URL relative references in the page are resolved
+	//against: the page URL, unless the page has a <base href> element
+	Base *url.URL
+	//Title is the text of the first <title> element
+	Title string
+	//Meta holds all <meta> elements, in document order
+	Meta []html.Token
+	//Links holds all <link> elements, in document order
+	Links []html.Token
+	//Scripts holds the <script> elements with inline content in
+	//the page's head, and the JSON-LD scripts anywhere in the page
+	Scripts []Script
+	//Body holds the readable content of the page's body. It is
+	//nil unless the page was parsed with ParseDocumentBody.
+	Body *Body
+	//Fetcher is used by Extractors that fetch other resources the
+	//page links to. Summarizer sets it to the Summarizer's Fetcher.
+	Fetcher *Fetcher
+	//Context is the context of the request the page is being
+	//summarized for, which Extractors' fetches should be tied to.
+	//Summarizer sets it to the context it was given.
+	Context context.Context
+}
+
+//Script represents a <script> element with inline content
+type Script struct {
+	//Type is the value of the element's `type` attribute
+	Type string
+	//Text is the content of the element
+	Text string
+}
+
+//ParseDocument tokenizes the head of the HTML page read from `r`,
+//which was served from `pageURL`. The rest of the page is only
+//scanned for JSON-LD scripts, which many pages put in their body.
+func ParseDocument(pageURL string, r io.Reader) (*Document, error) {
+	return parseDocument(pageURL, r, false)
+}
+
+//ParseDocumentBody is like ParseDocument, but carries on tokenizing
+//after the head, collecting the text and images of the page's body
+//in the Document's Body
+func ParseDocumentBody(pageURL string, r io.Reader) (*Document, error) {
+	return parseDocument(pageURL, r, true)
+}
+
+//parseDocument tokenizes the HTML page read from `r`, which was served
+//from `pageURL`. After the head, only JSON-LD scripts are collected,
+//and the body's content too if `deep` is true.
+func parseDocument(pageURL string, r io.Reader, deep bool) (*Document, error) {
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing page URL: %v", err)
+	}
+	doc := &Document{
+		URL:  base,
+		Base: base,
+	}
+
+	tokenizer := html.NewTokenizer(r)
+	HTMLTitle := false
+	baseHref := false
+	afterHead := false
+	var body *bodyParser
+	//ldScript is the JSON-LD script after the head being read
+	var ldScript *Script
+	for {
+		tokenType := tokenizer.Next()
+		if tokenType == html.ErrorToken {
+			err := tokenizer.Err()
+			if err == io.EOF {
+				break
+			}
+			return nil, fmt.Errorf("error tokenizing HTML: %w", err)
+		}
+		if afterHead && body == nil {
+			//outside deep mode the rest of the page is only scanned
+			//for JSON-LD scripts, without making a Token of each token
+			switch tokenType {
+			case html.StartTagToken:
+				if name, hasAttr := tokenizer.TagName(); string(name) == "script" && hasAttr {
+					if typ := tagType(tokenizer); isJSONLD(typ) {
+						ldScript = &Script{Type: typ}
+					}
+				}
+			case html.TextToken:
+				if ldScript != nil {
+					ldScript.Text = string(tokenizer.Text())
+					doc.Scripts = append(doc.Scripts, *ldScript)
+					ldScript = nil
+				}
+			case html.EndTagToken:
+				ldScript = nil
+			}
+			continue
+		}
+		if afterHead {
+			token := tokenizer.Token()
+			switch {
+			case tokenType == html.StartTagToken && token.Data == "script":
+				if typ, _ := attr(token, "type"); isJSONLD(typ) {
+					ldScript = &Script{Type: typ}
+				}
+			case tokenType == html.TextToken && ldScript != nil:
+				ldScript.Text = token.Data
+				doc.Scripts = append(doc.Scripts, *ldScript)
+				ldScript = nil
+			case tokenType == html.EndTagToken && token.Data == "script":
+				ldScript = nil
+			}
+			body.token(tokenType, token)
+			continue
+		}
+		if tokenType != html.StartTagToken && tokenType != html.SelfClosingTagToken {
+			if tokenType == html.EndTagToken && "head" == tokenizer.Token().Data {
+				afterHead = true
+				if deep {
+					body = newBodyParser()
+				}
+			}
+			continue
+		}
+
+		token := tokenizer.Token()
+		switch token.Data {
+		case "title":
+			if !HTMLTitle && tokenizer.Next() == html.TextToken {
+				doc.Title = tokenizer.Token().Data
+				HTMLTitle = true
+			}
+		case "base":
+			//only the first <base href> in a document is used
+			if href, ok := attr(token, "href"); ok && !baseHref {
+				doc.Base = absoluteURL(doc.URL, href)
+				baseHref = true
+			}
+		case "meta":
+			doc.Meta = append(doc.Meta, token)
+		case "link":
+			doc.Links = append(doc.Links, token)
+		case "script":
+			typ, _ := attr(token, "type")
+			if tokenType == html.StartTagToken && tokenizer.Next() == html.TextToken {
+				doc.Scripts = append(doc.Scripts, Script{Type: typ, Text: tokenizer.Token().Data})
+			}
+		case "body":
+			//pages may leave out the </head> tag
+			if deep {
+				afterHead = true
+				body = newBodyParser()
+			}
+		}
+	}
+	if body != nil {
+		doc.Body = body.finish()
+	}
+	return doc, nil
+}
+
+//Resolve returns `ref` resolved to an absolute URL
+//against the document's base URL
+func (doc *Document) Resolve(ref string) string {
+	return absoluteURL(doc.Base, ref).String()
+}
+
+//absoluteURL resolves `u` against `base`, returning `base` itself
+//if `u` can't be parsed as a URL reference
+func absoluteURL(base *url.URL, u string) *url.URL {
+	relative, err := url.Parse(strings.TrimSpace(u))
+	if err != nil {
+		return base
+	}
+	return base.ResolveReference(relative)
+}
+
+//tagType returns the `type` attribute of the start tag `tokenizer`
+//is at, reading its attributes without making a Token of it
+func tagType(tokenizer *html.Tokenizer) string {
+	for more := true; more; {
+		var key, val []byte
+		key, val, more = tokenizer.TagAttr()
+		if string(key) == "type" {
+			return string(val)
+		}
+	}
+	return ""
+}
+
+//attr returns the value of the attribute `key` of token `t`,
+//and whether the token has that attribute at all
+func attr(t html.Token, key string) (string, bool) {
+	for _, a := range t.Attr {
+		if a.Key == key {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
+
+//metaProperty returns the property a <meta> token describes, from
+//either its `property` or `name` attribute, along with its content
+func metaProperty(t html.Token) (prop string, content string) {
+	prop, ok := attr(t, "property")
+	if !ok {
+		prop, _ = attr(t, "name")
+	}
+	content, _ = attr(t, "content")
+	return
+}
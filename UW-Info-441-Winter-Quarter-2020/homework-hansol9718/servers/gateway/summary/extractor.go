@@ -0,0 +1,20 @@
+package summary
+
+//Extractor extracts summary properties from one source of
+//metadata in a page, such as its Open Graph properties.
+//See Summarizer for how the results of several Extractors
+//are combined into one PageSummary
+type Extractor interface {
+	//Extract returns the summary properties found in `doc`, leaving
+	//any it doesn't know about empty. It returns nil if it found none.
+	Extract(doc *Document) *PageSummary
+}
+
+//ExtractorFunc is an adapter that allows an ordinary
+//function to be used as an Extractor
+type ExtractorFunc func(doc *Document) *PageSummary
+
+//Extract calls f(doc)
+func (f ExtractorFunc) Extract(doc *Document) *PageSummary {
+	return f(doc)
+}
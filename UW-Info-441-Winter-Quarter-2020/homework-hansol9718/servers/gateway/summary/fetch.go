@@ -0,0 +1,117 @@
+package summary
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html/charset"
+
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-hansol9718/servers/gateway/logging"
+)
+
+//ErrNotHTML is returned when asked to summarize
+//a URL that is not an HTML page
+var ErrNotHTML = errors.New("not an HTML page")
+
+//StatusError is returned when the server a page is fetched
+//from responds with an error status code
+type StatusError struct {
+	StatusCode int
+}
+
+//Error implements the error interface
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("server responded with status code %d", e.StatusCode)
+}
+
+//FetchHTML fetches `pageURL` and returns the response body along with
+//the URL the page was actually served from, which differs from `pageURL`
+//when the request was redirected. The request is canceled when `ctx` is
+//done, and the body is subject to the Fetcher's limits. The body is
+//transcoded to UTF-8 from whatever character set the page uses.
+func (f *Fetcher) FetchHTML(ctx context.Context, pageURL string) (io.ReadCloser, string, error) {
+	resp, err := f.fetchPage(ctx, pageURL, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	return resp.Body, resp.Request.URL.String(), nil
+}
+
+//fetchPage fetches `pageURL`, adding `header` to the request.
+//The response is either an HTML page, whose body is transcoded to UTF-8,
+//or a 304 Not Modified response to a conditional request.
+//The fetch is logged to the Fetcher's Log with the request ID `ctx` carries.
+func (f *Fetcher) fetchPage(ctx context.Context, pageURL string, header http.Header) (resp *http.Response, err error) {
+	start := time.Now()
+	defer func() {
+		f.logFetch(ctx, pageURL, start, resp, err)
+	}()
+
+	resp, err = f.Fetch(ctx, pageURL, header)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotModified {
+		return resp, nil
+	}
+
+	if resp.StatusCode >= 400 {
+		resp.Body.Close()
+		return nil, &StatusError{StatusCode: resp.StatusCode}
+	}
+
+	ctype := resp.Header.Get("Content-Type")
+	if !strings.HasPrefix(ctype, "text/html") {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%w: content type is %q", ErrNotHTML, ctype)
+	}
+
+	//the character set is taken from a byte order mark, the charset
+	//parameter of the Content-Type header, or a <meta> element near
+	//the start of the page, in that order of precedence
+	body, err := charset.NewReader(resp.Body, ctype)
+	if err != nil {
+		resp.Body.Close()
+		return nil, fmt.Errorf("error detecting character set: %w", err)
+	}
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{body, resp.Body}
+
+	return resp, nil
+}
+
+//logFetch logs the outcome of fetching `pageURL`
+func (f *Fetcher) logFetch(ctx context.Context, pageURL string, start time.Time, resp *http.Response, err error) {
+	if f.Log == nil {
+		return
+	}
+	fields := logging.Fields{
+		"msg":       "fetch",
+		"url":       pageURL,
+		"latencyMs": float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if id := logging.RequestID(ctx); len(id) > 0 {
+		fields["requestId"] = id
+	}
+	if resp != nil {
+		fields["status"] = resp.StatusCode
+		if finalURL := resp.Request.URL.String(); finalURL != pageURL {
+			fields["finalUrl"] = finalURL
+		}
+	}
+	if err != nil {
+		fields["error"] = err.Error()
+		var statusErr *StatusError
+		if errors.As(err, &statusErr) {
+			fields["status"] = statusErr.StatusCode
+		}
+	}
+	f.Log.Log(fields)
+}
@@ -0,0 +1,122 @@
+package summary
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-hansol9718/servers/gateway/logging"
+)
+
+func TestFetchHTML(t *testing.T) {
+	cases := []struct {
+		name        string
+		hint        string
+		URL         string
+		expectError bool
+	}{
+		{
+			"Valid URL",
+			"This is a valid HTML page, so this should work",
+			"https://info344-a17.github.io/tests/ogall.html",
+			false,
+		},
+		{
+			"Not Found URL",
+			"Remember to check the response status code",
+			"https://info344-a17.github.io/tests/not-found.html",
+			true,
+		},
+		{
+			"Non-HTML URL",
+			"Remember to check the response content-type to ensure it's an HTML page",
+			"https://info344-a17.github.io/tests/test.png",
+			true,
+		},
+	}
+
+	for _, c := range cases {
+		stream, _, err := NewFetcher().FetchHTML(context.Background(), c.URL)
+
+		if err != nil && !c.expectError {
+			t.Errorf("case %s: unexpected error %v\nHINT: %s", c.name, err, c.hint)
+		}
+		if c.expectError && err == nil {
+			t.Errorf("case %s: expected error but didn't get one\nHINT: %s", c.name, c.hint)
+		}
+
+		if stream != nil {
+			stream.Close()
+		}
+	}
+}
+
+func TestFetchHTMLRedirect(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/pages/test.html", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/pages/test.html", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte("<html><head></head></html>"))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	stream, pageURL, err := loopbackFetcher().FetchHTML(context.Background(), server.URL+"/moved")
+	if err != nil {
+		t.Fatalf("unexpected error fetching redirected page: %v", err)
+	}
+	stream.Close()
+	if pageURL != server.URL+"/pages/test.html" {
+		t.Errorf("incorrect page URL: expected %s but got %s", server.URL+"/pages/test.html", pageURL)
+	}
+}
+
+func TestFetchHTMLLogging(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html></html>"))
+	}))
+	defer server.Close()
+
+	buf := &bytes.Buffer{}
+	f := loopbackFetcher()
+	f.Log = logging.New(buf)
+	ctx := logging.WithRequestID(context.Background(), "req-1")
+	if body, _, err := f.FetchHTML(ctx, server.URL+"/page"); err == nil {
+		body.Close()
+	}
+	f.FetchHTML(ctx, server.URL+"/missing")
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected a log entry per fetch, but got %q", buf.String())
+	}
+	expected := []map[string]interface{}{
+		{"msg": "fetch", "requestId": "req-1", "url": server.URL + "/page", "status": 200.0},
+		{"msg": "fetch", "requestId": "req-1", "url": server.URL + "/missing", "status": 404.0},
+	}
+	for i, line := range lines {
+		entry := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("error decoding log entry %q: %v", line, err)
+		}
+		for name, value := range expected[i] {
+			if entry[name] != value {
+				t.Errorf("entry %d: incorrect %s: expected %v but got %v", i, name, value, entry[name])
+			}
+		}
+	}
+	if !strings.Contains(lines[1], `"error"`) {
+		t.Errorf("failed fetches should be logged with their error, but got %s", lines[1])
+	}
+}
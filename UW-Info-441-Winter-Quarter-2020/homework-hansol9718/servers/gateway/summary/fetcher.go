@@ -0,0 +1,382 @@
+package summary
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-hansol9718/servers/gateway/logging"
+)
+
+//ErrInvalidURL is returned when asked to fetch a URL that can't be parsed
+var ErrInvalidURL = errors.New("invalid URL")
+
+//ErrUnsupportedScheme is returned when asked to fetch
+//a URL whose scheme is not http or https
+var ErrUnsupportedScheme = errors.New("only http and https URLs may be fetched")
+
+//ErrForbiddenAddress is returned when asked to fetch a URL whose
+//host is, or resolves to, an address that may not be fetched from
+var ErrForbiddenAddress = errors.New("address may not be fetched from")
+
+//ErrTimeout is returned when fetching a URL takes longer than
+//the Fetcher's Timeout, or connecting takes longer than its ConnectTimeout
+var ErrTimeout = errors.New("timed out fetching URL")
+
+//ErrTooLarge is returned when reading more than
+//the Fetcher's MaxBytes from a response body
+var ErrTooLarge = errors.New("response body is too large")
+
+//ErrTooManyRedirects is returned when a URL redirects
+//more times than the Fetcher's MaxRedirects
+var ErrTooManyRedirects = errors.New("too many redirects")
+
+//Default limits used by NewFetcher
+const (
+	//DefaultConnectTimeout is how long a Fetcher may take to
+	//resolve a host and connect to it
+	DefaultConnectTimeout = 5 * time.Second
+	//DefaultFetchTimeout is how long a Fetcher may take to fetch
+	//a URL, including redirects and reading the response body
+	DefaultFetchTimeout = 15 * time.Second
+	//DefaultMaxRedirects is the number of redirects a Fetcher follows
+	DefaultMaxRedirects = 10
+	//DefaultMaxBytes is the most a Fetcher reads from a response body
+	DefaultMaxBytes = 2 << 20
+)
+
+//forbiddenNetworks are the networks, other than those covered by
+//the net.IP classification methods, that are not on the public
+//internet or can be used to reach networks that aren't
+var forbiddenNetworks = mustParseNetworks(
+	"0.0.0.0/8",     //"this" network
+	"100.64.0.0/10", //carrier-grade NAT
+	"192.0.0.0/24",  //IETF protocol assignments
+	"198.18.0.0/15", //benchmarking
+	"240.0.0.0/4",   //reserved, including broadcast
+	"64:ff9b::/96",  //NAT64, which maps to IPv4 addresses
+	"2002::/16",     //6to4, which embeds IPv4 addresses
+	"::/96",         //IPv4-compatible, which embeds IPv4 addresses
+	"2001::/32",     //Teredo, which embeds IPv4 addresses
+)
+
+//Resolver looks up the IP addresses of a host.
+// *net.Resolver implements this interface.
+type Resolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+//Fetcher fetches http and https URLs on behalf of the gateway's clients,
+//refusing to connect to loopback, private, link-local, multicast and
+//other addresses that aren't on the public internet, so that clients
+//can't use the gateway to reach services on its own network.
+//
+//The Fetcher resolves host names itself and checks every address it
+//connects to, including those it is redirected to. It never uses
+//a proxy, as the proxy would connect on its behalf unchecked.
+//
+//NewFetcher returns a Fetcher with the default limits, but a Fetcher
+//literal works too, with no limits other than those it sets. A Fetcher
+//must not be copied after first use.
+type Fetcher struct {
+	//Allow lists networks that may be fetched from even though
+	//they aren't public, such as a trusted internal service
+	Allow []*net.IPNet
+	//Deny lists networks, in addition to those that aren't public,
+	//that may never be fetched from. It takes precedence over Allow.
+	Deny []*net.IPNet
+	//Resolver looks up the addresses of host names.
+	//If nil, net.DefaultResolver is used.
+	Resolver Resolver
+	//Dial connects to an address that has passed all checks.
+	//If nil, a net.Dialer is used.
+	Dial func(ctx context.Context, network string, addr string) (net.Conn, error)
+
+	//ConnectTimeout limits how long resolving a host and connecting
+	//to it may take. Zero means no limit.
+	ConnectTimeout time.Duration
+	//Timeout limits how long fetching a URL may take, from sending
+	//the request until the response body is closed. Zero means no limit.
+	Timeout time.Duration
+	//MaxRedirects is the number of redirects that are followed.
+	//Zero means redirects are not followed.
+	MaxRedirects int
+	//MaxBytes is the most that may be read from a response body.
+	//Zero means no limit.
+	MaxBytes int64
+	//Log is the logger page fetches are logged to, along with the
+	//request ID of the gateway request they were made for. If nil,
+	//they aren't logged.
+	Log *logging.Logger
+
+	clientOnce sync.Once
+	client     *http.Client
+}
+
+//NewFetcher constructs a new Fetcher with the default limits
+func NewFetcher() *Fetcher {
+	return &Fetcher{
+		ConnectTimeout: DefaultConnectTimeout,
+		Timeout:        DefaultFetchTimeout,
+		MaxRedirects:   DefaultMaxRedirects,
+		MaxBytes:       DefaultMaxBytes,
+	}
+}
+
+//httpClient returns the http.Client the Fetcher sends requests
+//with, which is built on first use so that it always dials
+//through the Fetcher's checks
+func (f *Fetcher) httpClient() *http.Client {
+	f.clientOnce.Do(func() {
+		f.client = &http.Client{
+			Transport: &http.Transport{
+				Proxy:                 nil,
+				DialContext:           f.dialContext,
+				ForceAttemptHTTP2:     true,
+				MaxIdleConns:          100,
+				IdleConnTimeout:       90 * time.Second,
+				TLSHandshakeTimeout:   10 * time.Second,
+				ExpectContinueTimeout: 1 * time.Second,
+			},
+			CheckRedirect: f.checkRedirect,
+		}
+	})
+	return f.client
+}
+
+//Fetch sends a GET request for `rawURL`, with the headers in `header`,
+//and returns the response, whatever its status code. The request is
+//canceled when `ctx` is done or the Fetcher's Timeout passes, and
+//reading more than the Fetcher's MaxBytes from the response body
+//returns ErrTooLarge. The caller must close the response body.
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string, header http.Header) (*http.Response, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidURL, err)
+	}
+	if err := checkScheme(u); err != nil {
+		return nil, err
+	}
+
+	cancel := context.CancelFunc(func() {})
+	if f.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, f.Timeout)
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	resp, err := f.httpClient().Do(req)
+	if err != nil {
+		cancel()
+		return nil, fetchError(err)
+	}
+	resp.Body = &fetchBody{body: resp.Body, max: f.MaxBytes, cancel: cancel}
+	return resp, nil
+}
+
+//Allowed reports whether the Fetcher may connect to `ip`
+func (f *Fetcher) Allowed(ip net.IP) bool {
+	if ip4 := ip.To4(); ip4 != nil {
+		//treats IPv4-mapped IPv6 addresses, like ::ffff:127.0.0.1,
+		//the same as the IPv4 addresses they map to
+		ip = ip4
+	}
+	if containsIP(f.Deny, ip) {
+		return false
+	}
+	if containsIP(f.Allow, ip) {
+		return true
+	}
+	return isPublic(ip)
+}
+
+//checkRedirect checks each URL the Fetcher is redirected to
+//before following the redirect
+func (f *Fetcher) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) > f.MaxRedirects {
+		return fmt.Errorf("%w: stopped after %d redirects", ErrTooManyRedirects, f.MaxRedirects)
+	}
+	return checkScheme(req.URL)
+}
+
+//dialContext connects to `addr`, after resolving its host and
+//checking that the Fetcher may connect to the resulting address
+func (f *Fetcher) dialContext(ctx context.Context, network string, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if f.ConnectTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, f.ConnectTimeout)
+		defer cancel()
+	}
+
+	var ips []net.IP
+	if ip := net.ParseIP(host); ip != nil {
+		ips = []net.IP{ip}
+	} else {
+		resolver := f.Resolver
+		if resolver == nil {
+			resolver = net.DefaultResolver
+		}
+		addrs, err := resolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range addrs {
+			ips = append(ips, a.IP)
+		}
+	}
+
+	dial := f.Dial
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+	//connects to the first address that is allowed and reachable,
+	//by IP so that the host can't be resolved again differently
+	err = fmt.Errorf("%w: %s has no addresses", ErrForbiddenAddress, host)
+	for _, ip := range ips {
+		if !f.Allowed(ip) {
+			err = fmt.Errorf("%w: %s resolves to %s", ErrForbiddenAddress, host, ip)
+			continue
+		}
+		conn, dialErr := dial(ctx, network, net.JoinHostPort(ip.String(), port))
+		if dialErr == nil {
+			return conn, nil
+		}
+		err = dialErr
+	}
+	return nil, err
+}
+
+//fetchBody is the body of a response from a Fetcher. It limits
+//how much can be read, and cancels the request when closed.
+type fetchBody struct {
+	body io.ReadCloser
+	//max is the most that may be read, or zero if there is no limit
+	max    int64
+	read   int64
+	cancel context.CancelFunc
+}
+
+//Read implements the io.Reader interface
+func (b *fetchBody) Read(p []byte) (int, error) {
+	if b.max > 0 {
+		if b.read > b.max {
+			return 0, fmt.Errorf("%w: more than %d bytes", ErrTooLarge, b.max)
+		}
+		//reads one byte more than allowed, to tell bodies
+		//that are exactly the limit from those over it
+		if left := b.max - b.read + 1; int64(len(p)) > left {
+			p = p[:left]
+		}
+	}
+	n, err := b.body.Read(p)
+	b.read += int64(n)
+	if b.max > 0 && b.read > b.max {
+		return n - 1, fmt.Errorf("%w: more than %d bytes", ErrTooLarge, b.max)
+	}
+	if err != nil && err != io.EOF {
+		err = fetchError(err)
+	}
+	return n, err
+}
+
+//Close implements the io.Closer interface
+func (b *fetchBody) Close() error {
+	err := b.body.Close()
+	b.cancel()
+	return err
+}
+
+//fetchError wraps `err` with ErrTimeout if it was caused by a timeout
+func fetchError(err error) error {
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return fmt.Errorf("%w: %v", ErrTimeout, err)
+	}
+	return err
+}
+
+//checkScheme returns ErrUnsupportedScheme if `u`
+//is not an http or https URL
+func checkScheme(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%w: %q", ErrUnsupportedScheme, u.Scheme)
+	}
+	return nil
+}
+
+//isPublic reports whether `ip` is a public unicast address
+func isPublic(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	return !containsIP(forbiddenNetworks, ip)
+}
+
+//containsIP reports whether any of `networks` contains `ip`
+func containsIP(networks []*net.IPNet, ip net.IP) bool {
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+//ParseNetworks parses a comma-separated list of networks
+//in CIDR notation, such as "10.0.0.0/8, 192.168.1.1/32".
+//A plain IP address is treated as a network of one address.
+func ParseNetworks(list string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, s := range strings.Split(list, ",") {
+		s = strings.TrimSpace(s)
+		if len(s) == 0 {
+			continue
+		}
+		if !strings.Contains(s, "/") {
+			ip := net.ParseIP(s)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP address %q", s)
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network %q: %v", s, err)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+//mustParseNetworks parses the networks in CIDR notation,
+//panicking if any is invalid
+func mustParseNetworks(cidrs ...string) []*net.IPNet {
+	networks, err := ParseNetworks(strings.Join(cidrs, ","))
+	if err != nil {
+		panic(err)
+	}
+	return networks
+}
@@ -0,0 +1,380 @@
+package summary
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+//loopbackFetcher returns a Fetcher that may fetch from
+//the loopback network, where httptest servers listen
+func loopbackFetcher() *Fetcher {
+	f := NewFetcher()
+	f.Allow = mustParseNetworks("127.0.0.0/8", "::1/128")
+	return f
+}
+
+//fakeResolver resolves host names from a map
+type fakeResolver map[string][]string
+
+func (r fakeResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	var addrs []net.IPAddr
+	for _, ip := range r[host] {
+		addrs = append(addrs, net.IPAddr{IP: net.ParseIP(ip)})
+	}
+	if len(addrs) == 0 {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	return addrs, nil
+}
+
+func TestFetcherAllowed(t *testing.T) {
+	cases := []struct {
+		ip      string
+		allow   string
+		deny    string
+		allowed bool
+	}{
+		{"93.184.216.34", "", "", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", "", "", true},
+		{"127.0.0.1", "", "", false},
+		{"127.8.9.10", "", "", false},
+		{"::1", "", "", false},
+		{"::ffff:127.0.0.1", "", "", false},
+		{"::ffff:10.1.2.3", "", "", false},
+		{"0.0.0.0", "", "", false},
+		{"::", "", "", false},
+		{"10.0.0.1", "", "", false},
+		{"172.16.5.4", "", "", false},
+		{"192.168.1.1", "", "", false},
+		{"100.64.0.1", "", "", false},
+		{"169.254.169.254", "", "", false},
+		{"fe80::1", "", "", false},
+		{"fd00::1", "", "", false},
+		{"224.0.0.1", "", "", false},
+		{"ff02::1", "", "", false},
+		{"255.255.255.255", "", "", false},
+		{"64:ff9b::7f00:1", "", "", false},
+		{"2002:7f00:1::1", "", "", false},
+		{"::a00:1", "", "", false},
+		{"2001:0:4136:e378:8000:63bf:3fff:fdd2", "", "", false},
+		{"10.1.2.3", "10.1.0.0/16", "", true},
+		{"::ffff:10.1.2.3", "10.1.0.0/16", "", true},
+		{"10.2.0.1", "10.1.0.0/16", "", false},
+		{"93.184.216.34", "", "93.184.216.0/24", false},
+		{"10.1.2.3", "10.0.0.0/8", "10.1.2.3", false},
+	}
+
+	for _, c := range cases {
+		f := NewFetcher()
+		f.Allow, _ = ParseNetworks(c.allow)
+		f.Deny, _ = ParseNetworks(c.deny)
+		if allowed := f.Allowed(net.ParseIP(c.ip)); allowed != c.allowed {
+			t.Errorf("case %s (allow %q, deny %q): expected allowed to be %t but got %t",
+				c.ip, c.allow, c.deny, c.allowed, allowed)
+		}
+	}
+}
+
+func TestFetcherLiteral(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	//a Fetcher that wasn't made with NewFetcher still checks addresses
+	f := &Fetcher{}
+	if _, err := f.Fetch(context.Background(), server.URL, nil); !errors.Is(err, ErrForbiddenAddress) {
+		t.Errorf("expected a Fetcher literal to refuse loopback addresses, but got error %v", err)
+	}
+	f = &Fetcher{Allow: mustParseNetworks("127.0.0.0/8", "::1/128")}
+	resp, err := f.Fetch(context.Background(), server.URL, nil)
+	if err != nil {
+		t.Fatalf("unexpected error fetching with a Fetcher literal: %v", err)
+	}
+	defer resp.Body.Close()
+	if body, _ := ioutil.ReadAll(resp.Body); string(body) != "ok" {
+		t.Errorf("incorrect response body: expected %q but got %q", "ok", string(body))
+	}
+}
+
+func TestFetcher(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/to-private":
+			http.Redirect(w, r, "http://private.test/", http.StatusFound)
+		case "/to-mapped":
+			http.Redirect(w, r, "http://mapped.test/", http.StatusFound)
+		case "/to-public":
+			http.Redirect(w, r, "http://public.test/", http.StatusFound)
+		case "/to-file":
+			http.Redirect(w, r, "file:///etc/passwd", http.StatusFound)
+		default:
+			w.Write([]byte("ok"))
+		}
+	}))
+	defer server.Close()
+
+	//every allowed connection goes to the test server,
+	//whatever address the host name resolved to
+	dialed := []string{}
+	f := NewFetcher()
+	f.Resolver = fakeResolver{
+		"public.test":  {"93.184.216.34"},
+		"private.test": {"10.0.0.1"},
+		"mapped.test":  {"::ffff:127.0.0.1"},
+		"mixed.test":   {"192.168.0.1", "93.184.216.35"},
+		"meta.test":    {"169.254.169.254"},
+	}
+	f.Dial = func(ctx context.Context, network string, addr string) (net.Conn, error) {
+		dialed = append(dialed, addr)
+		return (&net.Dialer{}).DialContext(ctx, network, server.Listener.Addr().String())
+	}
+
+	cases := []struct {
+		name           string
+		hint           string
+		URL            string
+		expectedErr    error
+		expectedDialed string
+	}{
+		{
+			"Public Host",
+			"Hosts that resolve to public addresses should be fetched",
+			"http://public.test/",
+			nil,
+			"93.184.216.34:80",
+		},
+		{
+			"Private Host",
+			"Hosts that resolve to private addresses must not be fetched",
+			"http://private.test/",
+			ErrForbiddenAddress,
+			"",
+		},
+		{
+			"Metadata Host",
+			"Cloud metadata services are on link-local addresses",
+			"http://meta.test/latest/meta-data/",
+			ErrForbiddenAddress,
+			"",
+		},
+		{
+			"IPv4-Mapped Host",
+			"IPv4-mapped IPv6 addresses must be checked as the IPv4 address they map to",
+			"http://mapped.test/",
+			ErrForbiddenAddress,
+			"",
+		},
+		{
+			"Mixed Addresses",
+			"Only the host's allowed addresses should be connected to",
+			"http://mixed.test:8080/",
+			nil,
+			"93.184.216.35:8080",
+		},
+		{
+			"Loopback Literal",
+			"IP address literals must be checked too",
+			"http://127.0.0.1/",
+			ErrForbiddenAddress,
+			"",
+		},
+		{
+			"IPv6 Loopback Literal",
+			"IPv6 address literals must be checked too",
+			"http://[::1]:8080/",
+			ErrForbiddenAddress,
+			"",
+		},
+		{
+			"Redirect To Private Host",
+			"Every address the fetcher is redirected to must be checked",
+			"http://public.test/to-private",
+			ErrForbiddenAddress,
+			"93.184.216.34:80",
+		},
+		{
+			"Redirect To IPv4-Mapped Host",
+			"Every address the fetcher is redirected to must be checked",
+			"http://public.test/to-mapped",
+			ErrForbiddenAddress,
+			"93.184.216.34:80",
+		},
+		{
+			"Redirect To Public Host",
+			"Redirects to public hosts should be followed",
+			"http://public.test/to-public",
+			nil,
+			"93.184.216.34:80",
+		},
+		{
+			"File Scheme",
+			"Only http and https URLs may be fetched",
+			"file:///etc/passwd",
+			ErrUnsupportedScheme,
+			"",
+		},
+		{
+			"Gopher Scheme",
+			"Only http and https URLs may be fetched",
+			"gopher://public.test/",
+			ErrUnsupportedScheme,
+			"",
+		},
+		{
+			"Redirect To File Scheme",
+			"Only http and https URLs may be redirected to",
+			"http://public.test/to-file",
+			ErrUnsupportedScheme,
+			"93.184.216.34:80",
+		},
+	}
+
+	//every request must dial, so that its connections are recorded
+	f.httpClient().Transport.(*http.Transport).DisableKeepAlives = true
+
+	for _, c := range cases {
+		dialed = dialed[:0]
+		resp, err := f.Fetch(context.Background(), c.URL, nil)
+		if resp != nil {
+			resp.Body.Close()
+		}
+		if c.expectedErr == nil && err != nil {
+			t.Errorf("case %s: unexpected error: %v\nHINT: %s", c.name, err, c.hint)
+		}
+		if c.expectedErr != nil && !errors.Is(err, c.expectedErr) {
+			t.Errorf("case %s: expected error %v but got %v\nHINT: %s", c.name, c.expectedErr, err, c.hint)
+		}
+		if strings.Join(dialed, ",") != c.expectedDialed && !(len(dialed) > 0 && dialed[0] == c.expectedDialed) {
+			t.Errorf("case %s: expected to connect to %q first, but connected to %v\nHINT: %s",
+				c.name, c.expectedDialed, dialed, c.hint)
+		}
+	}
+}
+
+func TestFetcherLimits(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(5 * time.Second):
+		case <-r.Context().Done():
+		}
+	})
+	mux.HandleFunc("/slow-body", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<html><head>"))
+		w.(http.Flusher).Flush()
+		select {
+		case <-time.After(5 * time.Second):
+		case <-r.Context().Done():
+		}
+	})
+	mux.HandleFunc("/bytes/", func(w http.ResponseWriter, r *http.Request) {
+		n, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/bytes/"))
+		w.Write([]byte(strings.Repeat("a", n)))
+	})
+	mux.HandleFunc("/redirect/", func(w http.ResponseWriter, r *http.Request) {
+		n, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/redirect/"))
+		if n == 0 {
+			w.Write([]byte("ok"))
+			return
+		}
+		http.Redirect(w, r, "/redirect/"+strconv.Itoa(n-1), http.StatusFound)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	f := loopbackFetcher()
+	f.Timeout = 100 * time.Millisecond
+	f.MaxBytes = 100
+	f.MaxRedirects = 3
+
+	cases := []struct {
+		name        string
+		hint        string
+		path        string
+		expectedErr error
+	}{
+		{
+			"Slow Response",
+			"Fetching must stop after the Fetcher's Timeout",
+			"/slow",
+			ErrTimeout,
+		},
+		{
+			"Slow Body",
+			"The Timeout must include reading the response body",
+			"/slow-body",
+			ErrTimeout,
+		},
+		{
+			"Small Body",
+			"Bodies under MaxBytes should be read in full",
+			"/bytes/99",
+			nil,
+		},
+		{
+			"Body At Limit",
+			"Bodies of exactly MaxBytes should be read in full",
+			"/bytes/100",
+			nil,
+		},
+		{
+			"Large Body",
+			"Reading more than MaxBytes must fail",
+			"/bytes/101",
+			ErrTooLarge,
+		},
+		{
+			"Huge Body",
+			"Reading more than MaxBytes must fail",
+			"/bytes/100000",
+			ErrTooLarge,
+		},
+		{
+			"Redirects At Limit",
+			"MaxRedirects redirects should be followed",
+			"/redirect/3",
+			nil,
+		},
+		{
+			"Too Many Redirects",
+			"Fetching must stop after MaxRedirects redirects",
+			"/redirect/4",
+			ErrTooManyRedirects,
+		},
+	}
+
+	for _, c := range cases {
+		var body []byte
+		resp, err := f.Fetch(context.Background(), server.URL+c.path, nil)
+		if err == nil {
+			body, err = ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+		}
+		if c.expectedErr == nil && err != nil {
+			t.Errorf("case %s: unexpected error: %v\nHINT: %s", c.name, err, c.hint)
+		}
+		if c.expectedErr != nil && !errors.Is(err, c.expectedErr) {
+			t.Errorf("case %s: expected error %v but got %v\nHINT: %s", c.name, c.expectedErr, err, c.hint)
+		}
+		if c.expectedErr == ErrTooLarge && len(body) > int(f.MaxBytes) {
+			t.Errorf("case %s: read %d bytes, more than the limit of %d\nHINT: %s", c.name, len(body), f.MaxBytes, c.hint)
+		}
+	}
+
+	//requests must also stop when the caller's context is done
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	f.Timeout = 0
+	if _, err := f.Fetch(ctx, server.URL+"/slow", nil); !errors.Is(err, ErrTimeout) {
+		t.Errorf("expected error %v when the context's deadline passed but got %v", ErrTimeout, err)
+	}
+}
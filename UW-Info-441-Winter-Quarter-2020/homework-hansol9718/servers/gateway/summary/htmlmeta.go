@@ -0,0 +1,33 @@
+package summary
+
+import (
+	"strings"
+)
+
+//HTMLMetaExtractor extracts the summary properties available from
+//plain HTML: the <title> element, and the description, author and
+//keywords <meta> elements. Icons are extracted by IconExtractor.
+type HTMLMetaExtractor struct{}
+
+//Extract implements the Extractor interface
+func (e *HTMLMetaExtractor) Extract(doc *Document) *PageSummary {
+	page := &PageSummary{
+		Title: doc.Title,
+	}
+	for _, token := range doc.Meta {
+		prop, content := metaProperty(token)
+		switch prop {
+		case "description":
+			page.Description = content
+		case "author":
+			page.Author = content
+		case "keywords":
+			s := strings.Split(content, ",")
+			for i, val := range s {
+				s[i] = strings.TrimSpace(val)
+			}
+			page.Keywords = s
+		}
+	}
+	return page
+}
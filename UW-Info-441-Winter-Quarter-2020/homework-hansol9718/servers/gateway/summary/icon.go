@@ -0,0 +1,230 @@
+package summary
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+//DefaultIconSize is the size in pixels of the icon
+//the default IconExtractor chooses for a summary
+const DefaultIconSize = 32
+
+//maxManifestBytes is the most that is read from a web app manifest
+const maxManifestBytes = 1 << 20
+
+//Kinds of icons, which are the Rel of the icons in a summary
+const (
+	IconRelIcon           = "icon"
+	IconRelAppleTouchIcon = "apple-touch-icon"
+	IconRelMaskIcon       = "mask-icon"
+	IconRelManifest       = "manifest"
+)
+
+//iconRels maps the rel tokens of <link> elements that
+//declare icons to the kind of icon they declare
+var iconRels = map[string]string{
+	"icon":                         IconRelIcon,
+	"apple-touch-icon":             IconRelAppleTouchIcon,
+	"apple-touch-icon-precomposed": IconRelAppleTouchIcon,
+	"mask-icon":                    IconRelMaskIcon,
+}
+
+//webManifest represents the parts of a web app manifest
+//that are read for icons. See https://www.w3.org/TR/appmanifest
+type webManifest struct {
+	Icons []struct {
+		Src     string `json:"src"`
+		Sizes   string `json:"sizes"`
+		Type    string `json:"type"`
+		Purpose string `json:"purpose"`
+	} `json:"icons"`
+}
+
+//IconExtractor collects every icon a page declares: those of <link>
+//elements whose rel includes icon, apple-touch-icon or mask-icon, which
+//also matches rel="shortcut icon", followed by those in the page's web
+//app manifest, which is fetched using the Document's Fetcher. An icon
+//is collected for each of the sizes in an element's sizes attribute.
+//The icons become the summary's Icons, and the best of them for Size,
+//as chosen by BestIcon, becomes its Icon.
+type IconExtractor struct {
+	//Size is the size in pixels the Icon is to be shown at
+	Size int
+}
+
+//Extract implements the Extractor interface
+func (e *IconExtractor) Extract(doc *Document) *PageSummary {
+	var icons []*PreviewImage
+	manifestURL := ""
+	for _, token := range doc.Links {
+		rel, _ := attr(token, "rel")
+		href, _ := attr(token, "href")
+		if len(strings.TrimSpace(href)) == 0 {
+			continue
+		}
+		for _, t := range strings.Fields(strings.ToLower(rel)) {
+			if kind, ok := iconRels[t]; ok {
+				typ, _ := attr(token, "type")
+				sizes, _ := attr(token, "sizes")
+				icons = append(icons, sizedIcons(doc.Resolve(href), typ, kind, sizes)...)
+				break
+			}
+			//only the first manifest in a document is used
+			if t == "manifest" && len(manifestURL) == 0 {
+				manifestURL = doc.Resolve(href)
+			}
+		}
+	}
+	if len(manifestURL) > 0 && doc.Fetcher != nil {
+		ctx := doc.Context
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		//pages whose manifest can't be fetched just
+		//have the icons declared in the page
+		if manifestIcons, err := fetchManifestIcons(ctx, doc.Fetcher, manifestURL); err == nil {
+			icons = append(icons, manifestIcons...)
+		}
+	}
+	if len(icons) == 0 {
+		return nil
+	}
+	return &PageSummary{
+		Icon:  BestIcon(icons, e.Size),
+		Icons: icons,
+	}
+}
+
+//fetchManifestIcons fetches the web app manifest at `manifestURL`
+//and returns the icons in it, other than those that are only for
+//a purpose like "maskable" or "monochrome"
+func fetchManifestIcons(ctx context.Context, fetcher *Fetcher, manifestURL string) ([]*PreviewImage, error) {
+	resp, err := fetcher.Fetch(ctx, manifestURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("manifest server responded with status code %d", resp.StatusCode)
+	}
+
+	manifest := &webManifest{}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxManifestBytes)).Decode(manifest); err != nil {
+		return nil, fmt.Errorf("error decoding manifest: %v", err)
+	}
+	var icons []*PreviewImage
+	for _, icon := range manifest.Icons {
+		if len(strings.TrimSpace(icon.Src)) == 0 {
+			continue
+		}
+		if len(strings.TrimSpace(icon.Purpose)) > 0 && !hasToken(icon.Purpose, "any") {
+			continue
+		}
+		//icon URLs are relative to the manifest, not the page
+		src := absoluteURL(resp.Request.URL, icon.Src).String()
+		icons = append(icons, sizedIcons(src, icon.Type, IconRelManifest, icon.Sizes)...)
+	}
+	return icons, nil
+}
+
+//sizedIcons returns an icon at `iconURL` for each of the
+//sizes in the space-separated list `sizes`, or a single icon
+//without a size if the list is empty
+func sizedIcons(iconURL string, typ string, rel string, sizes string) []*PreviewImage {
+	var icons []*PreviewImage
+	for _, size := range strings.Fields(strings.ToLower(sizes)) {
+		icon := &PreviewImage{URL: iconURL, Type: typ, Rel: rel}
+		//sizes are WIDTHxHEIGHT, like "100x200",
+		//or "any" when the icon is scalable
+		dimensions := strings.Split(size, "x")
+		icon.Width, _ = strconv.Atoi(dimensions[0])
+		if len(dimensions) > 1 {
+			icon.Height, _ = strconv.Atoi(dimensions[1])
+		}
+		icons = append(icons, icon)
+	}
+	if len(icons) == 0 {
+		icons = append(icons, &PreviewImage{URL: iconURL, Type: typ, Rel: rel})
+	}
+	return icons
+}
+
+//Ranks of icons when choosing the best one, from worst to best
+const (
+	rankMask = iota
+	rankUnsized
+	rankSmaller
+	rankScalable
+	rankLarger
+)
+
+//BestIcon returns the icon in `icons` best suited to being shown at
+//`size` pixels: the smallest icon at least that size, or failing that
+//a scalable SVG icon, or failing that the largest icon smaller than
+//it. Icons without a declared size are only chosen when no icon has
+//one, and mask icons, which are monochrome, when there are no others.
+//The first of equally good icons is chosen. BestIcon returns nil if
+//`icons` is empty.
+func BestIcon(icons []*PreviewImage, size int) *PreviewImage {
+	var best *PreviewImage
+	bestRank, bestSize := -1, 0
+	for _, icon := range icons {
+		rank, iconSize := iconRank(icon, size)
+		better := rank > bestRank ||
+			rank == bestRank && rank == rankLarger && iconSize < bestSize ||
+			rank == bestRank && rank == rankSmaller && iconSize > bestSize
+		if better {
+			best, bestRank, bestSize = icon, rank, iconSize
+		}
+	}
+	return best
+}
+
+//iconRank returns the rank of `icon` when choosing the best icon
+//for `size`, along with the icon's size, its larger dimension
+func iconRank(icon *PreviewImage, size int) (int, int) {
+	iconSize := icon.Width
+	if icon.Height > iconSize {
+		iconSize = icon.Height
+	}
+	switch {
+	case icon.Rel == IconRelMaskIcon:
+		return rankMask, iconSize
+	case iconSize >= size && iconSize > 0:
+		return rankLarger, iconSize
+	case strings.EqualFold(icon.Type, "image/svg+xml") || strings.HasSuffix(strings.ToLower(icon.URL), ".svg"):
+		return rankScalable, iconSize
+	case iconSize > 0:
+		return rankSmaller, iconSize
+	default:
+		return rankUnsized, iconSize
+	}
+}
+
+//probeFavicon returns the icon at /favicon.ico on the host `pageURL`
+//is on, which is where browsers look for an icon when a page doesn't
+//declare one, or nil if there isn't an image there
+func (s *Summarizer) probeFavicon(ctx context.Context, pageURL *url.URL) *PreviewImage {
+	faviconURL := pageURL.ResolveReference(&url.URL{Path: "/favicon.ico"})
+	resp, err := s.fetcher().Fetch(ctx, faviconURL.String(), nil)
+	if err != nil {
+		return nil
+	}
+	resp.Body.Close()
+	typ, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(typ, "image/") {
+		return nil
+	}
+	return &PreviewImage{
+		URL:  resp.Request.URL.String(),
+		Type: typ,
+		Rel:  IconRelIcon,
+	}
+}
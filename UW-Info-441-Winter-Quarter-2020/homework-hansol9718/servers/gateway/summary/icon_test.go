@@ -0,0 +1,228 @@
+package summary
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestIconExtractor(t *testing.T) {
+	manifests := map[string]string{
+		"/app/manifest.json": `{"name": "Test", "icons": [
+			{"src": "icons/192.png", "sizes": "192x192", "type": "image/png"},
+			{"src": "/icons/maskable.png", "sizes": "512x512", "purpose": "maskable"},
+			{"src": "icons/512.png", "sizes": "512x512", "type": "image/png", "purpose": "any maskable"}
+		]}`,
+		"/bad/manifest.json": `<html></html>`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		manifest, ok := manifests[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/manifest+json")
+		w.Write([]byte(manifest))
+	}))
+	defer server.Close()
+
+	page := func(links string) string {
+		return `<html><head>` + links + `</head></html>`
+	}
+
+	cases := []struct {
+		name          string
+		hint          string
+		html          string
+		size          int
+		expectedIcon  *PreviewImage
+		expectedIcons []*PreviewImage
+	}{
+		{
+			"Rel Tokens",
+			"rel is a space-separated list of tokens, so rel=\"shortcut icon\" declares an icon too",
+			page(`<link rel="shortcut icon" href="/favicon.ico">
+				<link rel="stylesheet" href="/style.css">
+				<link rel="Apple-Touch-Icon" href="/touch.png" sizes="180x180">`),
+			32,
+			&PreviewImage{URL: "http://test.com/touch.png", Width: 180, Height: 180, Rel: IconRelAppleTouchIcon},
+			[]*PreviewImage{
+				{URL: "http://test.com/favicon.ico", Rel: IconRelIcon},
+				{URL: "http://test.com/touch.png", Width: 180, Height: 180, Rel: IconRelAppleTouchIcon},
+			},
+		},
+		{
+			"Sizes",
+			"An icon should be collected for every entry in sizes, and the smallest large enough one chosen",
+			page(`<link rel="icon" href="/favicon.ico" sizes="16x16 32X32 64x64" type="image/x-icon">
+				<link rel="apple-touch-icon-precomposed" href="/touch.png">`),
+			24,
+			&PreviewImage{URL: "http://test.com/favicon.ico", Type: "image/x-icon", Width: 32, Height: 32, Rel: IconRelIcon},
+			[]*PreviewImage{
+				{URL: "http://test.com/favicon.ico", Type: "image/x-icon", Width: 16, Height: 16, Rel: IconRelIcon},
+				{URL: "http://test.com/favicon.ico", Type: "image/x-icon", Width: 32, Height: 32, Rel: IconRelIcon},
+				{URL: "http://test.com/favicon.ico", Type: "image/x-icon", Width: 64, Height: 64, Rel: IconRelIcon},
+				{URL: "http://test.com/touch.png", Rel: IconRelAppleTouchIcon},
+			},
+		},
+		{
+			"Non-Square Size",
+			"Sizes are WIDTHxHEIGHT, so a wide icon should keep its width and height the right way round",
+			page(`<link rel="icon" href="/wide.png" sizes="120x60">`),
+			32,
+			&PreviewImage{URL: "http://test.com/wide.png", Width: 120, Height: 60, Rel: IconRelIcon},
+			[]*PreviewImage{
+				{URL: "http://test.com/wide.png", Width: 120, Height: 60, Rel: IconRelIcon},
+			},
+		},
+		{
+			"Scalable And Mask Icons",
+			"A scalable icon should be preferred to one that's too small, and mask icons only used as a last resort",
+			page(`<link rel="mask-icon" href="/mask.svg" color="#000000">
+				<link rel="icon" href="/small.png" sizes="16x16">
+				<link rel="icon" href="/icon.svg" sizes="any" type="image/svg+xml">`),
+			64,
+			&PreviewImage{URL: "http://test.com/icon.svg", Type: "image/svg+xml", Rel: IconRelIcon},
+			[]*PreviewImage{
+				{URL: "http://test.com/mask.svg", Rel: IconRelMaskIcon},
+				{URL: "http://test.com/small.png", Width: 16, Height: 16, Rel: IconRelIcon},
+				{URL: "http://test.com/icon.svg", Type: "image/svg+xml", Rel: IconRelIcon},
+			},
+		},
+		{
+			"Manifest",
+			"Icons in the web app manifest should be resolved against the manifest's URL, skipping maskable-only icons",
+			page(`<link rel="icon" href="/favicon.ico" sizes="32x32">
+				<link rel="manifest" href="` + server.URL + `/app/manifest.json">`),
+			256,
+			&PreviewImage{URL: server.URL + "/app/icons/512.png", Type: "image/png", Width: 512, Height: 512, Rel: IconRelManifest},
+			[]*PreviewImage{
+				{URL: "http://test.com/favicon.ico", Width: 32, Height: 32, Rel: IconRelIcon},
+				{URL: server.URL + "/app/icons/192.png", Type: "image/png", Width: 192, Height: 192, Rel: IconRelManifest},
+				{URL: server.URL + "/app/icons/512.png", Type: "image/png", Width: 512, Height: 512, Rel: IconRelManifest},
+			},
+		},
+		{
+			"Bad Manifest",
+			"Pages whose manifest can't be read should still have the icons they declare",
+			page(`<link rel="manifest" href="` + server.URL + `/bad/manifest.json">
+				<link rel="icon" href="/favicon.ico">`),
+			32,
+			&PreviewImage{URL: "http://test.com/favicon.ico", Rel: IconRelIcon},
+			[]*PreviewImage{
+				{URL: "http://test.com/favicon.ico", Rel: IconRelIcon},
+			},
+		},
+		{
+			"No Icons",
+			"Pages without icon links have no icons",
+			page(`<link rel="icon"><link rel="manifest" href="` + server.URL + `/missing.json">`),
+			32,
+			nil,
+			nil,
+		},
+	}
+
+	for _, c := range cases {
+		summarizer := NewSummarizer(&IconExtractor{Size: c.size})
+		summarizer.Fetcher = loopbackFetcher()
+		summary, err := summarizer.Summarize(context.Background(), "http://test.com/test.html", strings.NewReader(c.html))
+		if err != nil {
+			t.Errorf("case %s: unexpected error %v\nHINT: %s\n", c.name, err, c.hint)
+			continue
+		}
+		if !reflect.DeepEqual(summary.Icon, c.expectedIcon) || !reflect.DeepEqual(summary.Icons, c.expectedIcons) {
+			expectedJSON, _ := json.MarshalIndent(&PageSummary{Icon: c.expectedIcon, Icons: c.expectedIcons}, "", "  ")
+			actualJSON, _ := json.MarshalIndent(&PageSummary{Icon: summary.Icon, Icons: summary.Icons}, "", "  ")
+			t.Errorf("case %s: incorrect icons:\nEXPECTED: %s\nACTUAL: %s\nHINT: %s\n",
+				c.name, string(expectedJSON), string(actualJSON), c.hint)
+		}
+	}
+}
+
+func TestBestIcon(t *testing.T) {
+	small := &PreviewImage{URL: "small.png", Width: 16, Height: 16}
+	medium := &PreviewImage{URL: "medium.png", Width: 48, Height: 48}
+	large := &PreviewImage{URL: "large.png", Width: 192, Height: 192}
+	unsized := &PreviewImage{URL: "favicon.ico"}
+	scalable := &PreviewImage{URL: "icon.svg"}
+	mask := &PreviewImage{URL: "mask.svg", Rel: IconRelMaskIcon}
+
+	cases := []struct {
+		name     string
+		icons    []*PreviewImage
+		size     int
+		expected *PreviewImage
+	}{
+		{"Smallest Large Enough", []*PreviewImage{large, small, medium}, 32, medium},
+		{"Exact Size", []*PreviewImage{large, medium}, 48, medium},
+		{"Largest Too Small", []*PreviewImage{small, medium, unsized}, 64, medium},
+		{"Scalable", []*PreviewImage{small, scalable}, 64, scalable},
+		{"Unsized", []*PreviewImage{mask, unsized}, 32, unsized},
+		{"Mask Only", []*PreviewImage{mask}, 32, mask},
+		{"None", nil, 32, nil},
+	}
+
+	for _, c := range cases {
+		if actual := BestIcon(c.icons, c.size); actual != c.expected {
+			t.Errorf("case %s: expected %v but got %v", c.name, c.expected, actual)
+		}
+	}
+}
+
+func TestSummarizeURLFavicon(t *testing.T) {
+	faviconType := "image/vnd.microsoft.icon"
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/favicon.ico":
+			requests++
+			if len(faviconType) == 0 {
+				http.NotFound(w, r)
+				return
+			}
+			w.Header().Set("Content-Type", faviconType)
+			w.Write([]byte{0, 0, 1, 0})
+		case "/declared.html":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<html><head><link rel="icon" href="/icon.png"></head></html>`))
+		default:
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<html><head><title>no icons</title></head></html>`))
+		}
+	}))
+	defer server.Close()
+
+	summarizer := NewSummarizer()
+	summarizer.Fetcher = loopbackFetcher()
+
+	summary, _, err := summarizer.SummarizeURL(context.Background(), server.URL+"/dir/page.html?q=1", Options{})
+	if err != nil {
+		t.Fatalf("unexpected error summarizing page: %v", err)
+	}
+	expected := &PreviewImage{URL: server.URL + "/favicon.ico", Type: faviconType, Rel: IconRelIcon}
+	if !reflect.DeepEqual(summary.Icon, expected) || len(summary.Icons) != 1 {
+		t.Errorf("pages that declare no icons should have the one at /favicon.ico: expected %+v but got %+v", expected, summary.Icon)
+	}
+
+	summary, _, err = summarizer.SummarizeURL(context.Background(), server.URL+"/declared.html", Options{})
+	if err != nil {
+		t.Fatalf("unexpected error summarizing page: %v", err)
+	}
+	if summary.Icon == nil || summary.Icon.URL != server.URL+"/icon.png" || requests != 1 {
+		t.Errorf("/favicon.ico should not be probed for pages that declare an icon, but got icon %+v after %d probes", summary.Icon, requests)
+	}
+
+	faviconType = ""
+	summary, _, err = summarizer.SummarizeURL(context.Background(), server.URL+"/other.html", Options{})
+	if err != nil {
+		t.Fatalf("unexpected error summarizing page: %v", err)
+	}
+	if summary.Icon != nil || summary.Icons != nil {
+		t.Errorf("pages without an image at /favicon.ico should have no icon, but got %+v", summary.Icon)
+	}
+}
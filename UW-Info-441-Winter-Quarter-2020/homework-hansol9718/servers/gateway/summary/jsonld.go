@@ -0,0 +1,358 @@
+package summary
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+//StructuredData represents one schema.org entity described by a
+//page's JSON-LD. Only the section matching the entity's Type is set.
+type StructuredData struct {
+	Type         string            `json:"type"`
+	Name         string            `json:"name,omitempty"`
+	Description  string            `json:"description,omitempty"`
+	URL          string            `json:"url,omitempty"`
+	Article      *ArticleData      `json:"article,omitempty"`
+	Product      *ProductData      `json:"product,omitempty"`
+	Recipe       *RecipeData       `json:"recipe,omitempty"`
+	Event        *EventData        `json:"event,omitempty"`
+	Organization *OrganizationData `json:"organization,omitempty"`
+}
+
+//ArticleData represents the properties of a schema.org
+//Article, NewsArticle or BlogPosting
+type ArticleData struct {
+	Headline      string   `json:"headline,omitempty"`
+	Authors       []string `json:"authors,omitempty"`
+	Publisher     string   `json:"publisher,omitempty"`
+	Section       string   `json:"section,omitempty"`
+	DatePublished string   `json:"datePublished,omitempty"`
+	DateModified  string   `json:"dateModified,omitempty"`
+}
+
+//ProductData represents the properties of a schema.org Product
+type ProductData struct {
+	Brand  string   `json:"brand,omitempty"`
+	SKU    string   `json:"sku,omitempty"`
+	Offers []*Offer `json:"offers,omitempty"`
+	Rating *Rating  `json:"rating,omitempty"`
+}
+
+//RecipeData represents the properties of a schema.org Recipe
+type RecipeData struct {
+	Authors       []string `json:"authors,omitempty"`
+	DatePublished string   `json:"datePublished,omitempty"`
+	PrepTime      string   `json:"prepTime,omitempty"`
+	CookTime      string   `json:"cookTime,omitempty"`
+	TotalTime     string   `json:"totalTime,omitempty"`
+	Yield         string   `json:"yield,omitempty"`
+	Ingredients   []string `json:"ingredients,omitempty"`
+	Rating        *Rating  `json:"rating,omitempty"`
+}
+
+//EventData represents the properties of a schema.org Event
+type EventData struct {
+	StartDate string   `json:"startDate,omitempty"`
+	EndDate   string   `json:"endDate,omitempty"`
+	Location  string   `json:"location,omitempty"`
+	Organizer string   `json:"organizer,omitempty"`
+	Offers    []*Offer `json:"offers,omitempty"`
+}
+
+//OrganizationData represents the properties of a schema.org Organization
+type OrganizationData struct {
+	Logo   string   `json:"logo,omitempty"`
+	SameAs []string `json:"sameAs,omitempty"`
+}
+
+//Offer represents a schema.org Offer or AggregateOffer. Prices are
+//kept as strings, as they are often written that way in JSON-LD
+type Offer struct {
+	Price         string `json:"price,omitempty"`
+	LowPrice      string `json:"lowPrice,omitempty"`
+	HighPrice     string `json:"highPrice,omitempty"`
+	PriceCurrency string `json:"priceCurrency,omitempty"`
+	Availability  string `json:"availability,omitempty"`
+	URL           string `json:"url,omitempty"`
+}
+
+//Rating represents a schema.org AggregateRating
+type Rating struct {
+	Value float64 `json:"value,omitempty"`
+	Best  float64 `json:"best,omitempty"`
+	Count int     `json:"count,omitempty"`
+}
+
+//JSONLDExtractor extracts schema.org structured data from
+//the page's <script type="application/ld+json"> elements
+type JSONLDExtractor struct{}
+
+//Extract implements the Extractor interface. The summary's Title,
+//Description, Author and Images come from the first Article, Product,
+//Recipe or Event entity, and its SiteName from the first Organization.
+//JSON-LD scripts are read wherever they are in the page.
+func (e *JSONLDExtractor) Extract(doc *Document) *PageSummary {
+	page := &PageSummary{}
+	for _, script := range doc.Scripts {
+		if !isJSONLD(script.Type) {
+			continue
+		}
+		var data interface{}
+		if err := json.Unmarshal([]byte(script.Text), &data); err != nil {
+			//pages with broken JSON-LD are still worth summarizing
+			continue
+		}
+		for _, node := range ldNodes(data) {
+			e.extractNode(doc, page, node)
+		}
+	}
+	return page
+}
+
+//isJSONLD reports whether `scriptType`, the type
+//of a <script> element, is the JSON-LD media type
+func isJSONLD(scriptType string) bool {
+	return strings.EqualFold(strings.TrimSpace(scriptType), "application/ld+json")
+}
+
+//extractNode adds the schema.org entity `node` to `page`
+//if it is of a supported type
+func (e *JSONLDExtractor) extractNode(doc *Document, page *PageSummary, node map[string]interface{}) {
+	sd := &StructuredData{
+		Type:        ldType(node),
+		Name:        ldText(node["name"]),
+		Description: ldText(node["description"]),
+		URL:         ldURL(doc, node["url"]),
+	}
+	var authors []string
+	switch sd.Type {
+	case "Article", "NewsArticle", "BlogPosting":
+		authors = ldTexts(node["author"])
+		sd.Article = &ArticleData{
+			Headline:      ldText(node["headline"]),
+			Authors:       authors,
+			Publisher:     ldText(node["publisher"]),
+			Section:       ldText(node["articleSection"]),
+			DatePublished: ldText(node["datePublished"]),
+			DateModified:  ldText(node["dateModified"]),
+		}
+		if len(sd.Name) == 0 {
+			sd.Name = sd.Article.Headline
+		}
+	case "Product":
+		sd.Product = &ProductData{
+			Brand:  ldText(node["brand"]),
+			SKU:    ldText(node["sku"]),
+			Offers: ldOffers(doc, node["offers"]),
+			Rating: ldRating(node["aggregateRating"]),
+		}
+	case "Recipe":
+		authors = ldTexts(node["author"])
+		sd.Recipe = &RecipeData{
+			Authors:       authors,
+			DatePublished: ldText(node["datePublished"]),
+			PrepTime:      ldText(node["prepTime"]),
+			CookTime:      ldText(node["cookTime"]),
+			TotalTime:     ldText(node["totalTime"]),
+			Yield:         ldText(node["recipeYield"]),
+			Ingredients:   ldTexts(node["recipeIngredient"]),
+			Rating:        ldRating(node["aggregateRating"]),
+		}
+	case "Event":
+		sd.Event = &EventData{
+			StartDate: ldText(node["startDate"]),
+			EndDate:   ldText(node["endDate"]),
+			Location:  ldText(node["location"]),
+			Organizer: ldText(node["organizer"]),
+			Offers:    ldOffers(doc, node["offers"]),
+		}
+	case "Organization":
+		sd.Organization = &OrganizationData{
+			Logo:   ldURL(doc, node["logo"]),
+			SameAs: ldTexts(node["sameAs"]),
+		}
+	default:
+		return
+	}
+	page.Structured = append(page.Structured, sd)
+
+	//an Organization is usually the site itself
+	//rather than what the page is about
+	if sd.Organization != nil {
+		if len(page.SiteName) == 0 {
+			page.SiteName = sd.Name
+		}
+		return
+	}
+	if len(page.Title) == 0 {
+		page.Title = sd.Name
+		page.Description = sd.Description
+		page.Author = strings.Join(authors, ", ")
+		page.Images = ldImages(doc, node["image"])
+	}
+}
+
+//ldNodes returns the entities in a parsed JSON-LD document, which may
+//be a single entity, an array of them, or an object with an @graph array
+func ldNodes(data interface{}) []map[string]interface{} {
+	var nodes []map[string]interface{}
+	for _, v := range ldList(data) {
+		node, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if graph, ok := node["@graph"]; ok {
+			nodes = append(nodes, ldNodes(graph)...)
+		} else {
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes
+}
+
+//ldType returns the first supported schema.org type of `node`,
+//without any vocabulary prefix such as "http://schema.org/",
+//or an empty string if it has none
+func ldType(node map[string]interface{}) string {
+	for _, v := range ldList(node["@type"]) {
+		typ, _ := v.(string)
+		typ = typ[strings.LastIndexAny(typ, "/:")+1:]
+		switch typ {
+		case "Article", "NewsArticle", "BlogPosting", "Product", "Recipe", "Event", "Organization":
+			return typ
+		}
+	}
+	return ""
+}
+
+//ldList returns `v` as a list of values, since any
+//JSON-LD property may hold either one value or an array
+func ldList(v interface{}) []interface{} {
+	switch val := v.(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		return val
+	default:
+		return []interface{}{val}
+	}
+}
+
+//ldText returns `v` as text. For an entity, such as a
+//Person or Place, that is its name.
+func ldText(v interface{}) string {
+	switch val := v.(type) {
+	case string:
+		return strings.TrimSpace(val)
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case map[string]interface{}:
+		if name := ldText(val["name"]); len(name) > 0 {
+			return name
+		}
+		return ldText(val["@value"])
+	case []interface{}:
+		if len(val) > 0 {
+			return ldText(val[0])
+		}
+	}
+	return ""
+}
+
+//ldTexts returns each value of `v` as text
+func ldTexts(v interface{}) []string {
+	var texts []string
+	for _, val := range ldList(v) {
+		if text := ldText(val); len(text) > 0 {
+			texts = append(texts, text)
+		}
+	}
+	return texts
+}
+
+//ldFloat returns `v` as a number, parsing it if it's a string
+func ldFloat(v interface{}) float64 {
+	f, _ := strconv.ParseFloat(ldText(v), 64)
+	return f
+}
+
+//ldURL returns `v` as an absolute URL. `v` may be a URL
+//or an entity, such as an ImageObject, with a URL
+func ldURL(doc *Document, v interface{}) string {
+	switch val := v.(type) {
+	case string:
+		//empty values would resolve to the page's own URL
+		if len(strings.TrimSpace(val)) == 0 {
+			return ""
+		}
+		return doc.Resolve(val)
+	case map[string]interface{}:
+		for _, key := range []string{"url", "contentUrl"} {
+			if u := ldURL(doc, val[key]); len(u) > 0 {
+				return u
+			}
+		}
+	case []interface{}:
+		if len(val) > 0 {
+			return ldURL(doc, val[0])
+		}
+	}
+	return ""
+}
+
+//ldImages returns the images in a schema.org image property
+func ldImages(doc *Document, v interface{}) []*PreviewImage {
+	var images []*PreviewImage
+	for _, val := range ldList(v) {
+		u := ldURL(doc, val)
+		if len(u) == 0 {
+			continue
+		}
+		img := &PreviewImage{URL: u}
+		if obj, ok := val.(map[string]interface{}); ok {
+			img.Width = int(ldFloat(obj["width"]))
+			img.Height = int(ldFloat(obj["height"]))
+			img.Alt = ldText(obj["caption"])
+		}
+		images = append(images, img)
+	}
+	return images
+}
+
+//ldOffers returns the schema.org Offers or AggregateOffers in `v`
+func ldOffers(doc *Document, v interface{}) []*Offer {
+	var offers []*Offer
+	for _, val := range ldList(v) {
+		obj, ok := val.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		offers = append(offers, &Offer{
+			Price:         ldText(obj["price"]),
+			LowPrice:      ldText(obj["lowPrice"]),
+			HighPrice:     ldText(obj["highPrice"]),
+			PriceCurrency: ldText(obj["priceCurrency"]),
+			Availability:  ldText(obj["availability"]),
+			URL:           ldURL(doc, obj["url"]),
+		})
+	}
+	return offers
+}
+
+//ldRating returns the schema.org AggregateRating in `v`, or nil
+func ldRating(v interface{}) *Rating {
+	obj, ok := v.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	count := ldFloat(obj["ratingCount"])
+	if count == 0 {
+		count = ldFloat(obj["reviewCount"])
+	}
+	return &Rating{
+		Value: ldFloat(obj["ratingValue"]),
+		Best:  ldFloat(obj["bestRating"]),
+		Count: int(count),
+	}
+}
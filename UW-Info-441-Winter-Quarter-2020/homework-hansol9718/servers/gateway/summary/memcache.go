@@ -0,0 +1,91 @@
+package summary
+
+import (
+	"container/list"
+	"encoding/json"
+	"sync"
+)
+
+//MemCache represents an in-process memory SummaryCache that holds
+//up to a fixed number of entries, evicting the least recently used
+//entry when full. Each gateway instance has its own MemCache,
+//so production systems should use a shared RedisCache.
+type MemCache struct {
+	mx       sync.Mutex
+	capacity int
+	//entries holds the *memCacheItem for each page URL,
+	//most recently used at the front
+	entries *list.List
+	index   map[string]*list.Element
+}
+
+//memCacheItem is an entry in a MemCache. Entries are stored
+//as JSON so that callers can't modify a cached summary.
+type memCacheItem struct {
+	pageURL string
+	entry   []byte
+}
+
+//NewMemCache constructs and returns a new MemCache
+//that holds up to `capacity` entries
+func NewMemCache(capacity int) *MemCache {
+	if capacity <= 0 {
+		panic("MemCache capacity must be greater than zero")
+	}
+	return &MemCache{
+		capacity: capacity,
+		entries:  list.New(),
+		index:    map[string]*list.Element{},
+	}
+}
+
+//Get returns the entry previously saved for `pageURL`,
+//or ErrCacheMiss if there is none
+func (mc *MemCache) Get(pageURL string) (*CacheEntry, error) {
+	mc.mx.Lock()
+	elem, found := mc.index[pageURL]
+	if !found {
+		mc.mx.Unlock()
+		return nil, ErrCacheMiss
+	}
+	mc.entries.MoveToFront(elem)
+	j := elem.Value.(*memCacheItem).entry
+	mc.mx.Unlock()
+
+	entry := &CacheEntry{}
+	if err := json.Unmarshal(j, entry); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+//Set saves `entry` for `pageURL`, evicting the least
+//recently used entry if the cache is full
+func (mc *MemCache) Set(pageURL string, entry *CacheEntry) error {
+	j, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	mc.mx.Lock()
+	defer mc.mx.Unlock()
+	if elem, found := mc.index[pageURL]; found {
+		elem.Value.(*memCacheItem).entry = j
+		mc.entries.MoveToFront(elem)
+		return nil
+	}
+	mc.index[pageURL] = mc.entries.PushFront(&memCacheItem{pageURL: pageURL, entry: j})
+	if mc.entries.Len() > mc.capacity {
+		oldest := mc.entries.Back()
+		mc.entries.Remove(oldest)
+		delete(mc.index, oldest.Value.(*memCacheItem).pageURL)
+	}
+	return nil
+}
+
+//Len returns the number of entries in the cache
+func (mc *MemCache) Len() int {
+	mc.mx.Lock()
+	defer mc.mx.Unlock()
+	return mc.entries.Len()
+}
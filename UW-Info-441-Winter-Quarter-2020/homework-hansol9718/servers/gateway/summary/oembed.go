@@ -0,0 +1,201 @@
+package summary
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+//maxOEmbedBytes is the most that is read from an oEmbed response
+const maxOEmbedBytes = 1 << 20
+
+//Embed represents an embeddable version of a page, such as
+//a video player, published by the page's oEmbed provider.
+//See https://oembed.com. HTML comes from a third party and
+//should only be rendered in a sandboxed frame.
+type Embed struct {
+	Type      string         `json:"type"`
+	HTML      string         `json:"html,omitempty"`
+	URL       string         `json:"url,omitempty"`
+	Width     int            `json:"width,omitempty"`
+	Height    int            `json:"height,omitempty"`
+	Title     string         `json:"title,omitempty"`
+	Author    string         `json:"author,omitempty"`
+	Provider  *EmbedProvider `json:"provider,omitempty"`
+	Thumbnail *PreviewImage  `json:"thumbnail,omitempty"`
+}
+
+//EmbedProvider represents the provider of an Embed
+type EmbedProvider struct {
+	Name string `json:"name,omitempty"`
+	URL  string `json:"url,omitempty"`
+}
+
+//oEmbedResponse represents an oEmbed response document
+type oEmbedResponse struct {
+	Version         string     `json:"version"`
+	Type            string     `json:"type"`
+	HTML            string     `json:"html"`
+	URL             string     `json:"url"`
+	Width           oEmbedSize `json:"width"`
+	Height          oEmbedSize `json:"height"`
+	Title           string     `json:"title"`
+	AuthorName      string     `json:"author_name"`
+	ProviderName    string     `json:"provider_name"`
+	ProviderURL     string     `json:"provider_url"`
+	ThumbnailURL    string     `json:"thumbnail_url"`
+	ThumbnailWidth  oEmbedSize `json:"thumbnail_width"`
+	ThumbnailHeight oEmbedSize `json:"thumbnail_height"`
+}
+
+//oEmbedSize is a width or height in an oEmbed response, which
+//some providers write as a string rather than a number
+type oEmbedSize int
+
+//UnmarshalJSON implements the json.Unmarshaler interface
+func (s *oEmbedSize) UnmarshalJSON(data []byte) error {
+	str := strings.Trim(string(data), `"`)
+	if str == "null" || len(str) == 0 {
+		return nil
+	}
+	n, err := strconv.ParseFloat(str, 64)
+	if err != nil {
+		return fmt.Errorf("invalid oEmbed size %s", data)
+	}
+	*s = oEmbedSize(n)
+	return nil
+}
+
+//OEmbedExtractor discovers a page's oEmbed provider through a
+//<link rel="alternate" type="application/json+oembed"> element,
+//and fetches the page's Embed from it using the Document's Fetcher
+type OEmbedExtractor struct{}
+
+//Extract implements the Extractor interface. Pages without an oEmbed
+//provider, or whose provider returns an invalid response, have no Embed.
+func (e *OEmbedExtractor) Extract(doc *Document) *PageSummary {
+	endpoint := oEmbedEndpoint(doc)
+	if len(endpoint) == 0 {
+		return nil
+	}
+	if doc.Fetcher == nil {
+		return nil
+	}
+	ctx := doc.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	embed, err := e.fetch(ctx, doc.Fetcher, endpoint)
+	if err != nil {
+		return nil
+	}
+	return &PageSummary{Embed: embed}
+}
+
+//fetch fetches and validates the oEmbed response at `endpoint`
+func (e *OEmbedExtractor) fetch(ctx context.Context, fetcher *Fetcher, endpoint string) (*Embed, error) {
+	resp, err := fetcher.Fetch(ctx, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("oEmbed provider responded with status code %d", resp.StatusCode)
+	}
+
+	oembed := &oEmbedResponse{}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxOEmbedBytes)).Decode(oembed); err != nil {
+		return nil, fmt.Errorf("error decoding oEmbed response: %v", err)
+	}
+	if err := oembed.validate(); err != nil {
+		return nil, err
+	}
+
+	embed := &Embed{
+		Type:   oembed.Type,
+		HTML:   oembed.HTML,
+		URL:    oembed.URL,
+		Width:  int(oembed.Width),
+		Height: int(oembed.Height),
+		Title:  oembed.Title,
+		Author: oembed.AuthorName,
+	}
+	if len(oembed.ProviderName) > 0 || len(oembed.ProviderURL) > 0 {
+		embed.Provider = &EmbedProvider{
+			Name: oembed.ProviderName,
+			URL:  oembed.ProviderURL,
+		}
+	}
+	if len(strings.TrimSpace(oembed.ThumbnailURL)) > 0 {
+		embed.Thumbnail = &PreviewImage{
+			//thumbnail URLs are relative to the oEmbed response, not the page
+			URL:    absoluteURL(resp.Request.URL, oembed.ThumbnailURL).String(),
+			Width:  int(oembed.ThumbnailWidth),
+			Height: int(oembed.ThumbnailHeight),
+		}
+	}
+	return embed, nil
+}
+
+//validate returns an error if the response is missing
+//any of the properties its type requires
+func (r *oEmbedResponse) validate() error {
+	if len(r.Version) > 0 && r.Version != "1.0" {
+		return fmt.Errorf("unsupported oEmbed version %s", r.Version)
+	}
+	switch r.Type {
+	case "photo":
+		if len(r.URL) == 0 {
+			return errors.New("oEmbed photo has no url")
+		}
+	case "video", "rich":
+		if len(r.HTML) == 0 {
+			return fmt.Errorf("oEmbed %s has no html", r.Type)
+		}
+	case "link":
+		return nil
+	default:
+		return fmt.Errorf("unsupported oEmbed type %q", r.Type)
+	}
+	if r.Width <= 0 || r.Height <= 0 {
+		return fmt.Errorf("oEmbed %s has no width or height", r.Type)
+	}
+	return nil
+}
+
+//oEmbedEndpoint returns the absolute URL of the page's
+//JSON oEmbed response, or an empty string if it has none
+func oEmbedEndpoint(doc *Document) string {
+	for _, token := range doc.Links {
+		rel, _ := attr(token, "rel")
+		typ, _ := attr(token, "type")
+		href, _ := attr(token, "href")
+		if !hasToken(rel, "alternate") || len(href) == 0 {
+			continue
+		}
+		//text/json+oembed is an older, unofficial type
+		//still published by some providers
+		switch strings.ToLower(strings.TrimSpace(typ)) {
+		case "application/json+oembed", "text/json+oembed":
+		default:
+			continue
+		}
+		return doc.Resolve(href)
+	}
+	return ""
+}
+
+//hasToken reports whether the space-separated list
+//`list` contains `token`, ignoring case
+func hasToken(list string, token string) bool {
+	for _, t := range strings.Fields(list) {
+		if strings.EqualFold(t, token) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,149 @@
+package summary
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestOEmbedExtractor(t *testing.T) {
+	responses := map[string]string{
+		"/video": `{"version": "1.0", "type": "video", "html": "<iframe src=\"https://player.test.com/1\"></iframe>",
+			"width": 640, "height": "360", "title": "test video", "author_name": "test author",
+			"provider_name": "Test Provider", "provider_url": "https://test.com/",
+			"thumbnail_url": "/thumb.jpg", "thumbnail_width": 480, "thumbnail_height": 360}`,
+		"/photo":   `{"version": "1.0", "type": "photo", "url": "https://test.com/photo.jpg", "width": 100, "height": 50}`,
+		"/link":    `{"version": "1.0", "type": "link", "title": "test link"}`,
+		"/nohtml":  `{"version": "1.0", "type": "video", "width": 640, "height": 360}`,
+		"/nosize":  `{"version": "1.0", "type": "rich", "html": "<div></div>"}`,
+		"/badtype": `{"version": "1.0", "type": "audio", "html": "<audio></audio>", "width": 1, "height": 1}`,
+		"/badjson": `<html></html>`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp, ok := responses[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(resp))
+	}))
+	defer server.Close()
+
+	link := func(path string) string {
+		return `<html><head><link rel="alternate" type="application/json+oembed" href="` +
+			server.URL + path + `"></head></html>`
+	}
+
+	cases := []struct {
+		name          string
+		hint          string
+		html          string
+		expectedEmbed *Embed
+	}{
+		{
+			"Video",
+			"Make sure you fetch the oEmbed response and read all of its properties",
+			link("/video"),
+			&Embed{
+				Type:   "video",
+				HTML:   `<iframe src="https://player.test.com/1"></iframe>`,
+				Width:  640,
+				Height: 360,
+				Title:  "test video",
+				Author: "test author",
+				Provider: &EmbedProvider{
+					Name: "Test Provider",
+					URL:  "https://test.com/",
+				},
+				Thumbnail: &PreviewImage{
+					URL:    server.URL + "/thumb.jpg",
+					Width:  480,
+					Height: 360,
+				},
+			},
+		},
+		{
+			"Photo",
+			"oEmbed photos have a url rather than html",
+			link("/photo"),
+			&Embed{
+				Type:   "photo",
+				URL:    "https://test.com/photo.jpg",
+				Width:  100,
+				Height: 50,
+			},
+		},
+		{
+			"Link",
+			"oEmbed links need no other properties",
+			link("/link"),
+			&Embed{
+				Type:  "link",
+				Title: "test link",
+			},
+		},
+		{
+			"Video Without HTML",
+			"oEmbed videos must have html",
+			link("/nohtml"),
+			nil,
+		},
+		{
+			"Rich Without Size",
+			"oEmbed rich responses must have a width and height",
+			link("/nosize"),
+			nil,
+		},
+		{
+			"Unsupported Type",
+			"Only the photo, video, link and rich types are valid",
+			link("/badtype"),
+			nil,
+		},
+		{
+			"Invalid JSON",
+			"Responses that aren't valid JSON should be ignored",
+			link("/badjson"),
+			nil,
+		},
+		{
+			"Not Found",
+			"Make sure you check the response status code",
+			link("/missing"),
+			nil,
+		},
+		{
+			"XML Only",
+			"Only JSON oEmbed responses are supported",
+			`<html><head><link rel="alternate" type="text/xml+oembed" href="` + server.URL + `/video"></head></html>`,
+			nil,
+		},
+		{
+			"No Provider",
+			"Pages without an oEmbed link have no embed",
+			`<html><head><link rel="alternate" type="application/rss+xml" href="` + server.URL + `/video"></head></html>`,
+			nil,
+		},
+	}
+
+	summarizer := NewSummarizer(&OEmbedExtractor{})
+	summarizer.Fetcher = loopbackFetcher()
+	for _, c := range cases {
+		summary, err := summarizer.Summarize(context.Background(), "http://test.com/test.html", strings.NewReader(c.html))
+		if err != nil {
+			t.Errorf("case %s: unexpected error %v\nHINT: %s\n", c.name, err, c.hint)
+			continue
+		}
+		if !reflect.DeepEqual(summary.Embed, c.expectedEmbed) {
+			expectedJSON, _ := json.MarshalIndent(c.expectedEmbed, "", "  ")
+			actualJSON, _ := json.MarshalIndent(summary.Embed, "", "  ")
+			t.Errorf("case %s: incorrect embed:\nEXPECTED: %s\nACTUAL: %s\nHINT: %s\n",
+				c.name, string(expectedJSON), string(actualJSON), c.hint)
+		}
+	}
+}
@@ -0,0 +1,412 @@
+package summary
+
+import (
+	"strconv"
+	"strings"
+)
+
+//PreviewVideo represents a video for a page
+type PreviewVideo struct {
+	URL       string `json:"url,omitempty"`
+	SecureURL string `json:"secureURL,omitempty"`
+	Type      string `json:"type,omitempty"`
+	Width     int    `json:"width,omitempty"`
+	Height    int    `json:"height,omitempty"`
+}
+
+//PreviewAudio represents an audio file for a page
+type PreviewAudio struct {
+	URL       string `json:"url,omitempty"`
+	SecureURL string `json:"secureURL,omitempty"`
+	Type      string `json:"type,omitempty"`
+}
+
+//OpenGraphObject holds the properties specific to a page's Open Graph
+//object type, as described in http://ogp.me/#types. Only the sections
+//the page has properties for are set, which is usually the one for its
+//type. Properties that refer to other objects, like authors, are kept
+//as written, which should be the URLs of those objects' pages.
+type OpenGraphObject struct {
+	Article *OpenGraphArticle `json:"article,omitempty"`
+	Book    *OpenGraphBook    `json:"book,omitempty"`
+	Profile *OpenGraphProfile `json:"profile,omitempty"`
+	Music   *OpenGraphMusic   `json:"music,omitempty"`
+	Video   *OpenGraphVideo   `json:"video,omitempty"`
+}
+
+//OpenGraphArticle represents the article:* properties
+type OpenGraphArticle struct {
+	PublishedTime  string   `json:"publishedTime,omitempty"`
+	ModifiedTime   string   `json:"modifiedTime,omitempty"`
+	ExpirationTime string   `json:"expirationTime,omitempty"`
+	Authors        []string `json:"authors,omitempty"`
+	Section        string   `json:"section,omitempty"`
+	Tags           []string `json:"tags,omitempty"`
+}
+
+//OpenGraphBook represents the book:* properties
+type OpenGraphBook struct {
+	Authors     []string `json:"authors,omitempty"`
+	ISBN        string   `json:"isbn,omitempty"`
+	ReleaseDate string   `json:"releaseDate,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+}
+
+//OpenGraphProfile represents the profile:* properties
+type OpenGraphProfile struct {
+	FirstName string `json:"firstName,omitempty"`
+	LastName  string `json:"lastName,omitempty"`
+	Username  string `json:"username,omitempty"`
+	Gender    string `json:"gender,omitempty"`
+}
+
+//OpenGraphMusic represents the music:* properties of
+//songs, albums, playlists and radio stations
+type OpenGraphMusic struct {
+	Duration    int            `json:"duration,omitempty"`
+	Albums      []*MusicRecord `json:"albums,omitempty"`
+	Songs       []*MusicRecord `json:"songs,omitempty"`
+	Musicians   []string       `json:"musicians,omitempty"`
+	Creator     string         `json:"creator,omitempty"`
+	ReleaseDate string         `json:"releaseDate,omitempty"`
+}
+
+//MusicRecord represents a music:album a song is on,
+//or a music:song on an album or playlist
+type MusicRecord struct {
+	URL   string `json:"url,omitempty"`
+	Disc  int    `json:"disc,omitempty"`
+	Track int    `json:"track,omitempty"`
+}
+
+//OpenGraphVideo represents the video:* properties of
+//movies, TV shows and their episodes, and other videos
+type OpenGraphVideo struct {
+	Actors      []*VideoActor `json:"actors,omitempty"`
+	Directors   []string      `json:"directors,omitempty"`
+	Writers     []string      `json:"writers,omitempty"`
+	Duration    int           `json:"duration,omitempty"`
+	ReleaseDate string        `json:"releaseDate,omitempty"`
+	Tags        []string      `json:"tags,omitempty"`
+	Series      string        `json:"series,omitempty"`
+}
+
+//VideoActor represents a video:actor and the role they play
+type VideoActor struct {
+	URL  string `json:"url,omitempty"`
+	Role string `json:"role,omitempty"`
+}
+
+//ogURLProperties are the Open Graph properties whose content is a URL
+var ogURLProperties = map[string]bool{
+	"og:image": true, "og:image:url": true, "og:image:secure_url": true,
+	"og:video": true, "og:video:url": true, "og:video:secure_url": true,
+	"og:audio": true, "og:audio:url": true, "og:audio:secure_url": true,
+}
+
+//OpenGraphExtractor extracts the Open Graph properties
+//described at http://ogp.me from a page's <meta> elements
+type OpenGraphExtractor struct{}
+
+//Extract implements the Extractor interface
+func (e *OpenGraphExtractor) Extract(doc *Document) *PageSummary {
+	page := &PageSummary{}
+	object := &OpenGraphObject{}
+	for _, token := range doc.Meta {
+		prop, content := metaProperty(token)
+		//empty URLs would resolve to the page's own URL
+		if ogURLProperties[prop] && len(strings.TrimSpace(content)) == 0 {
+			continue
+		}
+		switch prop {
+		case "og:title":
+			page.Title = content
+		case "og:type":
+			page.Type = content
+		case "og:site_name":
+			page.SiteName = content
+		case "og:description":
+			page.Description = content
+		case "og:url":
+			page.URL = content
+		case "og:determiner":
+			page.Determiner = content
+		case "og:locale":
+			page.Locale = content
+		case "og:locale:alternate":
+			page.AlternateLocales = append(page.AlternateLocales, content)
+		case "og:image":
+			page.Images = append(page.Images, &PreviewImage{URL: doc.Resolve(content)})
+		case "og:video":
+			page.Videos = append(page.Videos, &PreviewVideo{URL: doc.Resolve(content)})
+		case "og:audio":
+			page.Audios = append(page.Audios, &PreviewAudio{URL: doc.Resolve(content)})
+
+		//og:image:url is the same as og:image, and so on, so the
+		//:url properties start a new item too, unless they repeat
+		//the URL of the current one, as many pages do
+		case "og:image:url":
+			if n := len(page.Images); n == 0 || startsNewItem(page.Images[n-1].URL, doc, content) {
+				page.Images = append(page.Images, &PreviewImage{})
+			}
+		case "og:video:url":
+			if n := len(page.Videos); n == 0 || startsNewItem(page.Videos[n-1].URL, doc, content) {
+				page.Videos = append(page.Videos, &PreviewVideo{})
+			}
+		case "og:audio:url":
+			if n := len(page.Audios); n == 0 || startsNewItem(page.Audios[n-1].URL, doc, content) {
+				page.Audios = append(page.Audios, &PreviewAudio{})
+			}
+		}
+
+		//structured properties describe the most recent og:image,
+		//og:video or og:audio, as described in http://ogp.me/#array,
+		//so they are ignored until there is one
+		if len(page.Images) > 0 {
+			setImageProperty(page.Images[len(page.Images)-1], doc, prop, content)
+		}
+		if len(page.Videos) > 0 {
+			setVideoProperty(page.Videos[len(page.Videos)-1], doc, prop, content)
+		}
+		if len(page.Audios) > 0 {
+			setAudioProperty(page.Audios[len(page.Audios)-1], doc, prop, content)
+		}
+		object.set(prop, content)
+	}
+	if *object != (OpenGraphObject{}) {
+		page.Object = object
+	}
+	return page
+}
+
+//startsNewItem reports whether an :url property with `content`
+//starts a new item, rather than describing the current one,
+//whose URL is `current`
+func startsNewItem(current string, doc *Document, content string) bool {
+	return len(current) > 0 && current != doc.Resolve(content)
+}
+
+//setImageProperty sets the og:image structured property `prop` of `img`
+func setImageProperty(img *PreviewImage, doc *Document, prop string, content string) {
+	switch prop {
+	case "og:image:url":
+		img.URL = doc.Resolve(content)
+	case "og:image:secure_url":
+		img.SecureURL = doc.Resolve(content)
+	case "og:image:type":
+		img.Type = content
+	case "og:image:width":
+		img.Width, _ = strconv.Atoi(content)
+	case "og:image:height":
+		img.Height, _ = strconv.Atoi(content)
+	case "og:image:alt":
+		img.Alt = content
+	}
+}
+
+//setVideoProperty sets the og:video structured property `prop` of `video`
+func setVideoProperty(video *PreviewVideo, doc *Document, prop string, content string) {
+	switch prop {
+	case "og:video:url":
+		video.URL = doc.Resolve(content)
+	case "og:video:secure_url":
+		video.SecureURL = doc.Resolve(content)
+	case "og:video:type":
+		video.Type = content
+	case "og:video:width":
+		video.Width, _ = strconv.Atoi(content)
+	case "og:video:height":
+		video.Height, _ = strconv.Atoi(content)
+	}
+}
+
+//setAudioProperty sets the og:audio structured property `prop` of `audio`
+func setAudioProperty(audio *PreviewAudio, doc *Document, prop string, content string) {
+	switch prop {
+	case "og:audio:url":
+		audio.URL = doc.Resolve(content)
+	case "og:audio:secure_url":
+		audio.SecureURL = doc.Resolve(content)
+	case "og:audio:type":
+		audio.Type = content
+	}
+}
+
+//set sets the object type property `prop`, if it is one. A section
+//is only added for properties that are part of the vocabulary.
+func (o *OpenGraphObject) set(prop string, content string) {
+	i := strings.IndexByte(prop, ':')
+	if i < 0 {
+		return
+	}
+	switch prop[:i] {
+	case "article":
+		article := o.Article
+		if article == nil {
+			article = &OpenGraphArticle{}
+		}
+		if article.set(prop, content) {
+			o.Article = article
+		}
+	case "book":
+		book := o.Book
+		if book == nil {
+			book = &OpenGraphBook{}
+		}
+		if book.set(prop, content) {
+			o.Book = book
+		}
+	case "profile":
+		profile := o.Profile
+		if profile == nil {
+			profile = &OpenGraphProfile{}
+		}
+		if profile.set(prop, content) {
+			o.Profile = profile
+		}
+	case "music":
+		music := o.Music
+		if music == nil {
+			music = &OpenGraphMusic{}
+		}
+		if music.set(prop, content) {
+			o.Music = music
+		}
+	case "video":
+		video := o.Video
+		if video == nil {
+			video = &OpenGraphVideo{}
+		}
+		if video.set(prop, content) {
+			o.Video = video
+		}
+	}
+}
+
+//set sets the article:* property `prop`, reporting whether it is one
+func (a *OpenGraphArticle) set(prop string, content string) bool {
+	switch prop {
+	case "article:published_time":
+		a.PublishedTime = content
+	case "article:modified_time":
+		a.ModifiedTime = content
+	case "article:expiration_time":
+		a.ExpirationTime = content
+	case "article:author":
+		a.Authors = append(a.Authors, content)
+	case "article:section":
+		a.Section = content
+	case "article:tag":
+		a.Tags = append(a.Tags, content)
+	default:
+		return false
+	}
+	return true
+}
+
+//set sets the book:* property `prop`, reporting whether it is one
+func (b *OpenGraphBook) set(prop string, content string) bool {
+	switch prop {
+	case "book:author":
+		b.Authors = append(b.Authors, content)
+	case "book:isbn":
+		b.ISBN = content
+	case "book:release_date":
+		b.ReleaseDate = content
+	case "book:tag":
+		b.Tags = append(b.Tags, content)
+	default:
+		return false
+	}
+	return true
+}
+
+//set sets the profile:* property `prop`, reporting whether it is one
+func (p *OpenGraphProfile) set(prop string, content string) bool {
+	switch prop {
+	case "profile:first_name":
+		p.FirstName = content
+	case "profile:last_name":
+		p.LastName = content
+	case "profile:username":
+		p.Username = content
+	case "profile:gender":
+		p.Gender = content
+	default:
+		return false
+	}
+	return true
+}
+
+//set sets the music:* property `prop`, reporting whether it is one.
+//The disc and track structured properties describe the most
+//recent album or song, and are ignored until there is one.
+func (m *OpenGraphMusic) set(prop string, content string) bool {
+	switch prop {
+	case "music:duration":
+		m.Duration, _ = strconv.Atoi(content)
+	case "music:album":
+		m.Albums = append(m.Albums, &MusicRecord{URL: content})
+	case "music:song":
+		m.Songs = append(m.Songs, &MusicRecord{URL: content})
+	case "music:musician":
+		m.Musicians = append(m.Musicians, content)
+	case "music:creator":
+		m.Creator = content
+	case "music:release_date":
+		m.ReleaseDate = content
+	case "music:album:disc", "music:album:track":
+		if len(m.Albums) == 0 {
+			return false
+		}
+		setRecordProperty(m.Albums[len(m.Albums)-1], prop[len("music:album:"):], content)
+	case "music:song:disc", "music:song:track":
+		if len(m.Songs) == 0 {
+			return false
+		}
+		setRecordProperty(m.Songs[len(m.Songs)-1], prop[len("music:song:"):], content)
+	default:
+		return false
+	}
+	return true
+}
+
+//setRecordProperty sets the disc or track `name` of `record`
+func setRecordProperty(record *MusicRecord, name string, content string) {
+	switch name {
+	case "disc":
+		record.Disc, _ = strconv.Atoi(content)
+	case "track":
+		record.Track, _ = strconv.Atoi(content)
+	}
+}
+
+//set sets the video:* property `prop`, reporting whether it is one.
+//The role structured property describes the most recent video:actor,
+//and is ignored until there is one.
+func (v *OpenGraphVideo) set(prop string, content string) bool {
+	switch prop {
+	case "video:actor":
+		v.Actors = append(v.Actors, &VideoActor{URL: content})
+	case "video:actor:role":
+		if len(v.Actors) == 0 {
+			return false
+		}
+		v.Actors[len(v.Actors)-1].Role = content
+	case "video:director":
+		v.Directors = append(v.Directors, content)
+	case "video:writer":
+		v.Writers = append(v.Writers, content)
+	case "video:duration":
+		v.Duration, _ = strconv.Atoi(content)
+	case "video:release_date":
+		v.ReleaseDate = content
+	case "video:tag":
+		v.Tags = append(v.Tags, content)
+	case "video:series":
+		v.Series = content
+	default:
+		return false
+	}
+	return true
+}
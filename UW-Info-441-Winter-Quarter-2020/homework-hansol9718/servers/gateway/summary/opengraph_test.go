@@ -0,0 +1,272 @@
+package summary
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+//ogpExample is the URL of an example page at
+//http://examples.opengraphprotocol.us, which the
+//pages in testdata/ogp are modeled on
+func ogpExample(path string) string {
+	return "http://examples.opengraphprotocol.us/" + path
+}
+
+func TestOpenGraphExamples(t *testing.T) {
+	profile := ogpExample("profile.html")
+	siteName := "Open Graph protocol examples"
+	smallImage := []*PreviewImage{{URL: ogpExample("media/images/50.png")}}
+
+	cases := []struct {
+		name            string
+		hint            string
+		fixture         string
+		expectedSummary *PageSummary
+	}{
+		{
+			"Article",
+			"Make sure you are reading the article:* properties, including the arrays of authors and tags",
+			"article.html",
+			&PageSummary{
+				Type:        "article",
+				URL:         ogpExample("article.html"),
+				Title:       "Steve Jobs resigns as CEO of Apple",
+				SiteName:    siteName,
+				Description: "In a letter to the board, Steve Jobs said he could no longer meet his duties and expectations.",
+				Locale:      "en_US",
+				Images: []*PreviewImage{
+					{
+						URL:       ogpExample("media/images/50.png"),
+						SecureURL: "https://d72cgtgi6hvvl.cloudfront.net/media/images/50.png",
+						Type:      "image/png",
+						Width:     50,
+						Height:    50,
+					},
+				},
+				Object: &OpenGraphObject{
+					Article: &OpenGraphArticle{
+						PublishedTime: "2011-08-24T19:19:00-07:00",
+						ModifiedTime:  "2011-08-25T08:30:00-07:00",
+						Authors:       []string{profile},
+						Section:       "Front page",
+						Tags:          []string{"Apple", "Steve Jobs"},
+					},
+				},
+			},
+		},
+		{
+			"Book",
+			"Make sure you are reading the book:* properties",
+			"book.html",
+			&PageSummary{
+				Type:     "book",
+				URL:      ogpExample("book.html"),
+				Title:    "Steve Jobs",
+				SiteName: siteName,
+				Images:   smallImage,
+				Object: &OpenGraphObject{
+					Book: &OpenGraphBook{
+						Authors:     []string{profile},
+						ISBN:        "978-1451648539",
+						ReleaseDate: "2011-10-24",
+						Tags:        []string{"Steve Jobs", "Apple", "Pixar"},
+					},
+				},
+			},
+		},
+		{
+			"Profile",
+			"Make sure you are reading the profile:* properties",
+			"profile.html",
+			&PageSummary{
+				Type:     "profile",
+				URL:      profile,
+				Title:    "Walter Isaacson",
+				SiteName: siteName,
+				Images:   smallImage,
+				Object: &OpenGraphObject{
+					Profile: &OpenGraphProfile{
+						FirstName: "Walter",
+						LastName:  "Isaacson",
+						Username:  "walterisaacson",
+						Gender:    "male",
+					},
+				},
+			},
+		},
+		{
+			"Song",
+			"Make sure you are reading og:audio and the music:* properties, including music:album's structured properties",
+			"song.html",
+			&PageSummary{
+				Type:     "music.song",
+				URL:      ogpExample("song.html"),
+				Title:    "Twist and Shout",
+				SiteName: siteName,
+				Images:   smallImage,
+				Audios: []*PreviewAudio{
+					{
+						URL:       ogpExample("media/audio/1khz.mp3"),
+						SecureURL: "https://d72cgtgi6hvvl.cloudfront.net/media/audio/1khz.mp3",
+						Type:      "audio/mpeg",
+					},
+				},
+				Object: &OpenGraphObject{
+					Music: &OpenGraphMusic{
+						Duration:  154,
+						Albums:    []*MusicRecord{{URL: ogpExample("album.html"), Disc: 1, Track: 14}},
+						Musicians: []string{profile},
+					},
+				},
+			},
+		},
+		{
+			"Album",
+			"music:song:disc and music:song:track describe the most recent music:song, as described in http://ogp.me/#array",
+			"album.html",
+			&PageSummary{
+				Type:     "music.album",
+				URL:      ogpExample("album.html"),
+				Title:    "Please Please Me",
+				SiteName: siteName,
+				Images:   smallImage,
+				Object: &OpenGraphObject{
+					Music: &OpenGraphMusic{
+						Songs: []*MusicRecord{
+							{URL: ogpExample("song.html"), Disc: 1, Track: 14},
+							{URL: ogpExample("song-2.html"), Track: 1},
+						},
+						Musicians:   []string{profile},
+						ReleaseDate: "1963-03-22",
+					},
+				},
+			},
+		},
+		{
+			"Movie",
+			"Make sure you are handling multiple structured og:video properties, and video:actor roles",
+			"movie.html",
+			&PageSummary{
+				Type:     "video.movie",
+				URL:      ogpExample("movie.html"),
+				Title:    "Arrival of a Train at La Ciotat",
+				SiteName: siteName,
+				Images: []*PreviewImage{
+					{
+						URL:    ogpExample("media/images/train.jpg"),
+						Type:   "image/jpeg",
+						Width:  500,
+						Height: 328,
+					},
+				},
+				Videos: []*PreviewVideo{
+					{
+						URL:       ogpExample("media/video/train.mp4"),
+						SecureURL: "https://d72cgtgi6hvvl.cloudfront.net/media/video/train.mp4",
+						Type:      "video/mp4",
+						Width:     472,
+						Height:    296,
+					},
+					{
+						URL:       ogpExample("media/video/train.webm"),
+						SecureURL: "https://d72cgtgi6hvvl.cloudfront.net/media/video/train.webm",
+						Type:      "video/webm",
+					},
+				},
+				Object: &OpenGraphObject{
+					Video: &OpenGraphVideo{
+						Actors: []*VideoActor{
+							{URL: ogpExample("profile-3.html"), Role: "Passenger"},
+							{URL: ogpExample("profile-4.html")},
+						},
+						Directors:   []string{profile, ogpExample("profile-2.html")},
+						Duration:    50,
+						ReleaseDate: "1895-12-28",
+						Tags:        []string{"La Ciotat", "train"},
+					},
+				},
+			},
+		},
+		{
+			"Episode",
+			"Make sure you are reading video:series and video:writer, and resolving relative image URLs",
+			"episode.html",
+			&PageSummary{
+				Type:     "video.episode",
+				URL:      ogpExample("episode.html"),
+				Title:    "Pilot",
+				SiteName: siteName,
+				Images:   smallImage,
+				Object: &OpenGraphObject{
+					Video: &OpenGraphVideo{
+						Writers:     []string{profile},
+						ReleaseDate: "2004-09-22",
+						Series:      ogpExample("tv_show.html"),
+					},
+				},
+			},
+		},
+		{
+			"Locales",
+			"Make sure you are reading og:determiner, og:locale and the og:locale:alternate array",
+			"canadian.html",
+			&PageSummary{
+				Type:             "website",
+				URL:              ogpExample("canadian.html"),
+				Title:            "Canada",
+				Determiner:       "the",
+				SiteName:         siteName,
+				Description:      "Content localized for English-speaking Canadians.",
+				Locale:           "en_CA",
+				AlternateLocales: []string{"fr_CA", "en_US"},
+				Images:           smallImage,
+			},
+		},
+		{
+			"Audio Array",
+			"og:audio:url sets the URL of the current og:audio, only starting one if there is none, and other structured properties before any og:audio are ignored",
+			"audio-array.html",
+			&PageSummary{
+				Type:   "website",
+				URL:    ogpExample("audio-array.html"),
+				Title:  "Two structured audio files",
+				Images: smallImage,
+				Audios: []*PreviewAudio{
+					{
+						URL:       ogpExample("media/audio/1khz.mp3"),
+						SecureURL: "https://d72cgtgi6hvvl.cloudfront.net/media/audio/1khz.mp3",
+						Type:      "audio/mpeg",
+					},
+					{
+						URL:  ogpExample("media/audio/250hz.mp3"),
+						Type: "audio/mpeg",
+					},
+				},
+			},
+		},
+	}
+
+	summarizer := NewSummarizer(&OpenGraphExtractor{})
+	for _, c := range cases {
+		f, err := os.Open(filepath.Join("testdata", "ogp", c.fixture))
+		if err != nil {
+			t.Fatalf("case %s: error opening fixture: %v", c.name, err)
+		}
+		summary, err := summarizer.Summarize(context.Background(), ogpExample(c.fixture), f)
+		f.Close()
+		if err != nil {
+			t.Errorf("case %s: unexpected error %v\nHINT: %s\n", c.name, err, c.hint)
+			continue
+		}
+		if !reflect.DeepEqual(summary, c.expectedSummary) {
+			expectedJSON, _ := json.MarshalIndent(c.expectedSummary, "", "  ")
+			actualJSON, _ := json.MarshalIndent(summary, "", "  ")
+			t.Errorf("case %s: incorrect result:\nEXPECTED: %s\nACTUAL: %s\nHINT: %s\n",
+				c.name, string(expectedJSON), string(actualJSON), c.hint)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package summary
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+//MaxStaleDuration is the longest a RedisCache keeps
+//entries after they go stale
+const MaxStaleDuration = 24 * time.Hour
+
+//RedisCache represents a SummaryCache backed by redis. Anyone who can
+//request a summary can add an entry, so the redis server should be
+//one of its own, with a maxmemory limit and an eviction policy, rather
+//than the one sessions are stored in.
+type RedisCache struct {
+	//Redis client used to talk to redis server.
+	Client *redis.Client
+	//How long entries are kept after they go stale, so that
+	//they can be revalidated with the origin server. Durations
+	//over MaxStaleDuration are treated as MaxStaleDuration.
+	StaleDuration time.Duration
+}
+
+//NewRedisCache constructs a new RedisCache
+func NewRedisCache(client *redis.Client, staleDuration time.Duration) *RedisCache {
+	return &RedisCache{Client: client, StaleDuration: staleDuration}
+}
+
+//Get returns the entry previously saved for `pageURL`,
+//or ErrCacheMiss if there is none
+func (rc *RedisCache) Get(pageURL string) (*CacheEntry, error) {
+	j, err := rc.Client.Get(summaryRedisKey(pageURL)).Bytes()
+	if err == redis.Nil {
+		return nil, ErrCacheMiss
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting cached summary: %v", err)
+	}
+	entry := &CacheEntry{}
+	if err := json.Unmarshal(j, entry); err != nil {
+		return nil, fmt.Errorf("error unmarshalling cached summary: %v", err)
+	}
+	return entry, nil
+}
+
+//Set saves `entry` for `pageURL`. Redis deletes the entry
+//once it has been stale for the StaleDuration. Entries that are
+//already stale with no StaleDuration aren't saved at all, and any
+//older entry for `pageURL` is deleted instead.
+func (rc *RedisCache) Set(pageURL string, entry *CacheEntry) error {
+	j, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	ttl := time.Until(entry.Expires)
+	if ttl < 0 {
+		ttl = 0
+	}
+	//redis treats an expiration of zero as never expiring,
+	//and can't expire keys any sooner than a millisecond
+	stale := rc.StaleDuration
+	if stale > MaxStaleDuration {
+		stale = MaxStaleDuration
+	}
+	expiration := ttl + stale
+	if expiration < time.Millisecond {
+		if err := rc.Client.Del(summaryRedisKey(pageURL)).Err(); err != nil {
+			return fmt.Errorf("error deleting cached summary: %v", err)
+		}
+		return nil
+	}
+	if err := rc.Client.Set(summaryRedisKey(pageURL), j, expiration).Err(); err != nil {
+		return fmt.Errorf("error caching summary: %v", err)
+	}
+	return nil
+}
+
+//summaryRedisKey returns the redis key to use for the summary cached
+//under `key`: a hash of it, so keys have a fixed size however long
+//the URLs clients send are, prefixed to keep them separate from any
+//other keys
+func summaryRedisKey(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return "summary:" + hex.EncodeToString(hash[:])
+}
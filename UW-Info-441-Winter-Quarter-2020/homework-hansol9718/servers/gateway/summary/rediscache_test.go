@@ -0,0 +1,95 @@
+package summary
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+/*
+TestRedisCache tests the RedisCache object. Like TestRedisStore
+in the sessions package, this is an integration test that uses a
+local instance of redis on its default port, or the address in
+the REDISADDR environment variable.
+*/
+func TestRedisCache(t *testing.T) {
+	redisaddr := os.Getenv("REDISADDR")
+	if len(redisaddr) == 0 {
+		redisaddr = "127.0.0.1:6379"
+	}
+	client := redis.NewClient(&redis.Options{
+		Addr: redisaddr,
+	})
+	cache := NewRedisCache(client, time.Hour)
+
+	pageURL := "http://test.com/rediscache-test.html"
+	client.Del(summaryRedisKey(pageURL))
+
+	if _, err := cache.Get(pageURL); err != ErrCacheMiss {
+		t.Errorf("incorrect error when getting an entry that was never cached: expected %v but got %v", ErrCacheMiss, err)
+	}
+
+	entry := &CacheEntry{
+		Summary:      &PageSummary{Title: "test title"},
+		Expires:      time.Now().Add(time.Minute).UTC().Round(0),
+		LastModified: "Sat, 01 Feb 2020 11:00:00 GMT",
+	}
+	if err := cache.Set(pageURL, entry); err != nil {
+		t.Fatalf("error caching entry: %v", err)
+	}
+	entryRet, err := cache.Get(pageURL)
+	if err != nil {
+		t.Fatalf("error getting cached entry: %v", err)
+	}
+	if !reflect.DeepEqual(entry, entryRet) {
+		jexp, _ := json.MarshalIndent(entry, "", "  ")
+		jact, _ := json.MarshalIndent(entryRet, "", "  ")
+		t.Errorf("incorrect entry retrieved:\nEXPECTED\n%s\nACTUAL\n%s", string(jexp), string(jact))
+	}
+
+	//entries are kept in redis until they've been stale for the StaleDuration
+	ttl := client.TTL(summaryRedisKey(pageURL)).Val()
+	if ttl <= time.Hour || ttl > time.Hour+time.Minute {
+		t.Errorf("incorrect redis TTL: expected just over an hour but got %v", ttl)
+	}
+
+	//entries that are already stale, with no time to revalidate them,
+	//must not be stored, as redis would never expire them
+	noStale := NewRedisCache(client, 0)
+	entry.Expires = time.Now().UTC().Round(0)
+	if err := noStale.Set(pageURL, entry); err != nil {
+		t.Fatalf("error caching stale entry: %v", err)
+	}
+	if _, err := noStale.Get(pageURL); err != ErrCacheMiss {
+		t.Errorf("stale entries should not be cached without a StaleDuration, and should replace older entries, but got error %v", err)
+	}
+	if ttl := client.TTL(summaryRedisKey(pageURL)).Val(); ttl == -1 {
+		t.Errorf("stale entries must never be stored without an expiration")
+	}
+	client.Del(summaryRedisKey(pageURL))
+
+	//stale entries are kept for no longer than MaxStaleDuration
+	longStale := NewRedisCache(client, 30*24*time.Hour)
+	if err := longStale.Set(pageURL, entry); err != nil {
+		t.Fatalf("error caching stale entry: %v", err)
+	}
+	if ttl := client.TTL(summaryRedisKey(pageURL)).Val(); ttl <= 0 || ttl > MaxStaleDuration {
+		t.Errorf("incorrect redis TTL: expected no more than %v but got %v", MaxStaleDuration, ttl)
+	}
+	client.Del(summaryRedisKey(pageURL))
+}
+
+func TestSummaryRedisKey(t *testing.T) {
+	key := summaryRedisKey("http://test.com/page.html")
+	if !strings.HasPrefix(key, "summary:") || len(key) != len("summary:")+64 {
+		t.Errorf("keys should be a prefixed hash of the cache key, but got %q", key)
+	}
+	if summaryRedisKey("http://test.com/"+strings.Repeat("a", 1000)) == key || len(summaryRedisKey(strings.Repeat("a", 1000))) != len(key) {
+		t.Errorf("different cache keys should have different redis keys of the same length")
+	}
+}
@@ -0,0 +1,260 @@
+package summary
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"reflect"
+	"time"
+)
+
+//DefaultCacheTTL is how long cached summaries stay fresh when
+//the origin server doesn't say how long its page may be cached
+const DefaultCacheTTL = 10 * time.Minute
+
+//defaultFetcher is used by Summarizers that have no Fetcher
+var defaultFetcher = NewFetcher()
+
+//PreviewImage represents a preview image for a page.
+//For icons, Rel is the kind of icon, like IconRelAppleTouchIcon.
+type PreviewImage struct {
+	URL       string `json:"url,omitempty"`
+	SecureURL string `json:"secureURL,omitempty"`
+	Type      string `json:"type,omitempty"`
+	Width     int    `json:"width,omitempty"`
+	Height    int    `json:"height,omitempty"`
+	Alt       string `json:"alt,omitempty"`
+	Rel       string `json:"rel,omitempty"`
+}
+
+//PageSummary represents summary properties for a web page
+type PageSummary struct {
+	Type             string            `json:"type,omitempty"`
+	URL              string            `json:"url,omitempty"`
+	Title            string            `json:"title,omitempty"`
+	Determiner       string            `json:"determiner,omitempty"`
+	SiteName         string            `json:"siteName,omitempty"`
+	Description      string            `json:"description,omitempty"`
+	Excerpt          string            `json:"excerpt,omitempty"`
+	WordCount        int               `json:"wordCount,omitempty"`
+	ReadingTime      int               `json:"readingTime,omitempty"`
+	Author           string            `json:"author,omitempty"`
+	Keywords         []string          `json:"keywords,omitempty"`
+	Locale           string            `json:"locale,omitempty"`
+	AlternateLocales []string          `json:"alternateLocales,omitempty"`
+	Icon             *PreviewImage     `json:"icon,omitempty"`
+	Icons            []*PreviewImage   `json:"icons,omitempty"`
+	Images           []*PreviewImage   `json:"images,omitempty"`
+	Videos           []*PreviewVideo   `json:"videos,omitempty"`
+	Audios           []*PreviewAudio   `json:"audios,omitempty"`
+	Object           *OpenGraphObject  `json:"object,omitempty"`
+	Twitter          *TwitterCard      `json:"twitter,omitempty"`
+	Structured       []*StructuredData `json:"structured,omitempty"`
+	Embed            *Embed            `json:"embed,omitempty"`
+}
+
+//Options are the settings of a single SummarizeURL call
+type Options struct {
+	//Deep reads the page's body as well as its head, as a Deep
+	//Summarizer does, for this page only. Deep and shallow
+	//summaries of a page are cached separately.
+	Deep bool
+}
+
+//Summarizer produces a PageSummary for a web page by running
+//an ordered list of Extractors over the page's head, and
+//its body too if the Summarizer is Deep.
+//
+//Each Extractor reads one source of metadata and returns the
+//properties it found. The results are combined field by field:
+//a PageSummary field is taken from the first Extractor that
+//provides a non-empty value for it, so Extractors earlier in the
+//list take precedence over later ones. Fields that hold a list,
+//such as Images or Keywords, are never combined from several
+//Extractors; the whole list comes from the first one that has any.
+type Summarizer struct {
+	//Extractors are run against every page, in order
+	Extractors []Extractor
+	//Fetcher fetches pages, and anything else Extractors need.
+	//If nil, a Fetcher with the default settings is used.
+	Fetcher *Fetcher
+	//Cache, if not nil, caches the summaries made by SummarizeURL
+	Cache SummaryCache
+	//CacheTTL is how long cached summaries stay fresh when the
+	//origin server doesn't say how long its page may be cached
+	CacheTTL time.Duration
+	//Deep makes the Summarizer read the body of pages as well as
+	//their head, so that ContentExtractor can summarize their text.
+	//This is slower: otherwise the body is only scanned for the
+	//start tags of JSON-LD scripts, rather than tokenized in full.
+	Deep bool
+}
+
+//NewSummarizer constructs a new Summarizer that runs `extractors`
+//in the given order. If no extractors are given, the Summarizer uses
+//DefaultExtractors()
+func NewSummarizer(extractors ...Extractor) *Summarizer {
+	if len(extractors) == 0 {
+		extractors = DefaultExtractors()
+	}
+	return &Summarizer{
+		Extractors: extractors,
+		CacheTTL:   DefaultCacheTTL,
+	}
+}
+
+//DefaultExtractors returns the Extractors a Summarizer uses when
+//none are specified, in order of precedence: Open Graph properties
+//are preferred over Twitter Card properties, then JSON-LD structured
+//data, and finally plain HTML metadata. The page's icons are collected
+//for an Icon of DefaultIconSize, and the page's oEmbed provider, if it
+//has one, is only consulted for the summary's Embed. The page's
+//body is only read when the Summarizer is Deep, and is only used for
+//what none of the page's metadata provides.
+func DefaultExtractors() []Extractor {
+	return []Extractor{
+		&OpenGraphExtractor{},
+		&TwitterExtractor{},
+		&JSONLDExtractor{},
+		&HTMLMetaExtractor{},
+		&IconExtractor{Size: DefaultIconSize},
+		&OEmbedExtractor{},
+		&ContentExtractor{},
+	}
+}
+
+//Summarize reads the HTML page served from `pageURL` from `r` and
+//returns a summary of it. `pageURL` should be the URL the page was
+//actually served from, after any redirects, as relative URLs in the
+//page are resolved against it. The page must be encoded in UTF-8;
+//SummarizeURL transcodes pages that use other character sets.
+//Anything Extractors fetch is canceled when `ctx` is done.
+func (s *Summarizer) Summarize(ctx context.Context, pageURL string, r io.Reader) (*PageSummary, error) {
+	return s.summarize(ctx, pageURL, r, s.Deep)
+}
+
+//summarize is Summarize, reading the page's body too if `deep` is true
+func (s *Summarizer) summarize(ctx context.Context, pageURL string, r io.Reader, deep bool) (*PageSummary, error) {
+	parse := ParseDocument
+	if deep {
+		parse = ParseDocumentBody
+	}
+	doc, err := parse(pageURL, r)
+	if err != nil {
+		return nil, err
+	}
+	doc.Fetcher = s.fetcher()
+	doc.Context = ctx
+
+	page := &PageSummary{}
+	for _, extractor := range s.Extractors {
+		if found := extractor.Extract(doc); found != nil {
+			merge(page, found)
+		}
+	}
+	return page, nil
+}
+
+//SummarizeURL fetches the HTML page at `pageURL` and returns a summary
+//of it, along with whether the summary came from the Summarizer's Cache.
+//
+//A cached summary is used as-is while it is fresh, according to the
+//Cache-Control or Expires headers the page was served with. Once stale,
+//it is revalidated with a conditional request using the page's ETag or
+//Last-Modified header, and used again if the page hasn't changed.
+//Errors from the Cache are ignored; the page is summarized instead.
+//
+//Pages that declare no icons are given the one at /favicon.ico
+//on their host, if there is one. The page's body is read if either
+//the Summarizer or `opts` is Deep.
+//
+//Fetching is canceled when `ctx` is done, and is subject to the limits
+//of the Summarizer's Fetcher, so errors may wrap ErrTimeout, ErrTooLarge
+//or ErrTooManyRedirects.
+func (s *Summarizer) SummarizeURL(ctx context.Context, pageURL string, opts Options) (*PageSummary, CacheStatus, error) {
+	deep := s.Deep || opts.Deep
+	key := cacheKey(pageURL, deep)
+	var cached *CacheEntry
+	header := http.Header{}
+	if s.Cache != nil {
+		if entry, err := s.Cache.Get(key); err == nil {
+			if entry.Fresh(time.Now()) {
+				return entry.Summary, CacheHit, nil
+			}
+			cached = entry
+			if len(entry.ETag) > 0 {
+				header.Set("If-None-Match", entry.ETag)
+			}
+			if len(entry.LastModified) > 0 {
+				header.Set("If-Modified-Since", entry.LastModified)
+			}
+		}
+	}
+
+	resp, err := s.fetcher().fetchPage(ctx, pageURL, header)
+	if err != nil {
+		return nil, CacheMiss, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		//a 304 to an unconditional request has no page to summarize
+		if cached == nil {
+			return nil, CacheMiss, &StatusError{StatusCode: resp.StatusCode}
+		}
+		//304 responses don't have to repeat the validators
+		if len(resp.Header.Get("ETag")) == 0 {
+			resp.Header.Set("ETag", cached.ETag)
+		}
+		if len(resp.Header.Get("Last-Modified")) == 0 {
+			resp.Header.Set("Last-Modified", cached.LastModified)
+		}
+		s.cache(key, cached.Summary, resp.Header)
+		return cached.Summary, CacheHit, nil
+	}
+
+	page, err := s.summarize(ctx, resp.Request.URL.String(), resp.Body, deep)
+	if err != nil {
+		return nil, CacheMiss, err
+	}
+	if len(page.Icons) == 0 {
+		if icon := s.probeFavicon(ctx, resp.Request.URL); icon != nil {
+			page.Icon = icon
+			page.Icons = []*PreviewImage{icon}
+		}
+	}
+	s.cache(key, page, resp.Header)
+	return page, CacheMiss, nil
+}
+
+//fetcher returns the Summarizer's Fetcher,
+//or the default Fetcher if it has none
+func (s *Summarizer) fetcher() *Fetcher {
+	if s.Fetcher != nil {
+		return s.Fetcher
+	}
+	return defaultFetcher
+}
+
+//cache saves `page` under `key` in the Summarizer's Cache, if it
+//has one and the response headers `header` allow it
+func (s *Summarizer) cache(key string, page *PageSummary, header http.Header) {
+	if s.Cache == nil {
+		return
+	}
+	if entry := cacheEntry(page, header, time.Now(), s.CacheTTL); entry != nil {
+		s.Cache.Set(key, entry)
+	}
+}
+
+//merge sets every field of `dst` that is still empty
+//to the value of the same field in `src`
+func merge(dst *PageSummary, src *PageSummary) {
+	dv := reflect.ValueOf(dst).Elem()
+	sv := reflect.ValueOf(src).Elem()
+	for i := 0; i < dv.NumField(); i++ {
+		if field := dv.Field(i); field.IsZero() {
+			field.Set(sv.Field(i))
+		}
+	}
+}
@@ -0,0 +1,949 @@
+package summary
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSummarize(t *testing.T) {
+	pagePrologue := "<html><head>"
+	pageEiplogue := "</head><body></body></html>"
+	pageURL := "http://test.com/test.html"
+	cases := []struct {
+		name            string
+		hint            string
+		html            string
+		expectedSummary *PageSummary
+	}{
+		{
+			"Open Graph Type",
+			`Make sure you are reading the <meta property="og:type" content="..."> element`,
+			pagePrologue + `<meta property="og:type" content="test type">` + pageEiplogue,
+			&PageSummary{
+				Type: "test type",
+			},
+		},
+		{
+			"Open Graph URL",
+			`Make sure you are reading the <meta property="og:url" content="..."> element`,
+			pagePrologue + `<meta property="og:url" content="http://test.com">` + pageEiplogue,
+			&PageSummary{
+				URL: "http://test.com",
+			},
+		},
+		{
+			"Open Graph Title",
+			`Make sure you are reading the <meta property="og:title" content="..."> element`,
+			pagePrologue + `<meta property="og:title" content="test title">` + pageEiplogue,
+			&PageSummary{
+				Title: "test title",
+			},
+		},
+		{
+			"Open Graph Site name",
+			`Make sure you are reading the <meta property="og:site_name" content="..."> element`,
+			pagePrologue + `<meta property="og:site_name" content="test site name">` + pageEiplogue,
+			&PageSummary{
+				SiteName: "test site name",
+			},
+		},
+		{
+			"Open Graph Description",
+			`Make sure you are reading the <meta property="og:description" content="..."> element`,
+			pagePrologue + `<meta property="og:description" content="test description">` + pageEiplogue,
+			&PageSummary{
+				Description: "test description",
+			},
+		},
+		{
+			"Open Graph Image",
+			`Make sure you are reading the <meta property="og:image" content="..."> element`,
+			pagePrologue + `<meta property="og:image" content="http://test.com/test.png">` + pageEiplogue,
+			&PageSummary{
+				Images: []*PreviewImage{
+					{
+						URL: "http://test.com/test.png",
+					},
+				},
+			},
+		},
+		{
+			"Open Graph Structured Image",
+			`Make sure you are handling the image structured properties, as described in http://ogp.me/#structured`,
+			pagePrologue + `
+			<meta property="og:image" content="http://test.com/test.png">
+			<meta property="og:image:secure_url" content="https://test.com/test.png">
+			<meta property="og:image:type" content="image/png">
+			<meta property="og:image:width" content="300">
+			<meta property="og:image:height" content="300">
+			<meta property="og:image:alt" content="test alt">
+			` + pageEiplogue,
+			&PageSummary{
+				Images: []*PreviewImage{
+					{
+						URL:       "http://test.com/test.png",
+						SecureURL: "https://test.com/test.png",
+						Type:      "image/png",
+						Width:     300,
+						Height:    300,
+						Alt:       "test alt",
+					},
+				},
+			},
+		},
+		{
+			"Open Graph Multiple Images",
+			`Make sure you are handling multiple images, as described in http://ogp.me/#array`,
+			pagePrologue + `
+			<meta property="og:image" content="http://test.com/test1.png">
+			<meta property="og:image:width" content="100">
+			<meta property="og:image:height" content="100">
+			<meta property="og:image:alt" content="test alt 1">
+			<meta property="og:image" content="http://test.com/test2.png">
+			<meta property="og:image" content="http://test.com/test3.png">
+			<meta property="og:image:alt" content="test alt 3">
+			` + pageEiplogue,
+			&PageSummary{
+				Images: []*PreviewImage{
+					{
+						URL:    "http://test.com/test1.png",
+						Width:  100,
+						Height: 100,
+						Alt:    "test alt 1",
+					},
+					{
+						URL: "http://test.com/test2.png",
+					},
+					{
+						URL: "http://test.com/test3.png",
+						Alt: "test alt 3",
+					},
+				},
+			},
+		},
+		{
+			"Open Graph Image URL",
+			"og:image:url is the same as og:image, so it starts a new image unless it repeats the current image's URL",
+			pagePrologue + `
+			<meta property="og:image:url" content="http://test.com/test1.png">
+			<meta property="og:image:width" content="100">
+			<meta property="og:image" content="http://test.com/test2.png">
+			<meta property="og:image:url" content="http://test.com/test2.png">
+			<meta property="og:image:alt" content="test alt 2">
+			<meta property="og:image" content="http://test.com/test3.png">
+			<meta property="og:image:url" content="http://test.com/test4.png">
+			<meta property="og:image:alt" content="test alt 4">
+			` + pageEiplogue,
+			&PageSummary{
+				Images: []*PreviewImage{
+					{
+						URL:   "http://test.com/test1.png",
+						Width: 100,
+					},
+					{
+						URL: "http://test.com/test2.png",
+						Alt: "test alt 2",
+					},
+					{
+						URL: "http://test.com/test3.png",
+					},
+					{
+						URL: "http://test.com/test4.png",
+						Alt: "test alt 4",
+					},
+				},
+			},
+		},
+		{
+			"Open Graph Empty URLs",
+			"Empty image, video and audio URLs would resolve to the page itself, so they should be skipped",
+			pagePrologue + `
+			<meta property="og:title" content="test title">
+			<meta property="og:image" content="">
+			<meta property="og:image:width" content="100">
+			<meta property="og:video" content="  ">
+			<meta property="og:audio" content="http://test.com/test.mp3">
+			<meta property="og:audio:url" content="">
+			<meta property="og:audio:secure_url" content="">
+			<meta name="twitter:image" content=" ">
+			` + pageEiplogue,
+			&PageSummary{
+				Title: "test title",
+				Audios: []*PreviewAudio{
+					{
+						URL: "http://test.com/test.mp3",
+					},
+				},
+			},
+		},
+		{
+			"All Open Graph Props",
+			"Make sure you are handling all of the Open Graph properties listed in the assignment",
+			pagePrologue + `
+			<meta property="og:type" content="test type">
+			<meta property="og:url" content="http://test.com">
+			<meta property="og:title" content="test title">
+			<meta property="og:site_name" content="test site name">
+			<meta property="og:description" content="test description">
+			<meta property="og:image" content="http://test.com/test.png">
+			` + pageEiplogue,
+			&PageSummary{
+				Type:        "test type",
+				URL:         "http://test.com",
+				Title:       "test title",
+				SiteName:    "test site name",
+				Description: "test description",
+				Images: []*PreviewImage{
+					{
+						URL: "http://test.com/test.png",
+					},
+				},
+			},
+		},
+		{
+			"HTML Title",
+			`Make sure you get the page title from the <title> element if not Open Graph title property is available`,
+			pagePrologue + `<title>HTML Page Title</title>` + pageEiplogue,
+			&PageSummary{
+				Title: "HTML Page Title",
+			},
+		},
+		{
+			"HTML Description",
+			`Make sure you get the page description from the <meta name="author" content="..."> tag if no Open Graph description is available`,
+			pagePrologue + `<meta name="description" content="test description">` + pageEiplogue,
+			&PageSummary{
+				Description: "test description",
+			},
+		},
+		{
+			"HTML Author",
+			`Make sure you get the page author from the <meta name="author" content="..."> tag`,
+			pagePrologue + `<meta name="author" content="test author">` + pageEiplogue,
+			&PageSummary{
+				Author: "test author",
+			},
+		},
+		{
+			"HTML Keywords With Spaces",
+			`Make sure you get the page keywords from the <meta name="keywords" content="..."> tag`,
+			pagePrologue + `<meta name="keywords" content="one, two, three">` + pageEiplogue,
+			&PageSummary{
+				Keywords: []string{"one", "two", "three"},
+			},
+		},
+		{
+			"HTML Keywords With No Spaces",
+			`Make sure you get the page keywords from the <meta name="keywords" content="..."> tag`,
+			pagePrologue + `<meta name="keywords" content="one,two,three">` + pageEiplogue,
+			&PageSummary{
+				Keywords: []string{"one", "two", "three"},
+			},
+		},
+		{
+			"HTML Icon",
+			`Make sure you get the page icon from the <link rel="icon" href="..."> tag`,
+			pagePrologue + `<link rel="icon" href="http://test.com/test.png">` + pageEiplogue,
+			&PageSummary{
+				Icon: &PreviewImage{
+					URL: "http://test.com/test.png",
+					Rel: "icon",
+				},
+				Icons: []*PreviewImage{
+					{
+						URL: "http://test.com/test.png",
+						Rel: "icon",
+					},
+				},
+			},
+		},
+		{
+			"HTML Icon With Sizes",
+			`Make sure you parse the 'sizes' attribute to get the icon height and width`,
+			pagePrologue + `<link rel="icon" href="http://test.com/test.png" sizes="100x200">` + pageEiplogue,
+			&PageSummary{
+				Icon: &PreviewImage{
+					URL:    "http://test.com/test.png",
+					Width:  100,
+					Height: 200,
+					Rel:    "icon",
+				},
+				Icons: []*PreviewImage{
+					{
+						URL:    "http://test.com/test.png",
+						Width:  100,
+						Height: 200,
+						Rel:    "icon",
+					},
+				},
+			},
+		},
+		{
+			"HTML Icon With Size Any",
+			`The sizes attribute of the <link rel="icon"> tag may have the value "any" to indicate no size preference`,
+			pagePrologue + `<link rel="icon" href="http://test.com/test.png" sizes="any">` + pageEiplogue,
+			&PageSummary{
+				Icon: &PreviewImage{
+					URL: "http://test.com/test.png",
+					Rel: "icon",
+				},
+				Icons: []*PreviewImage{
+					{
+						URL: "http://test.com/test.png",
+						Rel: "icon",
+					},
+				},
+			},
+		},
+		{
+			"HTML Icon With Type",
+			`Make sure you read the 'type' attribute to get the icon type`,
+			pagePrologue + `<link rel="icon" href="http://test.com/test.png" type="image/png">` + pageEiplogue,
+			&PageSummary{
+				Icon: &PreviewImage{
+					URL:  "http://test.com/test.png",
+					Type: "image/png",
+					Rel:  "icon",
+				},
+				Icons: []*PreviewImage{
+					{
+						URL:  "http://test.com/test.png",
+						Type: "image/png",
+						Rel:  "icon",
+					},
+				},
+			},
+		},
+		{
+			"Self-Closing Meta",
+			"Make sure you are handling self-closing <meta ... /> tags",
+			pagePrologue + `<meta property="og:title" content="Open Graph Title"/>` + pageEiplogue,
+			&PageSummary{
+				Title: "Open Graph Title",
+			},
+		},
+		{
+			"Attribute Order",
+			"HTML elements and attributes can be in any order; don't assume a particular order",
+			pagePrologue + `
+			<meta content="test title" property="og:title">
+			<meta content="test type" property="og:type">
+			<meta content="http://test.com/test.png" property="og:image">
+			<meta content="test site name" property="og:site_name">
+			<meta content="test description" property="og:description">
+			<meta content="http://test.com" property="og:url">
+			` + pageEiplogue,
+			&PageSummary{
+				Type:        "test type",
+				URL:         "http://test.com",
+				Title:       "test title",
+				SiteName:    "test site name",
+				Description: "test description",
+				Images: []*PreviewImage{
+					{
+						URL: "http://test.com/test.png",
+					},
+				},
+			},
+		},
+		{
+			"HTML and Open Graph Title",
+			`Make sure the <meta property="og:title"> overrides the HTML <title> element`,
+			pagePrologue + `
+			<meta property="og:title" content="Open Graph Title"/>
+			<title>HTML Page Title</title>` + pageEiplogue,
+			&PageSummary{
+				Title: "Open Graph Title",
+			},
+		},
+		{
+			"HTML and Open Graph Description",
+			`Make sure the <meta property="og:description"> overrides the HTML <meta name="description"> element`,
+			pagePrologue + `
+			<meta property="og:description" content="og description"/>
+			<meta name="description" content="html description">` + pageEiplogue,
+			&PageSummary{
+				Description: "og description",
+			},
+		},
+		{
+			"Relative Image URL",
+			"Remember to resolve relative image URLs to absolute ones using the page URL as a base",
+			pagePrologue + `<meta property="og:image" content="/test.png"/>` + pageEiplogue,
+			&PageSummary{
+				Images: []*PreviewImage{
+					{
+						URL: "http://test.com/test.png",
+					},
+				},
+			},
+		},
+		{
+			"Relative Icon URL",
+			"Remember to resolve relative HTML Icon URLs to absolute ones using the page URL as a base",
+			pagePrologue + `<link rel="icon" href="/test.png"/>` + pageEiplogue,
+			&PageSummary{
+				Icon: &PreviewImage{
+					URL: "http://test.com/test.png",
+					Rel: "icon",
+				},
+				Icons: []*PreviewImage{
+					{
+						URL: "http://test.com/test.png",
+						Rel: "icon",
+					},
+				},
+			},
+		},
+		{
+			"Protocol-Relative Image URL",
+			"Protocol-relative URLs should take the scheme of the page URL",
+			pagePrologue + `<meta property="og:image" content="//cdn.test.com/test.png"/>` + pageEiplogue,
+			&PageSummary{
+				Images: []*PreviewImage{
+					{
+						URL: "http://cdn.test.com/test.png",
+					},
+				},
+			},
+		},
+		{
+			"Path-Relative Image URL",
+			"Path-relative URLs should be resolved against the directory of the page URL",
+			pagePrologue + `
+			<meta property="og:image" content="images/test.png"/>
+			<meta property="og:image:secure_url" content="../secure/test.png"/>
+			` + pageEiplogue,
+			&PageSummary{
+				Images: []*PreviewImage{
+					{
+						URL:       "http://test.com/images/test.png",
+						SecureURL: "http://test.com/secure/test.png",
+					},
+				},
+			},
+		},
+		{
+			"Base Element",
+			`Make sure relative URLs are resolved against the <base href="..."> element when there is one`,
+			pagePrologue + `
+			<link rel="icon" href="favicon.png"/>
+			<base href="http://base.test.com/dir/">
+			<meta property="og:image" content="test.png"/>
+			` + pageEiplogue,
+			&PageSummary{
+				Icon: &PreviewImage{
+					URL: "http://base.test.com/dir/favicon.png",
+					Rel: "icon",
+				},
+				Icons: []*PreviewImage{
+					{
+						URL: "http://base.test.com/dir/favicon.png",
+						Rel: "icon",
+					},
+				},
+				Images: []*PreviewImage{
+					{
+						URL: "http://base.test.com/dir/test.png",
+					},
+				},
+			},
+		},
+		{
+			"Relative Base Element",
+			`A relative <base href="..."> is itself resolved against the page URL, and only the first one counts`,
+			pagePrologue + `
+			<base href="/static/">
+			<base href="/ignored/">
+			<meta property="og:image" content="test.png"/>
+			` + pageEiplogue,
+			&PageSummary{
+				Images: []*PreviewImage{
+					{
+						URL: "http://test.com/static/test.png",
+					},
+				},
+			},
+		},
+		{
+			"Twitter Title and Description",
+			`Make sure you read <meta name="twitter:title"> and <meta name="twitter:description"> when there are no Open Graph properties`,
+			pagePrologue + `
+			<title>HTML Page Title</title>
+			<meta name="description" content="html description">
+			<meta name="twitter:title" content="twitter title">
+			<meta name="twitter:description" content="twitter description">
+			` + pageEiplogue,
+			&PageSummary{
+				Title:       "twitter title",
+				Description: "twitter description",
+			},
+		},
+		{
+			"Twitter Image",
+			`Make sure you read <meta name="twitter:image"> and <meta name="twitter:image:alt"> when there is no og:image`,
+			pagePrologue + `
+			<meta name="twitter:image" content="/twitter.png">
+			<meta name="twitter:image:alt" content="twitter alt">
+			` + pageEiplogue,
+			&PageSummary{
+				Images: []*PreviewImage{
+					{
+						URL: "http://test.com/twitter.png",
+						Alt: "twitter alt",
+					},
+				},
+			},
+		},
+		{
+			"Twitter Card",
+			"Make sure you read the twitter:card, twitter:site and twitter:creator properties",
+			pagePrologue + `
+			<meta name="twitter:card" content="summary_large_image">
+			<meta name="twitter:site" content="@testsite">
+			<meta name="twitter:creator" content="@testcreator">
+			` + pageEiplogue,
+			&PageSummary{
+				Twitter: &TwitterCard{
+					Card:    "summary_large_image",
+					Site:    "@testsite",
+					Creator: "@testcreator",
+				},
+			},
+		},
+		{
+			"Open Graph and Twitter",
+			"Make sure Open Graph properties override the equivalent Twitter Card properties",
+			pagePrologue + `
+			<meta name="twitter:card" content="summary">
+			<meta name="twitter:title" content="twitter title">
+			<meta name="twitter:description" content="twitter description">
+			<meta name="twitter:image" content="http://test.com/twitter.png">
+			<meta property="og:title" content="og title">
+			<meta property="og:description" content="og description">
+			<meta property="og:image" content="http://test.com/og.png">
+			` + pageEiplogue,
+			&PageSummary{
+				Title:       "og title",
+				Description: "og description",
+				Images: []*PreviewImage{
+					{
+						URL: "http://test.com/og.png",
+					},
+				},
+				Twitter: &TwitterCard{
+					Card: "summary",
+				},
+			},
+		},
+		{
+			"Twitter Property Attribute",
+			`Some pages use <meta property="twitter:..."> instead of name="twitter:..."`,
+			pagePrologue + `<meta property="twitter:title" content="twitter title">` + pageEiplogue,
+			&PageSummary{
+				Title: "twitter title",
+			},
+		},
+		{
+			"JSON-LD Article",
+			`Make sure you read schema.org data from <script type="application/ld+json"> elements`,
+			pagePrologue + `
+			<title>HTML Page Title</title>
+			<script type="application/ld+json">
+			{
+				"@context": "https://schema.org",
+				"@type": "NewsArticle",
+				"headline": "test headline",
+				"description": "test description",
+				"image": ["/test1.png", {"@type": "ImageObject", "url": "http://test.com/test2.png", "width": 300, "height": "200"}],
+				"datePublished": "2020-02-01T08:00:00+08:00",
+				"author": [{"@type": "Person", "name": "Author One"}, {"@type": "Person", "name": "Author Two"}],
+				"publisher": {"@type": "Organization", "name": "test publisher"}
+			}
+			</script>
+			` + pageEiplogue,
+			&PageSummary{
+				Title:       "test headline",
+				Description: "test description",
+				Author:      "Author One, Author Two",
+				Images: []*PreviewImage{
+					{
+						URL: "http://test.com/test1.png",
+					},
+					{
+						URL:    "http://test.com/test2.png",
+						Width:  300,
+						Height: 200,
+					},
+				},
+				Structured: []*StructuredData{
+					{
+						Type:        "NewsArticle",
+						Name:        "test headline",
+						Description: "test description",
+						Article: &ArticleData{
+							Headline:      "test headline",
+							Authors:       []string{"Author One", "Author Two"},
+							Publisher:     "test publisher",
+							DatePublished: "2020-02-01T08:00:00+08:00",
+						},
+					},
+				},
+			},
+		},
+		{
+			"JSON-LD Graph",
+			"Make sure you read every entity in a JSON-LD @graph array",
+			pagePrologue + `
+			<script type="application/ld+json">
+			{
+				"@context": "https://schema.org",
+				"@graph": [
+					{"@type": "Organization", "name": "test site", "logo": {"@type": "ImageObject", "url": "/logo.png"}, "sameAs": "https://twitter.com/test"},
+					{"@type": ["Thing", "Product"], "name": "test product", "brand": {"@type": "Brand", "name": "test brand"}, "sku": 1234,
+					 "offers": {"@type": "Offer", "price": 19.99, "priceCurrency": "USD", "availability": "https://schema.org/InStock"},
+					 "aggregateRating": {"@type": "AggregateRating", "ratingValue": "4.5", "reviewCount": "10"}}
+				]
+			}
+			</script>
+			` + pageEiplogue,
+			&PageSummary{
+				Title:    "test product",
+				SiteName: "test site",
+				Structured: []*StructuredData{
+					{
+						Type: "Organization",
+						Name: "test site",
+						Organization: &OrganizationData{
+							Logo:   "http://test.com/logo.png",
+							SameAs: []string{"https://twitter.com/test"},
+						},
+					},
+					{
+						Type: "Product",
+						Name: "test product",
+						Product: &ProductData{
+							Brand: "test brand",
+							SKU:   "1234",
+							Offers: []*Offer{
+								{
+									Price:         "19.99",
+									PriceCurrency: "USD",
+									Availability:  "https://schema.org/InStock",
+								},
+							},
+							Rating: &Rating{
+								Value: 4.5,
+								Count: 10,
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			"JSON-LD Recipe and Event",
+			"Make sure you read the Recipe and Event schema.org types",
+			pagePrologue + `
+			<script type="application/ld+json">
+			[
+				{"@type": "Recipe", "name": "test recipe", "author": "test cook", "prepTime": "PT15M", "cookTime": "PT1H",
+				 "recipeYield": ["4", "4 servings"], "recipeIngredient": ["1 egg", "2 cups flour"]},
+				{"@type": "Event", "name": "test event", "startDate": "2020-03-01T19:00", "location": {"@type": "Place", "name": "test venue"}}
+			]
+			</script>
+			` + pageEiplogue,
+			&PageSummary{
+				Title:  "test recipe",
+				Author: "test cook",
+				Structured: []*StructuredData{
+					{
+						Type: "Recipe",
+						Name: "test recipe",
+						Recipe: &RecipeData{
+							Authors:     []string{"test cook"},
+							PrepTime:    "PT15M",
+							CookTime:    "PT1H",
+							Yield:       "4",
+							Ingredients: []string{"1 egg", "2 cups flour"},
+						},
+					},
+					{
+						Type: "Event",
+						Name: "test event",
+						Event: &EventData{
+							StartDate: "2020-03-01T19:00",
+							Location:  "test venue",
+						},
+					},
+				},
+			},
+		},
+		{
+			"Open Graph and JSON-LD",
+			"Make sure Open Graph properties override JSON-LD, and invalid or unsupported JSON-LD is ignored",
+			pagePrologue + `
+			<meta property="og:title" content="og title">
+			<script type="application/ld+json">{"@type": "Article", "headline": </script>
+			<script type="application/ld+json">{"@type": "WebSite", "name": "test website"}</script>
+			<script type="application/ld+json">{"@type": "Article", "headline": "test headline", "description": "test description"}</script>
+			` + pageEiplogue,
+			&PageSummary{
+				Title:       "og title",
+				Description: "test description",
+				Structured: []*StructuredData{
+					{
+						Type:        "Article",
+						Name:        "test headline",
+						Description: "test description",
+						Article: &ArticleData{
+							Headline: "test headline",
+						},
+					},
+				},
+			},
+		},
+		{
+			"Empty Input",
+			"A URL might return an empty page",
+			"",
+			&PageSummary{},
+		},
+	}
+
+	summarizer := NewSummarizer()
+	for _, c := range cases {
+		summary, err := summarizer.Summarize(context.Background(), pageURL, strings.NewReader(c.html))
+		if err != nil && err != io.EOF {
+			t.Errorf("case %s: unexpected error %v\nHINT: %s\n", c.name, err, c.hint)
+		}
+		if summary == nil {
+			t.Errorf("case: %s: returned summary struct is nil", c.name)
+			continue
+		}
+		if !reflect.DeepEqual(summary, c.expectedSummary) {
+			//reflect.DeepEqual considers a non-nil empty slice to be different
+			//than a nill slice, so check for those cases first
+			if c.expectedSummary.Images == nil && summary.Images != nil {
+				t.Errorf("case %s: expected nil `Images` slice, but got a non-nill slice", c.name)
+			} else if c.expectedSummary.Keywords == nil && summary.Keywords != nil {
+				t.Errorf("case %s: expected nil `Keywords` slice, but got a non-nill slice", c.name)
+			} else if c.expectedSummary.Icon == nil && summary.Icon != nil {
+				t.Errorf("case %s: expected nil `Icon` pointer, but got a non-nill pointer", c.name)
+			} else if c.expectedSummary.Twitter == nil && summary.Twitter != nil {
+				t.Errorf("case %s: expected nil `Twitter` pointer, but got a non-nill pointer", c.name)
+			} else if c.expectedSummary.Structured == nil && summary.Structured != nil {
+				t.Errorf("case %s: expected nil `Structured` slice, but got a non-nill slice", c.name)
+			} else {
+				expectedJSON, _ := json.MarshalIndent(c.expectedSummary, "", "  ")
+				actualJSON, _ := json.MarshalIndent(summary, "", "  ")
+				t.Errorf("case %s: incorrect result:\nEXPECTED: %s\nACTUAL: %s\nHINT: %s\n",
+					c.name, string(expectedJSON), string(actualJSON), c.hint)
+			}
+		}
+	}
+}
+
+func TestSummarizeBodyJSONLD(t *testing.T) {
+	page := `<html><head><title>test title</title></head>
+		<body>
+		<p>Pages often put their structured data in the body, after the content it describes.</p>
+		<meta property="og:title" content="body title">
+		<script>var ignored = {"@type": "Organization"};</script>
+		<script type="application/ld+json">{"@type": "Organization", "name": "test org", "url": "", "logo": " "}</script>
+		</body></html>`
+	expected := []*StructuredData{
+		{
+			Type:         "Organization",
+			Name:         "test org",
+			Organization: &OrganizationData{},
+		},
+	}
+
+	for _, deep := range []bool{false, true} {
+		summarizer := NewSummarizer(&HTMLMetaExtractor{}, &JSONLDExtractor{})
+		summarizer.Deep = deep
+		summary, err := summarizer.Summarize(context.Background(), "http://test.com/test.html", strings.NewReader(page))
+		if err != nil {
+			t.Fatalf("deep %t: unexpected error %v", deep, err)
+		}
+		if !reflect.DeepEqual(summary.Structured, expected) || summary.SiteName != "test org" {
+			actualJSON, _ := json.MarshalIndent(summary.Structured, "", "  ")
+			t.Errorf("deep %t: JSON-LD in the body should be read, and empty URLs left empty, but got %s", deep, string(actualJSON))
+		}
+		if summary.Title != "test title" {
+			t.Errorf("deep %t: only JSON-LD should be read from the body, but the title was %q", deep, summary.Title)
+		}
+	}
+}
+
+func TestSummarizerPrecedence(t *testing.T) {
+	pageURL := "http://test.com/test.html"
+	page := `<html><head>
+	<title>HTML Title</title>
+	<meta name="description" content="html description">
+	<meta property="og:image:alt" content="orphaned alt">
+	<meta property="og:description" content="og description">
+	</head></html>`
+
+	first := ExtractorFunc(func(doc *Document) *PageSummary {
+		return &PageSummary{Title: "first title"}
+	})
+	none := ExtractorFunc(func(doc *Document) *PageSummary {
+		return nil
+	})
+	last := ExtractorFunc(func(doc *Document) *PageSummary {
+		return &PageSummary{Title: "last title", Author: "last author"}
+	})
+
+	cases := []struct {
+		name            string
+		hint            string
+		extractors      []Extractor
+		expectedSummary *PageSummary
+	}{
+		{
+			"Default Extractors",
+			"Open Graph properties should take precedence over HTML metadata",
+			nil,
+			&PageSummary{
+				Title:       "HTML Title",
+				Description: "og description",
+			},
+		},
+		{
+			"Earlier Extractors Win",
+			"A field should come from the first extractor that provides it",
+			[]Extractor{first, none, &OpenGraphExtractor{}, &HTMLMetaExtractor{}, last},
+			&PageSummary{
+				Title:       "first title",
+				Description: "og description",
+				Author:      "last author",
+			},
+		},
+		{
+			"Reordered Extractors",
+			"Putting the HTML extractor first should make it take precedence",
+			[]Extractor{&HTMLMetaExtractor{}, &OpenGraphExtractor{}},
+			&PageSummary{
+				Title:       "HTML Title",
+				Description: "html description",
+			},
+		},
+	}
+
+	for _, c := range cases {
+		summary, err := NewSummarizer(c.extractors...).Summarize(context.Background(), pageURL, strings.NewReader(page))
+		if err != nil {
+			t.Errorf("case %s: unexpected error %v\nHINT: %s\n", c.name, err, c.hint)
+			continue
+		}
+		if !reflect.DeepEqual(summary, c.expectedSummary) {
+			expectedJSON, _ := json.MarshalIndent(c.expectedSummary, "", "  ")
+			actualJSON, _ := json.MarshalIndent(summary, "", "  ")
+			t.Errorf("case %s: incorrect result:\nEXPECTED: %s\nACTUAL: %s\nHINT: %s\n",
+				c.name, string(expectedJSON), string(actualJSON), c.hint)
+		}
+	}
+}
+
+func TestSummarizeURLCharset(t *testing.T) {
+	contentTypes := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentTypes[r.URL.Path])
+		http.ServeFile(w, r, filepath.Join("testdata", "charset", r.URL.Path))
+	}))
+	defer server.Close()
+
+	cases := []struct {
+		name                string
+		hint                string
+		fixture             string
+		contentType         string
+		expectedTitle       string
+		expectedDescription string
+	}{
+		{
+			"Shift_JIS Header",
+			"The charset parameter of the Content-Type header gives the page's character set",
+			"shift_jis.html",
+			"text/html; charset=Shift_JIS",
+			"東京の天気予報",
+			"今日は晴れ、明日は雨でしょう。",
+		},
+		{
+			"EUC-KR Meta Charset",
+			"Without a charset parameter, <meta charset> gives the page's character set",
+			"euc-kr.html",
+			"text/html",
+			"서울 날씨",
+			"오늘은 맑고 내일은 비가 오겠습니다.",
+		},
+		{
+			"Windows-1252 Meta Http-Equiv",
+			"Without a charset parameter, <meta http-equiv=\"Content-Type\"> gives the page's character set",
+			"windows-1252.html",
+			"text/html",
+			"Café “Crème” Brûlée",
+			"A déjà vu — naïve façade for €5",
+		},
+		{
+			"GB18030 Header",
+			"The charset parameter of the Content-Type header gives the page's character set",
+			"gb18030.html",
+			"text/html; charset=gb18030",
+			"北京天气预报",
+			"今天晴，明天有雨。",
+		},
+		{
+			"UTF-16 Byte Order Mark",
+			"A byte order mark takes precedence over the header and <meta> elements",
+			"utf-16le-bom.html",
+			"text/html; charset=iso-8859-1",
+			"Ünïcödé ☃ 雪だるま",
+			"Byte order marks take precedence",
+		},
+		{
+			"Header Over Meta",
+			"The Content-Type header takes precedence over <meta> elements",
+			"shift_jis-meta-mismatch.html",
+			"text/html; charset=shift_jis",
+			"大阪の天気予報",
+			"ヘッダーの文字コードが優先されます。",
+		},
+		{
+			"Unlabeled UTF-8",
+			"Pages with no declared character set that are valid UTF-8 should be read as UTF-8",
+			"utf-8.html",
+			"text/html",
+			"Ελληνικά νέα",
+			"Pages without a declared character set are detected as UTF-8 if they are valid UTF-8",
+		},
+	}
+
+	summarizer := NewSummarizer(&HTMLMetaExtractor{})
+	summarizer.Fetcher = loopbackFetcher()
+	for _, c := range cases {
+		contentTypes["/"+c.fixture] = c.contentType
+		summary, _, err := summarizer.SummarizeURL(context.Background(), server.URL+"/"+c.fixture, Options{})
+		if err != nil {
+			t.Errorf("case %s: unexpected error %v\nHINT: %s\n", c.name, err, c.hint)
+			continue
+		}
+		if summary.Title != c.expectedTitle {
+			t.Errorf("case %s: incorrect title: expected %q but got %q\nHINT: %s\n",
+				c.name, c.expectedTitle, summary.Title, c.hint)
+		}
+		if summary.Description != c.expectedDescription {
+			t.Errorf("case %s: incorrect description: expected %q but got %q\nHINT: %s\n",
+				c.name, c.expectedDescription, summary.Description, c.hint)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package summary
+
+import "strings"
+
+//TwitterCard represents the Twitter Card properties of a page that
+//have no Open Graph equivalent. See
+//https://developer.twitter.com/en/docs/tweets/optimize-with-cards/overview/markup
+type TwitterCard struct {
+	Card    string `json:"card,omitempty"`
+	Site    string `json:"site,omitempty"`
+	Creator string `json:"creator,omitempty"`
+}
+
+//TwitterExtractor extracts Twitter Card properties
+//from a page's <meta> elements
+type TwitterExtractor struct{}
+
+//Extract implements the Extractor interface
+func (e *TwitterExtractor) Extract(doc *Document) *PageSummary {
+	page := &PageSummary{}
+	card := &TwitterCard{}
+	for _, token := range doc.Meta {
+		prop, content := metaProperty(token)
+		switch prop {
+		case "twitter:card":
+			card.Card = content
+		case "twitter:site":
+			card.Site = content
+		case "twitter:creator":
+			card.Creator = content
+		case "twitter:title":
+			page.Title = content
+		case "twitter:description":
+			page.Description = content
+		case "twitter:image", "twitter:image:src":
+			//cards have only one image, so a later
+			//twitter:image replaces an earlier one
+			//empty URLs would resolve to the page's own URL
+			if len(strings.TrimSpace(content)) > 0 {
+				page.Images = []*PreviewImage{{URL: doc.Resolve(content)}}
+			}
+		case "twitter:image:alt":
+			if len(page.Images) > 0 {
+				page.Images[0].Alt = content
+			}
+		}
+	}
+	if *card != (TwitterCard{}) {
+		page.Twitter = card
+	}
+	return page
+}